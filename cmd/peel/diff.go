@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/coffee-cup/peel/internal/image"
+	flag "github.com/spf13/pflag"
+)
+
+// runDiff implements `peel diff`: print a unified diff between two file
+// versions, exiting 1 if they differ and 2 on errors like diff(1).
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = diffUsage
+	registry := addRegistryFlags(fs)
+	source := addSourceFlags(fs)
+	toImage := fs.String("to-image", "", "read the second file from another image")
+	fs.Parse(args)
+
+	if fs.NArg() != 3 {
+		diffUsage()
+		os.Exit(2)
+	}
+	ref := fs.Arg(0)
+	fromRef, err := image.ParseFileRef(fs.Arg(1))
+	if err != nil {
+		diffFatal(err)
+	}
+	toRef, err := image.ParseFileRef(fs.Arg(2))
+	if err != nil {
+		diffFatal(err)
+	}
+
+	reg, err := registry.registries(ref)
+	if err != nil {
+		diffFatal(err)
+	}
+	opts, err := source.options(reg)
+	if err != nil {
+		diffFatal(err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	from, err := loadAndAnalyze(ctx, ref, opts, source.analyzeOptions())
+	if err != nil {
+		diffFatal(err)
+	}
+	to := from
+	if *toImage != "" {
+		if to, err = loadAndAnalyze(ctx, *toImage, opts, source.analyzeOptions()); err != nil {
+			diffFatal(err)
+		}
+	}
+
+	fd, err := image.CompareFiles(ctx, from, fromRef, to, toRef)
+	if err != nil {
+		diffFatal(err)
+	}
+	if fd.Diff == "" {
+		return
+	}
+	fmt.Print(fd.Diff)
+	if fd.Truncated {
+		fmt.Fprintln(os.Stderr, "peel: file content truncated, diff covers the leading bytes only")
+	}
+	os.Exit(1)
+}
+
+// diffFatal logs err and exits 2, since 1 means the files differ.
+func diffFatal(err error) {
+	log.Print(err)
+	os.Exit(2)
+}
+
+func diffUsage() {
+	fmt.Fprintf(os.Stderr, "%s\n", bold("Usage:"))
+	fmt.Fprintf(os.Stderr, "  peel diff <image> <[layer:]path> <[layer:]path> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Paths without a layer refer to the top layer. Binary files are compared as hex dumps.\n\n")
+	fmt.Fprintf(os.Stderr, "%s\n", bold("Flags:"))
+	fmt.Fprintf(os.Stderr, "      %s   %s\n", cyan("--to-image"), "read the second file from another image")
+	sourceUsage()
+	registryUsage()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	flag "github.com/spf13/pflag"
+)
+
+// runDockerfile implements `peel dockerfile`: print a Dockerfile
+// reconstructed from the image's history.
+func runDockerfile(args []string) {
+	fs := flag.NewFlagSet("dockerfile", flag.ExitOnError)
+	fs.Usage = dockerfileUsage
+	registry := addRegistryFlags(fs)
+	source := addSourceFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		dockerfileUsage()
+		os.Exit(2)
+	}
+
+	reg, err := registry.registries(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts, err := source.options(reg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	img, err := loadAndAnalyze(ctx, fs.Arg(0), opts, source.analyzeOptions())
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(img.Dockerfile())
+}
+
+func dockerfileUsage() {
+	fmt.Fprintf(os.Stderr, "%s\n", bold("Usage:"))
+	fmt.Fprintf(os.Stderr, "  peel dockerfile <image> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Reconstructs a Dockerfile from the image's build history, including base image layers.\n\n")
+	fmt.Fprintf(os.Stderr, "%s\n", bold("Flags:"))
+	sourceUsage()
+	registryUsage()
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/coffee-cup/peel/internal/image"
+	"github.com/coffee-cup/peel/internal/referrers"
 	"github.com/coffee-cup/peel/internal/server"
 	flag "github.com/spf13/pflag"
 	"golang.org/x/term"
@@ -22,12 +29,35 @@ func isTTY() bool {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "dockerfile":
+			runDockerfile(os.Args[2:])
+			return
+		case "verify":
+			runVerify(os.Args[2:])
+			return
+		case "report":
+			runReport(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Usage = usage
 
 	showVersion := flag.BoolP("version", "v", false, "print version and exit")
 	port := flag.IntP("port", "p", 0, "port to listen on")
+	host := flag.String("host", "127.0.0.1", "address to listen on")
 	noOpen := flag.Bool("no-open", false, "don't auto-open browser")
-	platform := flag.String("platform", "", "target platform os/arch")
+	dockerfilePath := flag.String("dockerfile", "", "Dockerfile the image was built from")
+	keyPath := flag.String("key", "", "cosign public key to verify signatures with")
+	watch := flag.Bool("watch", false, "reload when the image changes")
+	watchInterval := flag.Duration("watch-interval", 2*time.Second, "how often --watch checks the image")
+	registry := addRegistryFlags(flag.CommandLine)
+	source := addSourceFlags(flag.CommandLine)
 	flag.Parse()
 
 	if *showVersion {
@@ -41,19 +71,53 @@ func main() {
 		os.Exit(1)
 	}
 
-	plat, err := image.ParsePlatform(*platform)
+	reg, err := registry.registries(ref)
 	if err != nil {
 		log.Fatal(err)
 	}
+	opts, err := source.options(reg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
 
 	srv := server.New(ref)
+	srv.SetSource(opts.Source)
+	srv.SetListenHost(*host)
+	if opts.Offline {
+		srv.SetOffline()
+	} else {
+		srv.SetRegistries(reg, opts.Platform)
+	}
+	if *dockerfilePath != "" {
+		content, err := os.ReadFile(*dockerfilePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := srv.SetDockerfile(*dockerfilePath, content); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *keyPath != "" {
+		pub, err := loadKey(*keyPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		srv.SetVerifyKey(pub)
+	}
 
-	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
+	ln, err := net.Listen("tcp", net.JoinHostPort(*host, strconv.Itoa(*port)))
 	if err != nil {
 		log.Fatal(err)
 	}
-	actualPort := ln.Addr().(*net.TCPAddr).Port
-	url := fmt.Sprintf("http://localhost:%d", actualPort)
+	addr := ln.Addr().(*net.TCPAddr)
+	urlHost := "localhost"
+	if !addr.IP.IsLoopback() && !addr.IP.IsUnspecified() {
+		urlHost = addr.IP.String()
+	}
+	url := fmt.Sprintf("http://%s", net.JoinHostPort(urlHost, strconv.Itoa(addr.Port)))
 
 	if !*noOpen {
 		go openBrowser(url)
@@ -61,59 +125,147 @@ func main() {
 
 	log.Printf("listening on %s", url)
 
-	go func() {
-		log.Printf("loading %s (%s/%s)", ref, plat.OS, plat.Architecture)
-		img, err := image.LoadImage(ref, plat)
+	srv.SetLoader(func(ctx context.Context, t server.Target, previous *image.Image) (*server.Loaded, error) {
+		topts, err := targetOptions(opts, t)
 		if err != nil {
-			log.Printf("error loading image: %v", err)
-			srv.SetError(err)
-			return
+			return nil, err
 		}
-		analyzed, err := image.Analyze(img, ref)
+		log.Printf("loading %s (%s)", t.Ref, topts.Platform)
+		aopts := source.analyzeOptions()
+		aopts.Previous = previous
+		analyzed, err := loadAndAnalyze(ctx, t.Ref, topts, aopts)
 		if err != nil {
-			log.Printf("error analyzing image: %v", err)
-			srv.SetError(err)
-			return
+			if ctx.Err() == nil {
+				log.Printf("error %v", err)
+			}
+			return nil, err
+		}
+		log.Printf("analyzed %d layers (%d reused)", analyzed.Info.LayerCount, analyzed.Reused)
+		loaded := &server.Loaded{Image: analyzed}
+		if !topts.Offline && topts.Source != image.SourceOCI {
+			loaded.Finder = referrerFinder(reg, t.Ref, analyzed.Info.Digest)
+		}
+		return loaded, nil
+	})
+	go func() {
+		// with --watch, a failed first load is retried once the image appears
+		srv.Reload(ctx)
+		if *watch {
+			watchImage(ctx, srv, opts, *watchInterval)
 		}
-		log.Printf("analyzed %d layers", analyzed.Info.LayerCount)
-		srv.SetImage(analyzed)
 	}()
 
-	if err := http.Serve(ln, srv); err != nil {
+	// Requests share ctx, so in-flight layer reads stop on shutdown
+	httpSrv := &http.Server{
+		Handler:     srv,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		stop() // a second interrupt exits immediately
+		log.Printf("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpSrv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+	if err := httpSrv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal(err)
 	}
 }
 
-func usage() {
-	tty := isTTY()
-
-	bold := func(s string) string {
-		if tty {
-			return "\033[1m" + s + "\033[0m"
+// targetOptions applies the platform and source of an image opened from the
+// UI over the command line's.
+func targetOptions(opts image.LoadOptions, t server.Target) (image.LoadOptions, error) {
+	if t.Platform != "" {
+		plat, err := image.ParsePlatform(t.Platform)
+		if err != nil {
+			return opts, err
 		}
-		return s
+		opts.Platform = plat
+		opts.ExplicitPlatform = true
 	}
-	cyan := func(s string) string {
-		if tty {
-			return "\033[36m" + s + "\033[0m"
+	if t.Source != "" {
+		src, err := image.ParseSource(t.Source)
+		if err != nil {
+			return opts, err
 		}
-		return s
+		opts.Source = src
 	}
-	dim := func(s string) string {
-		if tty {
-			return "\033[2m" + s + "\033[0m"
-		}
-		return s
+	return opts, nil
+}
+
+// referrerFinder finds the registry artifacts attached to the image with
+// digest, or returns nil if the registry can't be reached.
+func referrerFinder(reg *image.Registries, ref, digest string) *referrers.Finder {
+	parsed, err := reg.Parse(ref)
+	if err != nil {
+		log.Printf("referrers unavailable: %v", err)
+		return nil
 	}
+	finder, err := referrers.NewFinder(parsed, digest, reg.Options()...)
+	if err != nil {
+		log.Printf("referrers unavailable: %v", err)
+		return nil
+	}
+	return finder
+}
+
+// shutdownTimeout bounds how long in-flight requests get to finish after an
+// interrupt.
+const shutdownTimeout = 5 * time.Second
 
+// interruptContext returns a context cancelled on SIGINT or SIGTERM.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// loadAndAnalyze resolves ref and analyzes the resulting image.
+func loadAndAnalyze(ctx context.Context, ref string, opts image.LoadOptions, aopts image.AnalyzeOptions) (*image.Image, error) {
+	img, res, err := image.LoadImage(ctx, ref, opts)
+	if err != nil {
+		return nil, fmt.Errorf("loading image: %w", err)
+	}
+	logResolution(ref, res)
+	analyzed, err := image.Analyze(ctx, img, ref, aopts)
+	if err != nil {
+		return nil, fmt.Errorf("analyzing image: %w", err)
+	}
+	analyzed.Info.Resolution = res
+	return analyzed, nil
+}
+
+// style wraps s in an ANSI escape sequence when stderr is a terminal.
+func style(code, s string) string {
+	if isTTY() {
+		return "\033[" + code + "m" + s + "\033[0m"
+	}
+	return s
+}
+
+func bold(s string) string { return style("1", s) }
+func cyan(s string) string { return style("36", s) }
+func dim(s string) string  { return style("2", s) }
+
+func usage() {
 	fmt.Fprintf(os.Stderr, "%s\n\n", bold("peel")+" — container image inspector")
 	fmt.Fprintf(os.Stderr, "%s\n", bold("Usage:"))
-	fmt.Fprintf(os.Stderr, "  peel <image> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "  peel <image> [flags]\n")
+	fmt.Fprintf(os.Stderr, "  peel diff <image> <[layer:]path> <[layer:]path> [flags]\n")
+	fmt.Fprintf(os.Stderr, "  peel dockerfile <image> [flags]\n")
+	fmt.Fprintf(os.Stderr, "  peel verify <image> --key <cosign.pub> [flags]\n")
+	fmt.Fprintf(os.Stderr, "  peel report <image> [-o report.html] [flags]\n\n")
 	fmt.Fprintf(os.Stderr, "%s\n", bold("Flags:"))
 	fmt.Fprintf(os.Stderr, "  %s, %s         %s\n", cyan("-p"), cyan("--port"), "port to listen on "+dim("(int, default random)"))
-	fmt.Fprintf(os.Stderr, "      %s     %s\n", cyan("--platform"), "target platform os/arch "+dim("(e.g. linux/amd64)"))
+	fmt.Fprintf(os.Stderr, "      %s         %s\n", cyan("--host"), "address to listen on "+dim("(default 127.0.0.1; 0.0.0.0 exposes peel to the network)"))
+	fmt.Fprintf(os.Stderr, "      %s   %s\n", cyan("--dockerfile"), "Dockerfile the image was built from "+dim("(links layers to lines)"))
+	fmt.Fprintf(os.Stderr, "      %s          %s\n", cyan("--key"), "cosign public key "+dim("(shows signature status)"))
+	fmt.Fprintf(os.Stderr, "      %s        %s\n", cyan("--watch"), "reload when the image is rebuilt or pushed "+dim("(checks every --watch-interval, default 2s)"))
 	fmt.Fprintf(os.Stderr, "      %s      %s\n", cyan("--no-open"), "don't auto-open browser")
 	fmt.Fprintf(os.Stderr, "  %s, %s      %s\n", cyan("-v"), cyan("--version"), "print version and exit")
+	sourceUsage()
+	registryUsage()
 }
 
 func openBrowser(url string) {
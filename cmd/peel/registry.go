@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/coffee-cup/peel/internal/config"
+	"github.com/coffee-cup/peel/internal/image"
+	"github.com/google/go-containerregistry/pkg/name"
+	flag "github.com/spf13/pflag"
+)
+
+// registryFlags are the registry access flags shared by every subcommand.
+type registryFlags struct {
+	config        string
+	username      string
+	passwordStdin bool
+	token         string
+	authConfig    string
+	insecure      []string
+	caCerts       []string
+	mirrors       []string
+}
+
+func addRegistryFlags(fs *flag.FlagSet) *registryFlags {
+	f := &registryFlags{}
+	fs.StringVar(&f.config, "config", "", "peel config file")
+	fs.StringVar(&f.username, "username", "", "registry username")
+	fs.BoolVar(&f.passwordStdin, "password-stdin", false, "read the registry password from stdin")
+	fs.StringVar(&f.token, "registry-token", "", "registry bearer token")
+	fs.StringVar(&f.authConfig, "auth-config", "", "Docker config.json to read credentials from")
+	fs.StringArrayVar(&f.insecure, "insecure-registry", nil, "registry to reach over plain HTTP or unverified TLS")
+	fs.StringArrayVar(&f.caCerts, "ca-cert", nil, "PEM CA certificate to trust")
+	fs.StringArrayVar(&f.mirrors, "registry-mirror", nil, "pull from a mirror, as registry=mirror")
+	return f
+}
+
+// registries returns how to reach ref's registry and any other. Credentials
+// given on the command line apply to ref's registry and take precedence over
+// the config file; other registries use the config file and then the Docker
+// credential store. Insecure and mirror flags add to the config file.
+func (f *registryFlags) registries(ref string) (*image.Registries, error) {
+	cfg, err := f.load(ref)
+	if err != nil {
+		return nil, err
+	}
+	return image.NewRegistries(cfg, f.authConfig, f.caCerts)
+}
+
+// load reads the config file and applies the flags to it.
+func (f *registryFlags) load(ref string) (*config.Config, error) {
+	path, required := f.config, f.config != ""
+	if !required {
+		path = config.DefaultPath()
+	}
+	cfg, err := config.Load(path, required)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.username != "" || f.passwordStdin || f.token != "" {
+		creds, err := f.credentials(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := name.ParseReference(ref)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", ref, err)
+		}
+		// Replace only the credentials; insecure, CA and mirror settings
+		// from the config file still apply
+		host := parsed.Context().RegistryStr()
+		r := cfg.Registry(host)
+		r.Username, r.Password, r.Token = creds.Username, creds.Password, creds.Token
+		cfg.Set(host, r)
+	}
+
+	for _, host := range f.insecure {
+		r := cfg.Registry(host)
+		r.Insecure = true
+		cfg.Set(host, r)
+	}
+	for _, m := range f.mirrors {
+		from, to, ok := strings.Cut(m, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid --registry-mirror %q, expected registry=mirror", m)
+		}
+		r := cfg.Registry(from)
+		r.Mirror = to
+		cfg.Set(from, r)
+	}
+	return cfg, nil
+}
+
+func (f *registryFlags) credentials(stdin io.Reader) (config.Registry, error) {
+	if f.token != "" {
+		if f.username != "" || f.passwordStdin {
+			return config.Registry{}, errors.New("--registry-token can't be combined with --username or --password-stdin")
+		}
+		return config.Registry{Token: f.token}, nil
+	}
+	if f.username == "" {
+		return config.Registry{}, errors.New("--password-stdin requires --username")
+	}
+	r := config.Registry{Username: f.username}
+	if f.passwordStdin {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return config.Registry{}, fmt.Errorf("reading password: %w", err)
+		}
+		r.Password = strings.TrimRight(string(data), "\r\n")
+	}
+	return r, nil
+}
+
+func registryUsage() {
+	fmt.Fprintf(os.Stderr, "\n%s\n", bold("Registry flags:"))
+	fmt.Fprintf(os.Stderr, "      %s         %s\n", cyan("--config"), "peel config file with per-registry credentials "+dim("(default ~/.config/peel/config.json)"))
+	fmt.Fprintf(os.Stderr, "      %s       %s\n", cyan("--username"), "username for the image's registry")
+	fmt.Fprintf(os.Stderr, "      %s %s\n", cyan("--password-stdin"), "read the password from stdin")
+	fmt.Fprintf(os.Stderr, "      %s %s\n", cyan("--registry-token"), "bearer token for the image's registry")
+	fmt.Fprintf(os.Stderr, "      %s    %s\n", cyan("--auth-config"), "Docker config.json to read credentials from "+dim("(default ~/.docker/config.json)"))
+	fmt.Fprintf(os.Stderr, "      %s %s\n", cyan("--insecure-registry"), "registry reachable over plain HTTP or unverified TLS "+dim("(repeatable)"))
+	fmt.Fprintf(os.Stderr, "      %s        %s\n", cyan("--ca-cert"), "PEM CA certificate to trust "+dim("(repeatable)"))
+	fmt.Fprintf(os.Stderr, "      %s %s\n", cyan("--registry-mirror"), "pull via a mirror, e.g. docker.io=mirror.internal "+dim("(repeatable)"))
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+)
+
+func TestRegistryFlags_CredentialsKeepConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"registries": {"registry.internal:5000": {"username": "file", "password": "secret", "insecure": true, "caCert": "ca.pem", "mirror": "mirror.internal"}}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addRegistryFlags(fs)
+	if err := fs.Parse([]string{"--config", path, "--registry-token", "abc"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := f.load("registry.internal:5000/app:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := cfg.Registry("registry.internal:5000")
+	if r.Token != "abc" || r.Username != "" || r.Password != "" {
+		t.Errorf("expected only the command-line token, got %+v", r)
+	}
+	if !r.Insecure || r.CACert != "ca.pem" || r.Mirror != "mirror.internal" {
+		t.Errorf("expected the config file's settings to be kept, got %+v", r)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/coffee-cup/peel/internal/embed"
+	"github.com/coffee-cup/peel/internal/server"
+	flag "github.com/spf13/pflag"
+)
+
+// runReport implements `peel report`: write a self-contained HTML file with
+// the UI and the image's layers, diffs and metadata, browsable without peel.
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	fs.Usage = reportUsage
+	output := fs.StringP("output", "o", "report.html", "file to write")
+	dockerfilePath := fs.String("dockerfile", "", "Dockerfile the image was built from")
+	registry := addRegistryFlags(fs)
+	source := addSourceFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		reportUsage()
+		os.Exit(2)
+	}
+	ref := fs.Arg(0)
+
+	reg, err := registry.registries(ref)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts, err := source.options(reg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	img, err := loadAndAnalyze(ctx, ref, opts, source.analyzeOptions())
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The report is rendered from the same handlers the server uses
+	srv := server.New(ref)
+	srv.SetImage(img)
+	if *dockerfilePath != "" {
+		content, err := os.ReadFile(*dockerfilePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := srv.SetDockerfile(*dockerfilePath, content); err != nil {
+			log.Fatal(err)
+		}
+	}
+	snap, err := srv.Snapshot(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	html, err := embed.Report(snap)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, html, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	size := fmt.Sprintf("(%d KB)", len(html)>>10)
+	if len(html) >= 1<<20 {
+		size = fmt.Sprintf("(%.1f MB)", float64(len(html))/(1<<20))
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s %s\n", *output, dim(size))
+}
+
+func reportUsage() {
+	fmt.Fprintf(os.Stderr, "%s\n", bold("Usage:"))
+	fmt.Fprintf(os.Stderr, "  peel report <image> [-o report.html] [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Writes a self-contained HTML report with the image's layers, diffs, metadata and sizes,\n")
+	fmt.Fprintf(os.Stderr, "browsable offline. File contents are not included.\n\n")
+	fmt.Fprintf(os.Stderr, "%s\n", bold("Flags:"))
+	fmt.Fprintf(os.Stderr, "  %s, %s       %s\n", cyan("-o"), cyan("--output"), "file to write "+dim("(default report.html)"))
+	fmt.Fprintf(os.Stderr, "      %s   %s\n", cyan("--dockerfile"), "Dockerfile the image was built from "+dim("(links layers to lines)"))
+	sourceUsage()
+	registryUsage()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/coffee-cup/peel/internal/image"
+	flag "github.com/spf13/pflag"
+)
+
+// sourceFlags select where images are loaded from and for which platform.
+type sourceFlags struct {
+	platform       string
+	source         string
+	containerdRoot string
+	offline        bool
+	jobs           int
+}
+
+func addSourceFlags(fs *flag.FlagSet) *sourceFlags {
+	f := &sourceFlags{}
+	fs.StringVar(&f.platform, "platform", "", "target platform os/arch[/variant]")
+	fs.StringVar(&f.source, "source", "", "where to load the image from")
+	fs.StringVar(&f.containerdRoot, "containerd-root", "", "containerd content store directory")
+	fs.BoolVar(&f.offline, "offline", false, "never contact a registry")
+	fs.IntVarP(&f.jobs, "jobs", "j", 0, "layers to download and read at once")
+	return f
+}
+
+// options combines the source flags with the registries.
+func (f *sourceFlags) options(reg *image.Registries) (image.LoadOptions, error) {
+	plat, err := image.ParsePlatform(f.platform)
+	if err != nil {
+		return image.LoadOptions{}, err
+	}
+	src, err := image.ParseSource(f.source)
+	if err != nil {
+		return image.LoadOptions{}, err
+	}
+	return image.LoadOptions{
+		Platform:         plat,
+		Registries:       reg,
+		Source:           src,
+		ExplicitPlatform: f.platform != "",
+		Offline:          f.offline,
+		ContainerdRoot:   f.containerdRoot,
+	}, nil
+}
+
+// analyzeOptions returns how loaded images are analyzed.
+func (f *sourceFlags) analyzeOptions() image.AnalyzeOptions {
+	return image.AnalyzeOptions{Jobs: f.jobs}
+}
+
+// logResolution reports which image was loaded and why.
+func logResolution(ref string, res *image.Resolution) {
+	log.Printf("resolved %s to %s from %s: %s", ref, res.Platform, res.Source, res.Reason)
+}
+
+func sourceUsage() {
+	fmt.Fprintf(os.Stderr, "\n%s\n", bold("Source flags:"))
+	fmt.Fprintf(os.Stderr, "      %s        %s\n", cyan("--platform"), "target platform os/arch[/variant] "+dim("(default host; local images of another platform are used as is)"))
+	fmt.Fprintf(os.Stderr, "      %s          %s\n", cyan("--source"), "auto, docker, podman, containerd, oci or remote "+dim("(default auto: Docker, then registry)"))
+	fmt.Fprintf(os.Stderr, "      %s %s\n", cyan("--containerd-root"), "containerd content store "+dim("(default /var/lib/containerd/io.containerd.content.v1.content)"))
+	fmt.Fprintf(os.Stderr, "      %s         %s\n", cyan("--offline"), "never contact a registry; fail instead of pulling")
+	fmt.Fprintf(os.Stderr, "  %s, %s          %s\n", cyan("-j"), cyan("--jobs"), "layers to download and read at once "+dim("(default one per CPU)"))
+	fmt.Fprintf(os.Stderr, "\n  %s\n", dim("podman reads $CONTAINER_HOST or the Podman socket; containerd needs name@sha256:... or sha256:...;"))
+	fmt.Fprintf(os.Stderr, "  %s\n", dim("oci takes an OCI layout directory or image tarball, optionally as path:name."))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/coffee-cup/peel/internal/image"
+	"github.com/coffee-cup/peel/internal/referrers"
+	flag "github.com/spf13/pflag"
+)
+
+// runVerify implements `peel verify`: check the image's cosign signatures
+// against a public key. Exits 1 unless at least one signature verifies.
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Usage = verifyUsage
+	keyPath := fs.String("key", "", "cosign public key")
+	registry := addRegistryFlags(fs)
+	source := addSourceFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *keyPath == "" {
+		verifyUsage()
+		os.Exit(2)
+	}
+	ref := fs.Arg(0)
+
+	pub, err := loadKey(*keyPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reg, err := registry.registries(ref)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts, err := source.options(reg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if opts.Offline || opts.Source == image.SourceOCI {
+		log.Fatal("verify looks up signatures in the registry, so it can't run with --offline or --source oci")
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	img, res, err := image.LoadImage(ctx, ref, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logResolution(ref, res)
+	digest, err := img.Digest()
+	if err != nil {
+		log.Fatal(err)
+	}
+	parsed, err := reg.Parse(ref)
+	if err != nil {
+		log.Fatal(err)
+	}
+	finder, err := referrers.NewFinder(parsed, digest.String(), reg.Options()...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	results, err := finder.Verify(ctx, pub)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	verified := 0
+	for _, r := range results {
+		if r.Verified {
+			verified++
+			fmt.Printf("%s %s signed by key %s\n", cyan("✓"), r.Subject, dim("("+r.Identity+")"))
+		} else {
+			fmt.Printf("%s %s %s\n", bold("✗"), r.Subject, dim(r.Error))
+		}
+	}
+	if verified == 0 {
+		if len(results) == 0 {
+			fmt.Fprintf(os.Stderr, "no cosign signatures found for %s\n", ref)
+		}
+		os.Exit(1)
+	}
+}
+
+// loadKey reads a PEM public key from path.
+func loadKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := referrers.LoadPublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pub, nil
+}
+
+func verifyUsage() {
+	fmt.Fprintf(os.Stderr, "%s\n", bold("Usage:"))
+	fmt.Fprintf(os.Stderr, "  peel verify <image> --key <cosign.pub> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Verifies key-based cosign signatures against the image digest, without contacting a transparency log.\n")
+	fmt.Fprintf(os.Stderr, "Exits 1 unless at least one signature verifies.\n\n")
+	fmt.Fprintf(os.Stderr, "%s\n", bold("Flags:"))
+	fmt.Fprintf(os.Stderr, "      %s        %s\n", cyan("--key"), "PEM public key from cosign generate-key-pair")
+	sourceUsage()
+	registryUsage()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/coffee-cup/peel/internal/image"
+	"github.com/coffee-cup/peel/internal/server"
+)
+
+// watchImage polls what the server's image resolves to and reloads it when
+// it changes, e.g. after a rebuild or a push to the same tag. It follows
+// images opened from the UI. A failed reload is retried on the next tick.
+func watchImage(ctx context.Context, srv *server.Server, opts image.LoadOptions, interval time.Duration) {
+	target := srv.Target()
+	last := fingerprint(ctx, target, opts)
+	log.Printf("watching %s every %s", target.Ref, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if t := srv.Target(); t != target {
+			target = t
+			last = fingerprint(ctx, target, opts)
+			log.Printf("watching %s every %s", target.Ref, interval)
+			continue
+		}
+		current := fingerprint(ctx, target, opts)
+		if current == "" || current == last {
+			continue
+		}
+		log.Printf("%s changed, reloading", target.Ref)
+		if err := srv.Reload(ctx); err != nil {
+			continue
+		}
+		last = current
+	}
+}
+
+// fingerprint returns what t resolves to, or "" if it can't be resolved.
+func fingerprint(ctx context.Context, t server.Target, opts image.LoadOptions) string {
+	topts, err := targetOptions(opts, t)
+	if err != nil {
+		return ""
+	}
+	f, err := image.Fingerprint(ctx, t.Ref, topts)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("watch: %v", err)
+	}
+	return f
+}
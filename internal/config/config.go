@@ -0,0 +1,93 @@
+// Package config reads peel's config file, which holds per-registry
+// settings such as credentials.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Config is the contents of the config file.
+type Config struct {
+	// Registries is keyed by registry host, e.g. "ghcr.io" or "localhost:5000"
+	Registries map[string]Registry `json:"registries"`
+}
+
+// Registry holds settings for one registry.
+type Registry struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"` // bearer token, used instead of username/password
+
+	Insecure bool   `json:"insecure,omitempty"` // allow plain HTTP and unverified TLS
+	CACert   string `json:"caCert,omitempty"`   // PEM file trusted for this registry
+	Mirror   string `json:"mirror,omitempty"`   // registry host tried before this one
+}
+
+// HasCredentials reports whether r carries any credentials.
+func (r Registry) HasCredentials() bool {
+	return r.Username != "" || r.Password != "" || r.Token != ""
+}
+
+// DefaultPath returns $PEEL_CONFIG, or config.json under the user config
+// directory (e.g. ~/.config/peel/config.json).
+func DefaultPath() string {
+	if p := os.Getenv("PEEL_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "peel", "config.json")
+}
+
+// Load reads the config file at path. A missing file yields an empty config
+// unless required is set, for paths the user named explicitly.
+func Load(path string, required bool) (*Config, error) {
+	cfg := &Config{Registries: map[string]Registry{}}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file Config
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for host, r := range file.Registries {
+		cfg.Set(host, r)
+	}
+	return cfg, nil
+}
+
+// Set replaces the settings for host.
+func (c *Config) Set(host string, r Registry) {
+	c.Registries[RegistryKey(host)] = r
+}
+
+// Registry returns the settings for host.
+func (c *Config) Registry(host string) Registry {
+	return c.Registries[RegistryKey(host)]
+}
+
+// RegistryKey normalizes a registry host so "docker.io" and
+// "index.docker.io" name the same registry.
+func RegistryKey(host string) string {
+	reg, err := name.NewRegistry(host)
+	if err != nil {
+		return host
+	}
+	return reg.RegistryStr()
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"registries": {"docker.io": {"username": "robot", "password": "secret"}, "ghcr.io": {"token": "abc"}}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := cfg.Registry("index.docker.io"); r.Username != "robot" || r.Password != "secret" {
+		t.Errorf("expected docker.io credentials under index.docker.io, got %+v", r)
+	}
+	if r := cfg.Registry("ghcr.io"); r.Token != "abc" {
+		t.Errorf("unexpected ghcr.io settings: %+v", r)
+	}
+	if cfg.Registry("quay.io").HasCredentials() {
+		t.Error("expected no credentials for an unlisted registry")
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.json")
+	cfg, err := Load(path, false)
+	if err != nil || len(cfg.Registries) != 0 {
+		t.Errorf("expected empty config, got %+v, %v", cfg, err)
+	}
+	if _, err := Load(path, true); err == nil {
+		t.Error("expected error for a missing required config")
+	}
+}
@@ -0,0 +1,213 @@
+package dockerfile
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// Match ties an image layer to the Dockerfile instruction that produced it.
+type Match struct {
+	Layer       int    `json:"layer"`
+	Line        int    `json:"line"`
+	EndLine     int    `json:"endLine"`
+	Stage       string `json:"stage"`
+	Instruction string `json:"instruction"` // as written, continuations joined
+	// Exact is false when only the keyword agrees with the layer's history,
+	// e.g. the Dockerfile changed since the image was built.
+	Exact bool `json:"exact"`
+	// FromStage and FromLine locate the stage a COPY --from copies out of.
+	FromStage string `json:"fromStage,omitempty"`
+	FromLine  int    `json:"fromLine,omitempty"`
+}
+
+// Alignment is the result of correlating an image's layers with a Dockerfile.
+type Alignment struct {
+	Matches []Match `json:"matches"`
+	// Unmatched lists instructions of the built stage that no layer accounts for.
+	// ARG is never reported since BuildKit doesn't record it in history.
+	Unmatched []Instruction `json:"unmatched"`
+	// Extra lists layers after the first matched one that no instruction accounts for.
+	Extra []int `json:"extra"`
+}
+
+// Align correlates layer instructions (as returned by Normalize, one per
+// layer) with the instructions that build the file's last stage. Layers
+// from an external base image precede the first match and are left
+// unmatched. The alignment maximizes agreement, preferring later layers on
+// ties so the Dockerfile anchors to the top of the image.
+func Align(f *File, layers []string) Alignment {
+	target := len(f.Stages) - 1
+	chain := f.chain(target)
+
+	n, m := len(layers), len(chain)
+	score := make([][]int, n+1)
+	for i := range score {
+		score[i] = make([]int, m+1)
+	}
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			best := max(score[i-1][j], score[i][j-1])
+			if s := matchScore(chain[j-1], layers[i-1]); s > 0 {
+				best = max(best, score[i-1][j-1]+s)
+			}
+			score[i][j] = best
+		}
+	}
+
+	a := Alignment{Matches: []Match{}, Unmatched: []Instruction{}, Extra: []int{}}
+	matched := make([]bool, m)
+	first := n
+	for i, j := n, m; i > 0 && j > 0; {
+		s := matchScore(chain[j-1], layers[i-1])
+		switch {
+		case s > 0 && score[i][j] == score[i-1][j-1]+s:
+			a.Matches = append(a.Matches, f.match(i-1, chain[j-1], s == exactScore))
+			matched[j-1] = true
+			first = i - 1
+			i--
+			j--
+		case score[i][j] == score[i-1][j]:
+			i--
+		default:
+			j--
+		}
+	}
+	slices.Reverse(a.Matches)
+
+	for j, in := range chain {
+		if !matched[j] && in.Keyword != "ARG" {
+			a.Unmatched = append(a.Unmatched, in)
+		}
+	}
+	next := 0
+	for i := first; i < n; i++ {
+		if next < len(a.Matches) && a.Matches[next].Layer == i {
+			next++
+			continue
+		}
+		a.Extra = append(a.Extra, i)
+	}
+	return a
+}
+
+func (f *File) match(layer int, in Instruction, exact bool) Match {
+	m := Match{
+		Layer:       layer,
+		Line:        in.Line,
+		EndLine:     in.EndLine,
+		Stage:       f.Stages[in.Stage].StageName(),
+		Instruction: in.String(),
+		Exact:       exact,
+	}
+	if from := in.Flag("from"); from != "" && in.Keyword == "COPY" {
+		if st, ok := f.Stage(from, in.Stage); ok {
+			m.FromStage = st.StageName()
+			m.FromLine = st.Line
+		}
+	}
+	return m
+}
+
+const (
+	keywordScore = 1
+	exactScore   = 3
+)
+
+// matchScore rates how well a written instruction explains a layer's
+// normalized history: 0 for different keywords, keywordScore when only the
+// keyword agrees and exactScore when the arguments are equivalent too.
+func matchScore(in Instruction, layer string) int {
+	keyword, args, _ := strings.Cut(layer, " ")
+	if keyword != in.Keyword {
+		return 0
+	}
+	if equivalent(in.Keyword, in.Args, args) {
+		return exactScore
+	}
+	return keywordScore
+}
+
+// equivalent compares written arguments with those recovered from history,
+// allowing for the rewrites builders make when recording them.
+func equivalent(keyword, written, recorded string) bool {
+	switch keyword {
+	case "RUN":
+		written, recorded = stripFlags(written), stripFlags(recorded)
+		if list, ok := execForm(written); ok {
+			written = strings.Join(list, " ")
+		}
+	case "CMD", "ENTRYPOINT":
+		w, _ := execForm(written)
+		r, ok := execForm(recorded)
+		if !ok {
+			break
+		}
+		if w == nil {
+			w = []string{"/bin/sh", "-c", written}
+		}
+		return slices.Equal(w, r) || (len(r) == 3 && r[2] == written)
+	case "ADD", "COPY":
+		// The classic builder records a content hash instead of the sources
+		if isContentAddressed(recorded) {
+			return lastField(written) == lastField(recorded)
+		}
+	case "EXPOSE":
+		return slices.Equal(ports(written), ports(recorded))
+	}
+	return collapse(written) == collapse(recorded)
+}
+
+func isContentAddressed(args string) bool {
+	for _, f := range strings.Fields(args) {
+		if strings.HasPrefix(f, "--") {
+			continue
+		}
+		return strings.HasPrefix(f, "file:") || strings.HasPrefix(f, "dir:") || strings.HasPrefix(f, "multi:")
+	}
+	return false
+}
+
+func execForm(s string) ([]string, bool) {
+	var list []string
+	if err := json.Unmarshal([]byte(s), &list); err != nil {
+		return nil, false
+	}
+	return list, true
+}
+
+func stripFlags(s string) string {
+	s = strings.TrimSpace(s)
+	for strings.HasPrefix(s, "--") {
+		_, s, _ = strings.Cut(s, " ")
+		s = strings.TrimSpace(s)
+	}
+	return s
+}
+
+func ports(s string) []string {
+	var out []string
+	for _, p := range strings.Fields(s) {
+		if !strings.Contains(p, "/") {
+			p += "/tcp"
+		}
+		out = append(out, p)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func lastField(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// collapse normalizes whitespace and quoting so "ENV A=\"b c\"" and
+// "ENV A=b c" compare equal.
+func collapse(s string) string {
+	s = strings.NewReplacer(`"`, "", `'`, "").Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
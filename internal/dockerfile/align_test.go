@@ -0,0 +1,69 @@
+package dockerfile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAlign(t *testing.T) {
+	f, err := Parse(strings.NewReader(multiStage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := []string{
+		"ADD file:3b6a7bd2c1f0e9a8 /", // base image
+		`CMD ["/bin/sh"]`,
+		"RUN apk add --no-cache ca-certificates curl && adduser -D app", // edited since the build
+		"COPY --from=builder /out/app /usr/local/bin/app",
+		"LABEL org.example=1", // not in the Dockerfile
+		"USER app",
+		`ENTRYPOINT ["app"]`,
+	}
+	a := Align(f, layers)
+
+	byLayer := map[int]Match{}
+	for _, m := range a.Matches {
+		byLayer[m.Layer] = m
+	}
+	if _, ok := byLayer[0]; ok {
+		t.Error("base image layer should not match")
+	}
+	if m := byLayer[2]; m.Line != 15 || m.EndLine != 16 || m.Exact {
+		t.Errorf("expected inexact match to line 15, got %+v", m)
+	}
+	if m := byLayer[3]; m.Line != 17 || !m.Exact || m.FromStage != "builder" || m.FromLine != 4 {
+		t.Errorf("unexpected COPY --from match: %+v", m)
+	}
+	if m := byLayer[6]; m.Line != 22 || !m.Exact || m.Stage != "1" {
+		t.Errorf("unexpected ENTRYPOINT match: %+v", m)
+	}
+
+	if len(a.Unmatched) != 1 || a.Unmatched[0].Line != 18 {
+		t.Errorf("expected the heredoc RUN unmatched, got %+v", a.Unmatched)
+	}
+	if len(a.Extra) != 1 || a.Extra[0] != 4 {
+		t.Errorf("expected layer 4 extra, got %v", a.Extra)
+	}
+}
+
+func TestEquivalent(t *testing.T) {
+	tests := []struct {
+		keyword, written, recorded string
+		want                       bool
+	}{
+		{"RUN", "--mount=type=cache,target=/root/.cache go build", "go build", true},
+		{"RUN", `["make", "install"]`, "make install", true},
+		{"CMD", "node server.js", `["/bin/sh", "-c", "node server.js"]`, true},
+		{"CMD", `["node","server.js"]`, `["node", "server.js"]`, true},
+		{"COPY", "--chown=app . /app", "file:0a1b2c /app", true},
+		{"COPY", ". /app", "file:0a1b2c /srv", false},
+		{"EXPOSE", "8080 53/udp", "53/udp 8080/tcp", true},
+		{"ENV", `GREETING="hello world"`, "GREETING=hello world", true},
+		{"WORKDIR", "/app", "/src", false},
+	}
+	for _, tt := range tests {
+		if got := equivalent(tt.keyword, tt.written, tt.recorded); got != tt.want {
+			t.Errorf("equivalent(%s, %q, %q) = %v, want %v", tt.keyword, tt.written, tt.recorded, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Package dockerfile turns image history entries back into Dockerfile
+// instructions.
+package dockerfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// buildkitSuffix is appended by BuildKit to every CreatedBy it writes.
+const buildkitSuffix = "# buildkit"
+
+// nopMarker prefixes metadata-only instructions in classic builder history.
+const nopMarker = "#(nop)"
+
+// shellPrefixes are the default shells that wrap shell-form RUN commands.
+var shellPrefixes = []string{"/bin/sh -c ", "cmd /S /C "}
+
+var instructions = map[string]bool{
+	"ADD": true, "ARG": true, "CMD": true, "COPY": true, "ENTRYPOINT": true,
+	"ENV": true, "EXPOSE": true, "FROM": true, "HEALTHCHECK": true, "LABEL": true,
+	"MAINTAINER": true, "ONBUILD": true, "RUN": true, "SHELL": true,
+	"STOPSIGNAL": true, "USER": true, "VOLUME": true, "WORKDIR": true,
+}
+
+// classicSource matches the content-addressed sources the classic builder
+// records for ADD and COPY, e.g. "file:3b6a... in /".
+var classicSource = regexp.MustCompile(`^((?:--\S+\s+)*(?:file|dir|multi):[0-9a-f]+) in (.*)$`)
+
+// Normalize converts a history CreatedBy string into a Dockerfile
+// instruction. Strings it doesn't recognize are returned trimmed but
+// otherwise unchanged; use IsInstruction to tell the two apart.
+func Normalize(createdBy string) string {
+	s := strings.TrimSpace(createdBy)
+	s = strings.TrimSpace(strings.TrimSuffix(s, buildkitSuffix))
+	if s == "" {
+		return ""
+	}
+
+	// BuildKit writes RUN explicitly but keeps the shell and build args
+	if rest, ok := strings.CutPrefix(s, "RUN "); ok {
+		return "RUN " + stripShell(stripBuildArgs(rest))
+	}
+
+	if rest, ok := cutShell(stripBuildArgs(s)); ok {
+		if inst, ok := strings.CutPrefix(rest, nopMarker); ok {
+			return normalizeInstruction(strings.TrimSpace(inst))
+		}
+		return "RUN " + rest
+	}
+
+	if IsInstruction(s) {
+		return normalizeInstruction(s)
+	}
+	return s
+}
+
+// IsInstruction reports whether line starts with a Dockerfile keyword.
+func IsInstruction(line string) bool {
+	keyword, _, _ := strings.Cut(line, " ")
+	return instructions[strings.ToUpper(keyword)]
+}
+
+// Format renders instructions as a Dockerfile, one per layer. Long RUN
+// chains are split on top-level && for readability, entries that aren't
+// instructions are kept as comments and empty ones are noted as missing
+// history.
+func Format(lines []string) string {
+	var b strings.Builder
+	for _, line := range lines {
+		switch {
+		case line == "":
+			b.WriteString("# (no build history)\n")
+		case !IsInstruction(line):
+			b.WriteString("# " + strings.ReplaceAll(line, "\n", "\n# ") + "\n")
+		case strings.HasPrefix(line, "RUN "):
+			b.WriteString(strings.Join(splitChain(line), " \\\n    && ") + "\n")
+		default:
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// splitChain splits a shell command on " && ", except inside quotes or
+// parentheses, where the && belongs to a quoted string or a subshell.
+func splitChain(cmd string) []string {
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\':
+			i++ // escaped, also inside double quotes
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth = max(depth-1, 0)
+		case depth == 0 && strings.HasPrefix(cmd[i:], " && "):
+			parts = append(parts, cmd[start:i])
+			start = i + len(" && ")
+			i = start - 1
+		}
+	}
+	return append(parts, cmd[start:])
+}
+
+// stripBuildArgs removes the "|N KEY=value ..." prefix recorded for RUN
+// commands executed with build args in scope.
+func stripBuildArgs(s string) string {
+	if !strings.HasPrefix(s, "|") {
+		return s
+	}
+	countStr, rest, ok := strings.Cut(s[1:], " ")
+	if !ok {
+		return s
+	}
+	n, err := strconv.Atoi(countStr)
+	if err != nil {
+		return s
+	}
+	// Arg values may contain spaces, so prefer finding the shell over counting fields
+	for _, p := range shellPrefixes {
+		if i := strings.Index(rest, p); i >= 0 {
+			return rest[i:]
+		}
+	}
+	for range n {
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	return rest
+}
+
+func cutShell(s string) (string, bool) {
+	for _, p := range shellPrefixes {
+		if rest, ok := strings.CutPrefix(s, p); ok {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return s, false
+}
+
+func stripShell(s string) string {
+	rest, _ := cutShell(s)
+	return rest
+}
+
+// normalizeInstruction rewrites the Go-formatted arguments builders store in
+// history back into Dockerfile syntax.
+func normalizeInstruction(s string) string {
+	keyword, args, _ := strings.Cut(s, " ")
+	keyword = strings.ToUpper(keyword)
+	args = strings.TrimSpace(args)
+
+	switch keyword {
+	case "CMD", "ENTRYPOINT", "SHELL":
+		if list, ok := parseQuotedList(args); ok {
+			args = jsonArray(list)
+		} else if inner, ok := cutBrackets(args, "["); ok && keyword == "SHELL" {
+			// The classic builder records SHELL unquoted: [/bin/bash -o pipefail -c].
+			// SHELL only takes exec form, so the brackets can't be a shell test.
+			args = jsonArray(strings.Fields(inner))
+		}
+	case "EXPOSE":
+		// map[80/tcp:{} 443/tcp:{}]
+		if inner, ok := cutBrackets(args, "map["); ok {
+			var ports []string
+			for _, f := range strings.Fields(inner) {
+				ports = append(ports, strings.TrimSuffix(f, ":{}"))
+			}
+			args = strings.Join(ports, " ")
+		}
+	case "VOLUME":
+		// [/data /logs]
+		if inner, ok := cutBrackets(args, "["); ok {
+			args = strings.Join(strings.Fields(inner), " ")
+		}
+	case "ADD", "COPY":
+		if m := classicSource.FindStringSubmatch(args); m != nil {
+			args = m[1] + " " + m[2]
+		}
+	case "HEALTHCHECK":
+		args = normalizeHealthcheck(args)
+	}
+
+	if args == "" {
+		return keyword
+	}
+	return keyword + " " + args
+}
+
+// normalizeHealthcheck handles the %q form of a container.HealthConfig,
+// &{["CMD-SHELL" "curl -f localhost"] "30s" "3s" "0s" "0s" '\x03'}, emitting
+// the test plus any non-default durations and retries.
+func normalizeHealthcheck(args string) string {
+	inner, ok := cutBrackets(args, "&{")
+	if !ok {
+		return args
+	}
+	test, rest, ok := cutQuotedList(inner)
+	if !ok || len(test) == 0 {
+		return args
+	}
+
+	// Durations are double-quoted in field order; retries is a quoted rune
+	var flags []string
+	durations := []string{"interval", "timeout", "start-period", "start-interval"}
+	for rest != "" {
+		isRune := rest[0] == '\''
+		v, tail, err := quotedPrefix(rest)
+		if err != nil {
+			break
+		}
+		rest = strings.TrimSpace(tail)
+		switch {
+		case isRune:
+			if r := []rune(v); len(r) == 1 && r[0] != 0 {
+				flags = append(flags, "--retries="+strconv.Itoa(int(r[0])))
+			}
+		case len(durations) > 0:
+			if v != "0s" && v != "" {
+				flags = append(flags, "--"+durations[0]+"="+v)
+			}
+			durations = durations[1:]
+		}
+	}
+
+	var cmd string
+	switch test[0] {
+	case "NONE":
+		return "NONE"
+	case "CMD-SHELL":
+		cmd = "CMD " + strings.Join(test[1:], " ")
+	case "CMD":
+		cmd = "CMD " + jsonArray(test[1:])
+	default:
+		return args
+	}
+	return strings.Join(append(flags, cmd), " ")
+}
+
+// parseQuotedList parses a Go-formatted string slice such as
+// ["nginx" "-g" "daemon off;"].
+func parseQuotedList(s string) ([]string, bool) {
+	list, rest, ok := cutQuotedList(s)
+	return list, ok && rest == ""
+}
+
+// cutQuotedList parses a quoted string slice at the start of s and returns
+// the remainder after its closing bracket.
+func cutQuotedList(s string) (list []string, rest string, ok bool) {
+	rest, ok = strings.CutPrefix(s, "[")
+	if !ok {
+		return nil, s, false
+	}
+	list = []string{}
+	for {
+		rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ","))
+		if tail, ok := strings.CutPrefix(rest, "]"); ok {
+			return list, strings.TrimSpace(tail), true
+		}
+		v, tail, err := quotedPrefix(rest)
+		if err != nil {
+			return nil, s, false
+		}
+		list = append(list, v)
+		rest = tail
+	}
+}
+
+func quotedPrefix(s string) (value, rest string, err error) {
+	q, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return "", s, err
+	}
+	value, err = strconv.Unquote(q)
+	return value, s[len(q):], err
+}
+
+func cutBrackets(s, open string) (string, bool) {
+	closing := "]"
+	if strings.HasSuffix(open, "{") {
+		closing = "}"
+	}
+	if !strings.HasPrefix(s, open) || !strings.HasSuffix(s, closing) {
+		return "", false
+	}
+	return s[len(open) : len(s)-len(closing)], true
+}
+
+// jsonArray renders list in exec form, spaced the way people write it.
+func jsonArray(list []string) string {
+	quoted := make([]string, len(list))
+	for i, v := range list {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.Encode(v)
+		quoted[i] = strings.TrimSpace(buf.String())
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,73 @@
+package dockerfile
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		// Classic builder
+		{`/bin/sh -c #(nop)  CMD ["nginx" "-g" "daemon off;"]`, `CMD ["nginx", "-g", "daemon off;"]`},
+		{`/bin/sh -c #(nop) ADD file:3b6a7bd2c1f0e9a8 in / `, `ADD file:3b6a7bd2c1f0e9a8 /`},
+		{`/bin/sh -c #(nop) COPY dir:0a1b2c in /app `, `COPY dir:0a1b2c /app`},
+		{`/bin/sh -c #(nop)  EXPOSE map[80/tcp:{} 443/tcp:{}]`, `EXPOSE 80/tcp 443/tcp`},
+		{`/bin/sh -c #(nop)  VOLUME [/var/lib/mysql]`, `VOLUME /var/lib/mysql`},
+		{`/bin/sh -c #(nop) WORKDIR /app`, `WORKDIR /app`},
+		{`/bin/sh -c #(nop)  ENV PATH=/usr/local/bin:/usr/bin`, `ENV PATH=/usr/local/bin:/usr/bin`},
+		{`/bin/sh -c apt-get update && apt-get install -y curl`, `RUN apt-get update && apt-get install -y curl`},
+		{`|2 VERSION=1.2 NAME=a b /bin/sh -c make install`, `RUN make install`},
+
+		// BuildKit
+		{`RUN /bin/sh -c apk add --no-cache git # buildkit`, `RUN apk add --no-cache git`},
+		{`RUN |1 TARGETARCH=arm64 /bin/sh -c go build ./... # buildkit`, `RUN go build ./...`},
+		{`COPY --from=builder /out/app /usr/local/bin/ # buildkit`, `COPY --from=builder /out/app /usr/local/bin/`},
+		{`ENTRYPOINT ["docker-entrypoint.sh"]`, `ENTRYPOINT ["docker-entrypoint.sh"]`},
+		{`CMD ["node" "server.js"]`, `CMD ["node", "server.js"]`},
+		{`SHELL [/bin/bash -o pipefail -c]`, `SHELL ["/bin/bash", "-o", "pipefail", "-c"]`},
+		{`/bin/sh -c #(nop)  SHELL [/bin/bash -o pipefail -c]`, `SHELL ["/bin/bash", "-o", "pipefail", "-c"]`},
+		{`SHELL ["powershell" "-Command"]`, `SHELL ["powershell", "-Command"]`},
+		{`RUN /bin/bash -o pipefail -c curl -fsSL x | sh # buildkit`, `RUN /bin/bash -o pipefail -c curl -fsSL x | sh`},
+
+		{`HEALTHCHECK &{["CMD-SHELL" "curl -f http://localhost/ || exit 1"] "30s" "3s" "0s" "0s" '\x03'}`,
+			`HEALTHCHECK --interval=30s --timeout=3s --retries=3 CMD curl -f http://localhost/ || exit 1`},
+		{`HEALTHCHECK &{["CMD" "/bin/check" "[ok]"] "0s" "0s" "0s" "0s" '\x00'}`,
+			`HEALTHCHECK CMD ["/bin/check", "[ok]"]`},
+		{`HEALTHCHECK &{["NONE"] "0s" "0s" "0s" "0s" '\x00'}`, `HEALTHCHECK NONE`},
+
+		// Not instructions
+		{``, ``},
+		{`  `, ``},
+		{`bazel build //app:image`, `bazel build //app:image`},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	got := Format([]string{
+		"ADD file:abc /",
+		"",
+		"RUN apt-get update && apt-get install -y curl",
+		"apko",
+		`CMD ["bash"]`,
+		`RUN sh -c 'a && b' && echo "x && y" && (cd /src && make) && echo \"z && w`,
+	})
+	want := `ADD file:abc /
+# (no build history)
+RUN apt-get update \
+    && apt-get install -y curl
+# apko
+CMD ["bash"]
+RUN sh -c 'a && b' \
+    && echo "x && y" \
+    && (cd /src && make) \
+    && echo \"z \
+    && w
+`
+	if got != want {
+		t.Fatalf("unexpected Dockerfile:\n%s\nwant:\n%s", got, want)
+	}
+}
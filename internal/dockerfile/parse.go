@@ -0,0 +1,209 @@
+package dockerfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// File is a parsed Dockerfile.
+type File struct {
+	Stages []Stage `json:"stages"`
+}
+
+// Stage is one FROM and the instructions that follow it.
+type Stage struct {
+	Index        int           `json:"index"`
+	Name         string        `json:"name,omitempty"` // from FROM ... AS name
+	Base         string        `json:"base"`
+	Line         int           `json:"line"`
+	Instructions []Instruction `json:"instructions"`
+}
+
+// Instruction is one instruction as written, with continuations joined.
+type Instruction struct {
+	Keyword string `json:"keyword"` // upper-cased
+	Args    string `json:"args"`
+	Line    int    `json:"line"`    // 1-based line the instruction starts on
+	EndLine int    `json:"endLine"` // last line, including continuations and heredocs
+	Stage   int    `json:"stage"`
+}
+
+// String returns the instruction as a single line.
+func (in Instruction) String() string {
+	if in.Args == "" {
+		return in.Keyword
+	}
+	return in.Keyword + " " + in.Args
+}
+
+// Flag returns the value of a --name=value flag, or "" if absent.
+func (in Instruction) Flag(name string) string {
+	for _, f := range strings.Fields(in.Args) {
+		if !strings.HasPrefix(f, "--") {
+			break
+		}
+		if v, ok := strings.CutPrefix(f, "--"+name+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// StageName returns a stage's name, or its index when unnamed.
+func (s Stage) StageName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return strconv.Itoa(s.Index)
+}
+
+var (
+	escapeDirective = regexp.MustCompile(`^#\s*escape\s*=\s*(\S)\s*$`)
+	heredocStart    = regexp.MustCompile(`(?:^|[^<])<<(-?)["']?([A-Za-z_][A-Za-z0-9_]*)["']?`)
+)
+
+// Parse reads a Dockerfile. Instructions before the first FROM (global ARGs)
+// are skipped, as they never produce layers.
+func Parse(r io.Reader) (*File, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+
+	var lines []string
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	escape := `\`
+	f := &File{}
+	directives := true
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			directives = false
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if directives {
+				if m := escapeDirective.FindStringSubmatch(line); m != nil {
+					escape = m[1]
+				}
+			}
+			continue
+		}
+		directives = false
+
+		start := i
+		text := line
+		for strings.HasSuffix(text, escape) && i+1 < len(lines) {
+			text = strings.TrimSpace(strings.TrimSuffix(text, escape))
+			i++
+			next := strings.TrimSpace(lines[i])
+			// Comments and blank lines inside a continuation are dropped
+			if next == "" || strings.HasPrefix(next, "#") {
+				text += escape
+				continue
+			}
+			text += " " + next
+		}
+		text = strings.TrimSuffix(text, escape)
+
+		keyword, _, _ := strings.Cut(text, " ")
+		keyword = strings.ToUpper(keyword)
+
+		// Heredoc bodies follow the instruction line verbatim
+		var heredocs [][]string
+		if keyword == "RUN" || keyword == "COPY" || keyword == "ADD" {
+			heredocs = heredocStart.FindAllStringSubmatch(text, -1)
+		}
+		for _, m := range heredocs {
+			var body []string
+			for i+1 < len(lines) {
+				i++
+				end := lines[i]
+				if m[1] == "-" {
+					end = strings.TrimLeft(end, "\t")
+				}
+				if end == m[2] {
+					break
+				}
+				body = append(body, lines[i])
+			}
+			text += "\n" + strings.Join(body, "\n") + "\n" + m[2]
+		}
+
+		_, args, _ := strings.Cut(text, " ")
+		in := Instruction{
+			Keyword: keyword,
+			Args:    strings.TrimSpace(args),
+			Line:    start + 1,
+			EndLine: i + 1,
+		}
+		if !instructions[in.Keyword] {
+			return nil, fmt.Errorf("line %d: unknown instruction %q", in.Line, in.Keyword)
+		}
+
+		if in.Keyword == "FROM" {
+			f.Stages = append(f.Stages, parseFrom(in, len(f.Stages)))
+			continue
+		}
+		if len(f.Stages) == 0 {
+			continue
+		}
+		st := &f.Stages[len(f.Stages)-1]
+		in.Stage = st.Index
+		st.Instructions = append(st.Instructions, in)
+	}
+
+	if len(f.Stages) == 0 {
+		return nil, fmt.Errorf("no FROM instruction")
+	}
+	return f, nil
+}
+
+func parseFrom(in Instruction, index int) Stage {
+	var fields []string
+	for _, f := range strings.Fields(in.Args) {
+		if !strings.HasPrefix(f, "--") {
+			fields = append(fields, f)
+		}
+	}
+	st := Stage{Index: index, Line: in.Line}
+	if len(fields) > 0 {
+		st.Base = fields[0]
+	}
+	if len(fields) == 3 && strings.EqualFold(fields[1], "AS") {
+		st.Name = fields[2]
+	}
+	return st
+}
+
+// Stage returns the stage a FROM or COPY --from reference names, if it is
+// an earlier stage of this file rather than an external image.
+func (f *File) Stage(ref string, before int) (*Stage, bool) {
+	for i := range f.Stages[:before] {
+		if ref != "" && strings.EqualFold(f.Stages[i].Name, ref) {
+			return &f.Stages[i], true
+		}
+	}
+	if n, err := strconv.Atoi(ref); err == nil && n >= 0 && n < before {
+		return &f.Stages[n], true
+	}
+	return nil, false
+}
+
+// chain returns the instructions whose layers make up a stage's image: its
+// own, preceded by those of the stage it builds FROM when that is local.
+func (f *File) chain(index int) []Instruction {
+	st := f.Stages[index]
+	if base, ok := f.Stage(st.Base, index); ok {
+		return append(f.chain(base.Index), st.Instructions...)
+	}
+	return append([]Instruction(nil), st.Instructions...)
+}
@@ -0,0 +1,97 @@
+package dockerfile
+
+import (
+	"strings"
+	"testing"
+)
+
+const multiStage = `# syntax=docker/dockerfile:1
+ARG GO_VERSION=1.22
+
+FROM golang:${GO_VERSION} AS builder
+WORKDIR /src
+# fetch modules first for caching
+COPY go.mod go.sum ./
+RUN go mod download
+COPY . .
+RUN CGO_ENABLED=0 \
+    # comments inside continuations are dropped
+    go build -o /out/app ./cmd/app
+
+FROM alpine:3.19
+RUN apk add --no-cache ca-certificates && \
+    adduser -D app
+COPY --from=builder /out/app /usr/local/bin/app
+RUN <<EOF
+echo hello > /etc/motd
+EOF
+USER app
+ENTRYPOINT ["app"]
+`
+
+func TestParse_MultiStage(t *testing.T) {
+	f, err := Parse(strings.NewReader(multiStage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Stages) != 2 {
+		t.Fatalf("expected 2 stages, got %d", len(f.Stages))
+	}
+	builder, final := f.Stages[0], f.Stages[1]
+	if builder.Name != "builder" || builder.Base != "golang:${GO_VERSION}" || builder.Line != 4 {
+		t.Errorf("unexpected builder stage: %+v", builder)
+	}
+	if final.StageName() != "1" || final.Base != "alpine:3.19" {
+		t.Errorf("unexpected final stage: %+v", final)
+	}
+
+	build := builder.Instructions[4]
+	if build.String() != "RUN CGO_ENABLED=0 go build -o /out/app ./cmd/app" || build.Line != 10 || build.EndLine != 12 {
+		t.Errorf("unexpected continuation: %+v", build)
+	}
+
+	copyFrom := final.Instructions[1]
+	if copyFrom.Flag("from") != "builder" || copyFrom.Line != 17 {
+		t.Errorf("unexpected COPY --from: %+v", copyFrom)
+	}
+	heredoc := final.Instructions[2]
+	if heredoc.Line != 18 || heredoc.EndLine != 20 || !strings.Contains(heredoc.Args, "echo hello") {
+		t.Errorf("unexpected heredoc: %+v", heredoc)
+	}
+	if got := final.Instructions[len(final.Instructions)-1].Stage; got != 1 {
+		t.Errorf("expected stage 1, got %d", got)
+	}
+}
+
+func TestParse_EscapeDirective(t *testing.T) {
+	f, err := Parse(strings.NewReader("# escape=`\nFROM scratch\nCOPY a `\n  b /\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Stages[0].Instructions[0].String(); got != "COPY a b /" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	if _, err := Parse(strings.NewReader("RUN echo\n")); err == nil {
+		t.Error("expected error without FROM")
+	}
+	if _, err := Parse(strings.NewReader("FROM scratch\nBOGUS x\n")); err == nil {
+		t.Error("expected error for unknown instruction")
+	}
+}
+
+func TestStage_LocalBase(t *testing.T) {
+	f, err := Parse(strings.NewReader("FROM alpine AS base\nRUN a\nFROM base\nRUN b\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := f.chain(1)
+	if len(chain) != 2 || chain[0].Args != "a" || chain[1].Args != "b" {
+		t.Fatalf("unexpected chain: %+v", chain)
+	}
+	if _, ok := f.Stage("alpine", 1); ok {
+		t.Error("external image should not resolve to a stage")
+	}
+}
@@ -1,19 +1,114 @@
 package embed
 
 import (
+	"bytes"
 	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"io/fs"
+	"mime"
 	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
 )
 
 //go:embed dist/*
 var assets embed.FS
 
-// FileServer returns an http.Handler that serves the embedded frontend assets.
+// FileServer returns an http.Handler that serves the embedded frontend
+// assets. Any other path gets index.html, so the app can route links like
+// /layers/3/etc/passwd itself.
 func FileServer() http.Handler {
 	sub, err := fs.Sub(assets, "dist")
 	if err != nil {
 		panic(err)
 	}
-	return http.FileServerFS(sub)
+	index, err := fs.ReadFile(sub, "index.html")
+	if err != nil {
+		panic(err)
+	}
+	files := http.FileServerFS(sub)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		if name == "" || strings.HasPrefix(name, "assets/") {
+			files.ServeHTTP(w, r)
+			return
+		}
+		if fi, err := fs.Stat(sub, name); err == nil && !fi.IsDir() {
+			files.ServeHTTP(w, r)
+			return
+		}
+		// ServeContent rather than ServeFile, which would redirect paths
+		// ending in /index.html
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeContent(w, r, "index.html", time.Time{}, bytes.NewReader(index))
+	})
+}
+
+// Report returns index.html made self-contained so it can be opened from
+// disk: the stylesheet, entry script and icon are inlined, and data is
+// embedded as JSON in a #peel-report script element for the app to read
+// instead of calling the API.
+func Report(data any) ([]byte, error) {
+	sub, err := fs.Sub(assets, "dist")
+	if err != nil {
+		return nil, err
+	}
+	return inline(sub, data)
+}
+
+var (
+	scriptTag = regexp.MustCompile(`<script type="module"[^>]*\ssrc="/([^"]+)"[^>]*></script>`)
+	linkTag   = regexp.MustCompile(`<link rel="([^"]+)"[^>]*\shref="/([^"]+)"[^>]*>`)
+)
+
+func inline(fsys fs.FS, data any) ([]byte, error) {
+	index, err := fs.ReadFile(fsys, "index.html")
+	if err != nil {
+		return nil, err
+	}
+	if !scriptTag.Match(index) {
+		return nil, errors.New("frontend not built (run mise run build)")
+	}
+	// json.Marshal escapes <, > and &, so the data can't close its element
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var firstErr error
+	read := func(name string) []byte {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return b
+	}
+	out := scriptTag.ReplaceAllFunc(index, func(tag []byte) []byte {
+		js := read(string(scriptTag.FindSubmatch(tag)[1]))
+		js = bytes.ReplaceAll(js, []byte("</script"), []byte(`<\/script`))
+		return append(append([]byte(`<script type="module">`), js...), "</script>"...)
+	})
+	out = linkTag.ReplaceAllFunc(out, func(tag []byte) []byte {
+		m := linkTag.FindSubmatch(tag)
+		rel, name := string(m[1]), string(m[2])
+		switch rel {
+		case "stylesheet":
+			return append(append([]byte("<style>"), read(name)...), "</style>"...)
+		case "icon":
+			uri := "data:" + mime.TypeByExtension(path.Ext(name)) + ";base64," + base64.StdEncoding.EncodeToString(read(name))
+			return bytes.Replace(tag, []byte(`"/`+name+`"`), []byte(`"`+uri+`"`), 1)
+		default:
+			// preloads of chunks that can't be loaded from disk
+			return nil
+		}
+	})
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	element := append(append([]byte(`<script id="peel-report" type="application/json">`), payload...), "</script>\n</head>"...)
+	return bytes.Replace(out, []byte("</head>"), element, 1), nil
 }
@@ -0,0 +1,47 @@
+package embed
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestInline(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html": {Data: []byte(`<!doctype html><html><head>
+<link rel="icon" type="image/svg+xml" href="/favicon.svg" />
+<script type="module" crossorigin src="/assets/index-abc.js"></script>
+<link rel="modulepreload" crossorigin href="/assets/shiki-def.js">
+<link rel="stylesheet" crossorigin href="/assets/index-abc.css">
+</head><body><div id="root"></div></body></html>`)},
+		"assets/index-abc.js":  {Data: []byte(`document.body.append("</script>");`)},
+		"assets/index-abc.css": {Data: []byte(`body{color:red}`)},
+		"favicon.svg":          {Data: []byte(`<svg/>`)},
+	}
+
+	out, err := inline(fsys, map[string]string{"html": "</script><b>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(out)
+	for _, want := range []string{
+		`<script type="module">document.body.append("<\/script>");</script>`,
+		`<style>body{color:red}</style>`,
+		`href="data:image/svg+xml;base64,`,
+		`<script id="peel-report" type="application/json">{"html":"\u003c/script\u003e\u003cb\u003e"}</script>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %s in:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "/assets/") {
+		t.Errorf("expected no asset references left:\n%s", html)
+	}
+}
+
+func TestInline_NotBuilt(t *testing.T) {
+	fsys := fstest.MapFS{"index.html": {Data: []byte(`<html><body><p>peel — run mise run build</p></body></html>`)}}
+	if _, err := inline(fsys, nil); err == nil {
+		t.Error("expected an error for the placeholder frontend")
+	}
+}
@@ -0,0 +1,80 @@
+package image
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coffee-cup/peel/internal/config"
+	dockerconfig "github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Keychain resolves registry credentials from the peel config first, then
+// from a Docker config.json: the one at authConfig if set, otherwise the
+// default locations.
+func Keychain(cfg *config.Config, authConfig string) (authn.Keychain, error) {
+	var fallback authn.Keychain = authn.DefaultKeychain
+	if authConfig != "" {
+		f, err := os.Open(authConfig)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		cf, err := dockerconfig.LoadFromReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", authConfig, err)
+		}
+		fallback = fileKeychain{cf}
+	}
+	return authn.NewMultiKeychain(configKeychain{cfg}, fallback), nil
+}
+
+// configKeychain serves credentials from the peel config.
+type configKeychain struct {
+	cfg *config.Config
+}
+
+func (k configKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	r := k.cfg.Registry(target.RegistryStr())
+	switch {
+	case r.Token != "":
+		return &authn.Bearer{Token: r.Token}, nil
+	case r.HasCredentials():
+		return &authn.Basic{Username: r.Username, Password: r.Password}, nil
+	}
+	return authn.Anonymous, nil
+}
+
+// fileKeychain serves credentials from a Docker config.json, including any
+// credential helpers it names.
+type fileKeychain struct {
+	cf *configfile.ConfigFile
+}
+
+func (k fileKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	var empty types.AuthConfig
+	for _, key := range []string{target.String(), target.RegistryStr()} {
+		// Docker Hub credentials live under a legacy key
+		if key == name.DefaultRegistry {
+			key = authn.DefaultAuthKey
+		}
+		cfg, err := k.cf.GetAuthConfig(key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ServerAddress = ""
+		if cfg != empty {
+			return authn.FromConfig(authn.AuthConfig{
+				Username:      cfg.Username,
+				Password:      cfg.Password,
+				Auth:          cfg.Auth,
+				IdentityToken: cfg.IdentityToken,
+				RegistryToken: cfg.RegistryToken,
+			}), nil
+		}
+	}
+	return authn.Anonymous, nil
+}
@@ -0,0 +1,59 @@
+package image
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coffee-cup/peel/internal/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func resolve(t *testing.T, kc authn.Keychain, registry string) *authn.AuthConfig {
+	t.Helper()
+	reg, err := name.NewRegistry(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := kc.Resolve(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := auth.Authorization()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestKeychain(t *testing.T) {
+	dockerConfig := filepath.Join(t.TempDir(), "config.json")
+	auth := base64.StdEncoding.EncodeToString([]byte("docker-user:docker-pass"))
+	data := `{"auths": {"quay.io": {"auth": "` + auth + `"}, "ghcr.io": {"auth": "` + auth + `"}}}`
+	if err := os.WriteFile(dockerConfig, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Registries: map[string]config.Registry{}}
+	cfg.Set("ghcr.io", config.Registry{Username: "robot", Password: "secret"})
+	cfg.Set("registry.internal", config.Registry{Token: "tok"})
+
+	kc, err := Keychain(cfg, dockerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resolve(t, kc, "ghcr.io"); got.Username != "robot" || got.Password != "secret" {
+		t.Errorf("expected peel config to win for ghcr.io, got %+v", got)
+	}
+	if got := resolve(t, kc, "registry.internal"); got.RegistryToken != "tok" {
+		t.Errorf("expected bearer token, got %+v", got)
+	}
+	if got := resolve(t, kc, "quay.io"); got.Username != "docker-user" || got.Password != "docker-pass" {
+		t.Errorf("expected --auth-config credentials for quay.io, got %+v", got)
+	}
+	if got := resolve(t, kc, "example.com"); *got != (authn.AuthConfig{}) {
+		t.Errorf("expected anonymous, got %+v", got)
+	}
+}
@@ -0,0 +1,140 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Page sizes for registry listings.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// tagWorkers bounds concurrent manifest fetches when describing tags.
+const tagWorkers = 8
+
+// CatalogPage is one page of a registry's repositories.
+type CatalogPage struct {
+	Registry     string   `json:"registry"`
+	Repositories []string `json:"repositories"`
+	Next         string   `json:"next,omitempty"` // pass as last for the following page
+}
+
+// TagInfo describes what a tag points to.
+type TagInfo struct {
+	Tag       string     `json:"tag"`
+	Ref       string     `json:"ref"`
+	Digest    string     `json:"digest,omitempty"`
+	MediaType string     `json:"mediaType,omitempty"`
+	Created   *time.Time `json:"created,omitempty"`
+	Platforms []string   `json:"platforms,omitempty"` // for indexes
+	Error     string     `json:"error,omitempty"`
+}
+
+// TagPage is one page of a repository's tags.
+type TagPage struct {
+	Repository string    `json:"repository"`
+	Tags       []TagInfo `json:"tags"`
+	Next       string    `json:"next,omitempty"`
+}
+
+// Catalog lists repositories in a registry using the catalog API, n at a
+// time starting after last.
+func (r *Registries) Catalog(ctx context.Context, host, last string, n int) (*CatalogPage, error) {
+	reg, err := r.ParseRegistry(host)
+	if err != nil {
+		return nil, err
+	}
+	repos, err := remote.CatalogPage(reg, last, n, append(r.Options(), remote.WithContext(ctx))...)
+	if err != nil {
+		return nil, fmt.Errorf("catalog %s: %w", host, err)
+	}
+	page := &CatalogPage{Registry: reg.RegistryStr(), Repositories: repos}
+	if len(repos) == n {
+		page.Next = repos[len(repos)-1]
+	}
+	return page, nil
+}
+
+// Tags lists a repository's tags, n at a time starting after last, with the
+// digest and creation date of each. Indexes are described by the image for
+// platform.
+func (r *Registries) Tags(ctx context.Context, repo string, platform v1.Platform, last string, n int) (*TagPage, error) {
+	parsed, err := r.ParseRepository(repo)
+	if err != nil {
+		return nil, err
+	}
+	all, err := remote.List(parsed, append(r.Options(), remote.WithContext(ctx))...)
+	if err != nil {
+		return nil, fmt.Errorf("tags %s: %w", repo, err)
+	}
+	sort.Strings(all)
+
+	start := sort.SearchStrings(all, last)
+	if last != "" && start < len(all) && all[start] == last {
+		start++
+	}
+	end := min(start+n, len(all))
+	page := &TagPage{Repository: parsed.Name(), Tags: make([]TagInfo, end-start)}
+	if end < len(all) {
+		page.Next = all[end-1]
+	}
+
+	opts := append([]remote.Option{remote.WithPlatform(platform), remote.WithContext(ctx)}, r.Options()...)
+	sem := make(chan struct{}, tagWorkers)
+	var wg sync.WaitGroup
+	for i, tag := range all[start:end] {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			page.Tags[i] = describeTag(parsed.Tag(tag), opts)
+		}()
+	}
+	wg.Wait()
+	return page, nil
+}
+
+// describeTag fetches a tag's manifest and config. Failures are recorded on
+// the tag rather than failing the page.
+func describeTag(tag name.Tag, opts []remote.Option) TagInfo {
+	info := TagInfo{Tag: tag.TagStr(), Ref: tag.String()}
+	desc, err := remote.Get(tag, opts...)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	info.Digest = desc.Digest.String()
+	info.MediaType = string(desc.MediaType)
+
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err == nil {
+			if im, err := idx.IndexManifest(); err == nil {
+				for _, m := range im.Manifests {
+					if m.Platform != nil && m.Platform.OS != "unknown" {
+						info.Platforms = append(info.Platforms, m.Platform.String())
+					}
+				}
+			}
+		}
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return info
+	}
+	if cfg, err := img.ConfigFile(); err == nil && !cfg.Created.IsZero() {
+		created := cfg.Created.Time
+		info.Created = &created
+	}
+	return info
+}
@@ -0,0 +1,80 @@
+package image
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestCatalogAndTags(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = mutate.CreatedAt(img, v1.Time{Time: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{"/team/api:1.0", "/team/api:1.1", "/team/api:latest", "/team/web:latest"} {
+		parsed, err := name.ParseReference(host + ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(parsed, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var reg *Registries
+	catalog, err := reg.Catalog(t.Context(), host, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog.Repositories) != 1 || catalog.Next != catalog.Repositories[0] {
+		t.Fatalf("unexpected first page: %+v", catalog)
+	}
+	// The in-process registry ignores last, so only the page size is checked here
+	catalog, err = reg.Catalog(t.Context(), host, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog.Repositories) != 2 || catalog.Next != "" {
+		t.Fatalf("unexpected full page: %+v", catalog)
+	}
+
+	tags, err := reg.Tags(t.Context(), host+"/team/api", testPlatform, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags.Tags) != 2 || tags.Tags[0].Tag != "1.0" || tags.Next != "1.1" {
+		t.Fatalf("unexpected tags page: %+v", tags)
+	}
+	digest, _ := img.Digest()
+	first := tags.Tags[0]
+	if first.Digest != digest.String() || first.Created == nil || !first.Created.Equal(created) || first.Ref != host+"/team/api:1.0" {
+		t.Errorf("unexpected tag info: %+v", first)
+	}
+
+	tags, err = reg.Tags(t.Context(), host+"/team/api", testPlatform, tags.Next, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags.Tags) != 1 || tags.Tags[0].Tag != "latest" || tags.Next != "" {
+		t.Errorf("unexpected last page: %+v", tags)
+	}
+}
@@ -0,0 +1,107 @@
+package image
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/coffee-cup/peel/internal/textdiff"
+)
+
+// TopLayer refers to the last layer of an image in a FileRef.
+const TopLayer = -1
+
+// FileRef identifies a file at a given layer.
+type FileRef struct {
+	Layer int    `json:"layer"`
+	Path  string `json:"path"`
+}
+
+// ParseFileRef parses "LAYER:PATH" or a bare "PATH", which refers to the top
+// layer. Anything before the first ":" that isn't a layer number is part of
+// the path, so paths like /var/lib/dpkg/info/libc6:amd64.list need no layer.
+func ParseFileRef(s string) (FileRef, error) {
+	ref := FileRef{Layer: TopLayer, Path: s}
+	if layer, p, ok := strings.Cut(s, ":"); ok {
+		if n, err := strconv.Atoi(layer); err == nil {
+			ref = FileRef{Layer: n, Path: p}
+		}
+	}
+	if ref.Path == "" {
+		return FileRef{}, fmt.Errorf("missing path in %q", s)
+	}
+	ref.Path = "/" + strings.TrimPrefix(ref.Path, "/")
+	return ref, nil
+}
+
+// FileDiff is a unified diff between two file versions. Binary files are
+// compared as hex dumps of their leading bytes.
+type FileDiff struct {
+	From      FileRef `json:"from"`
+	To        FileRef `json:"to"`
+	IsBinary  bool    `json:"isBinary"`
+	Truncated bool    `json:"truncated"`
+	Diff      string  `json:"diff"` // empty if the versions are identical
+}
+
+// CompareFiles diffs the file at fromRef in from against the file at toRef in to.
+// from and to may be the same image.
+func CompareFiles(ctx context.Context, from *Image, fromRef FileRef, to *Image, toRef FileRef) (*FileDiff, error) {
+	fromRef = from.resolveRef(fromRef)
+	toRef = to.resolveRef(toRef)
+
+	a, err := from.ReadFile(ctx, fromRef.Layer, fromRef.Path)
+	if err != nil {
+		return nil, fmt.Errorf("from: %w", err)
+	}
+	b, err := to.ReadFile(ctx, toRef.Layer, toRef.Path)
+	if err != nil {
+		return nil, fmt.Errorf("to: %w", err)
+	}
+
+	fromName := versionName(fromRef.Layer, fromRef.Path)
+	toName := versionName(toRef.Layer, toRef.Path)
+	if from != to {
+		fromName = from.Info.Ref + " " + fromName
+		toName = to.Info.Ref + " " + toName
+	}
+
+	fd := &FileDiff{
+		From:      fromRef,
+		To:        toRef,
+		IsBinary:  a.IsBinary || b.IsBinary,
+		Truncated: a.Truncated || b.Truncated,
+	}
+	aText, bText := a.Content, b.Content
+	if fd.IsBinary {
+		if aText, err = hexDumpContent(a); err != nil {
+			return nil, err
+		}
+		if bText, err = hexDumpContent(b); err != nil {
+			return nil, err
+		}
+	}
+	fd.Diff = textdiff.Unified(fromName, toName, aText, bText, textdiff.DefaultContext)
+	return fd, nil
+}
+
+func (im *Image) resolveRef(ref FileRef) FileRef {
+	if ref.Layer == TopLayer {
+		ref.Layer = len(im.Layers) - 1
+	}
+	return ref
+}
+
+func hexDumpContent(fc *FileContent) (string, error) {
+	if !fc.IsBinary {
+		data := []byte(fc.Content)
+		return textdiff.HexDump(data[:min(len(data), maxBinaryBytes)]), nil
+	}
+	data, err := hex.DecodeString(fc.Content)
+	if err != nil {
+		return "", fmt.Errorf("decode %s: %w", fc.Path, err)
+	}
+	return textdiff.HexDump(data), nil
+}
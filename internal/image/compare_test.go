@@ -0,0 +1,102 @@
+package image
+
+import (
+	"archive/tar"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+func TestParseFileRef(t *testing.T) {
+	cases := []struct {
+		in   string
+		want FileRef
+	}{
+		{"3:/etc/passwd", FileRef{Layer: 3, Path: "/etc/passwd"}},
+		{"0:etc/passwd", FileRef{Layer: 0, Path: "/etc/passwd"}},
+		{"/etc/passwd", FileRef{Layer: TopLayer, Path: "/etc/passwd"}},
+		{"/var/lib/dpkg/info/libc6:amd64.list", FileRef{Layer: TopLayer, Path: "/var/lib/dpkg/info/libc6:amd64.list"}},
+		{"2:/var/lib/dpkg/info/libc6:amd64.list", FileRef{Layer: 2, Path: "/var/lib/dpkg/info/libc6:amd64.list"}},
+		{"x:/etc/passwd", FileRef{Layer: TopLayer, Path: "/x:/etc/passwd"}},
+		{"C:", FileRef{Layer: TopLayer, Path: "/C:"}},
+	}
+	for _, c := range cases {
+		got, err := ParseFileRef(c.in)
+		if err != nil {
+			t.Fatalf("%s: %v", c.in, err)
+		}
+		if got != c.want {
+			t.Errorf("%s: expected %+v, got %+v", c.in, c.want, got)
+		}
+	}
+
+	for _, bad := range []string{"", "3:"} {
+		if _, err := ParseFileRef(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestCompareFiles_Text(t *testing.T) {
+	img := testImage(t)
+	fd, err := CompareFiles(t.Context(), img, FileRef{Layer: 0, Path: "/etc/hello"}, img, FileRef{Layer: TopLayer, Path: "/etc/hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fd.IsBinary {
+		t.Error("expected text diff")
+	}
+	if fd.To.Layer != 2 {
+		t.Errorf("expected top layer to resolve to 2, got %d", fd.To.Layer)
+	}
+	if !strings.Contains(fd.Diff, "-hello\n+hello2\n") {
+		t.Errorf("unexpected diff:\n%s", fd.Diff)
+	}
+}
+
+func TestCompareFiles_DifferentPaths(t *testing.T) {
+	img := testImage(t)
+	fd, err := CompareFiles(t.Context(), img, FileRef{Layer: 0, Path: "/etc/hello"}, img, FileRef{Layer: 0, Path: "/usr/bin/app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fd.Diff, "--- layer 0:/etc/hello\n+++ layer 0:/usr/bin/app\n") {
+		t.Errorf("unexpected headers:\n%s", fd.Diff)
+	}
+}
+
+func TestCompareFiles_Binary(t *testing.T) {
+	layer := buildTarLayer(t, []tarEntry{
+		{name: "a.bin", typeflag: tar.TypeReg, data: []byte("\x00\x01\x02abc")},
+		{name: "b.bin", typeflag: tar.TypeReg, data: []byte("\x00\x01\x03abc")},
+	})
+	raw, err := mutate.Append(empty.Image, mutate.Addendum{Layer: layer, History: v1.History{CreatedBy: "COPY . /"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := Analyze(t.Context(), raw, "test:binary", AnalyzeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err := CompareFiles(t.Context(), img, FileRef{Layer: 0, Path: "/a.bin"}, img, FileRef{Layer: 0, Path: "/b.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fd.IsBinary {
+		t.Fatal("expected binary diff")
+	}
+	if !strings.Contains(fd.Diff, "-00000000  00 01 02 61") || !strings.Contains(fd.Diff, "+00000000  00 01 03 61") {
+		t.Errorf("expected hex dump diff, got:\n%s", fd.Diff)
+	}
+}
+
+func TestCompareFiles_Missing(t *testing.T) {
+	img := testImage(t)
+	if _, err := CompareFiles(t.Context(), img, FileRef{Layer: 0, Path: "/nope"}, img, FileRef{Layer: 0, Path: "/etc/hello"}); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
@@ -2,34 +2,42 @@ package image
 
 import (
 	"archive/tar"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path"
+	"runtime"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
-// buildLayerTree reads a layer's tar and builds a FileNode tree.
-func buildLayerTree(layer v1.Layer) (*FileNode, error) {
+// buildLayerTree reads a layer's tar and builds a FileNode tree. It also
+// returns the uncompressed size of the tar. Reading stops when ctx is done.
+func buildLayerTree(ctx context.Context, layer v1.Layer) (*FileNode, int64, error) {
 	rc, err := layer.Uncompressed()
 	if err != nil {
-		return nil, fmt.Errorf("uncompress layer: %w", err)
+		return nil, 0, fmt.Errorf("uncompress layer: %w", err)
 	}
 	defer rc.Close()
 
-	root := &FileNode{Name: "/", Path: "/", Type: FileTypeDir}
+	root := &FileNode{Name: "/", Path: "/", Type: FileTypeDir, implicit: true}
 	lookup := map[string]*FileNode{"/": root}
 
-	tr := tar.NewReader(rc)
+	cr := &countingReader{r: &ctxReader{ctx: ctx, r: rc}}
+	tr := tar.NewReader(cr)
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("read tar: %w", err)
+			return nil, 0, fmt.Errorf("read tar: %w", err)
 		}
 
 		cleanPath := "/" + strings.TrimPrefix(path.Clean(hdr.Name), "/")
@@ -71,8 +79,40 @@ func buildLayerTree(layer v1.Layer) (*FileNode, error) {
 		lookup[cleanPath] = node
 	}
 
+	// Drain the end-of-archive padding so the count covers the whole tar
+	if _, err := io.Copy(io.Discard, cr); err != nil {
+		return nil, 0, fmt.Errorf("read tar: %w", err)
+	}
+
 	sortTree(root)
-	return root, nil
+	aggregateTree(root)
+	return root, cr.n, nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ctxReader fails reads once ctx is done, so an abandoned request stops
+// decompressing and closing the layer releases its download.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
 }
 
 // ensureParents creates any missing ancestor directories for p.
@@ -83,16 +123,50 @@ func ensureParents(lookup map[string]*FileNode, root *FileNode, p string) {
 	}
 	// Recursively ensure grandparents
 	ensureParents(lookup, root, dir)
-	node := &FileNode{Name: path.Base(dir), Path: dir, Type: FileTypeDir}
+	node := &FileNode{Name: path.Base(dir), Path: dir, Type: FileTypeDir, implicit: true}
 	parentDir := lookup[path.Dir(dir)]
 	parentDir.Children = append(parentDir.Children, node)
 	lookup[dir] = node
 }
 
 func sortTree(n *FileNode) {
-	if len(n.Children) == 0 {
+	sortChildren(n)
+	for _, c := range n.Children {
+		sortTree(c)
+	}
+}
+
+// aggregateTree fills in directory totals for every dir in n, bottom-up.
+func aggregateTree(n *FileNode) {
+	for _, c := range n.Children {
+		aggregateTree(c)
+	}
+	aggregate(n)
+}
+
+// aggregate sets n's totals from its direct children, which must already be aggregated.
+func aggregate(n *FileNode) {
+	if n.Type != FileTypeDir {
 		return
 	}
+	n.TotalSize = n.Size
+	n.Descendants = 0
+	for _, c := range n.Children {
+		n.TotalSize += nodeTotal(c)
+		n.Descendants += 1 + c.Descendants
+	}
+}
+
+// nodeTotal returns the bytes at or below n.
+func nodeTotal(n *FileNode) int64 {
+	if n.Type == FileTypeDir {
+		return n.TotalSize
+	}
+	return n.Size
+}
+
+// sortChildren orders n's direct children: directories first, then by name.
+func sortChildren(n *FileNode) {
 	sort.Slice(n.Children, func(i, j int) bool {
 		a, b := n.Children[i], n.Children[j]
 		if (a.Type == FileTypeDir) != (b.Type == FileTypeDir) {
@@ -100,129 +174,274 @@ func sortTree(n *FileNode) {
 		}
 		return a.Name < b.Name
 	})
-	for _, c := range n.Children {
-		sortTree(c)
-	}
 }
 
-// mergeTrees deep-copies base and applies overlay on top, handling whiteouts.
-func mergeTrees(base, overlay *FileNode) *FileNode {
-	merged := deepCopy(base)
-	applyOverlay(merged, overlay)
-	sortTree(merged)
-	return merged
-}
+const opaqueWhiteout = ".wh..wh..opq"
 
-func deepCopy(n *FileNode) *FileNode {
-	cp := *n
-	if len(n.Children) > 0 {
-		cp.Children = make([]*FileNode, len(n.Children))
-		for i, c := range n.Children {
-			cp.Children[i] = deepCopy(c)
-		}
-	}
-	return &cp
-}
-
-func applyOverlay(base, overlay *FileNode) {
+// mergeTrees applies overlay on top of base, handling whiteouts, and returns the
+// new root. base is never modified: only directories along paths the overlay
+// touches are copied, and every untouched subtree is shared with base. This
+// keeps memory proportional to what each layer changes rather than to the
+// size of the whole filesystem.
+func mergeTrees(base, overlay *FileNode) *FileNode {
 	if overlay == nil {
-		return
+		return base
 	}
+	return mergeDir(base, overlay)
+}
 
-	baseLookup := make(map[string]*FileNode, len(base.Children))
-	for _, c := range base.Children {
-		baseLookup[c.Name] = c
+// mergeDir returns a copy of the directory base with overlay's children applied.
+// Replaced entries keep the layer that first added their path, and the
+// directory only counts as rewritten when the overlay's tar has an entry for it.
+func mergeDir(base, overlay *FileNode) *FileNode {
+	merged := *base
+	if !overlay.implicit {
+		merged.ModifiedIn = overlay.ModifiedIn
 	}
 
-	// Check for opaque whiteout — clears all base children
-	hasOpaque := false
-	for _, c := range overlay.Children {
-		if c.Name == ".wh..wh..opq" {
-			hasOpaque = true
-			break
-		}
+	// Opaque whiteout — hides all base children
+	var children []*FileNode
+	if childNamed(overlay, opaqueWhiteout) == nil {
+		children = slices.Clone(base.Children)
 	}
-	if hasOpaque {
-		base.Children = nil
-		baseLookup = nil
+	index := make(map[string]int, len(children))
+	for i, c := range children {
+		index[c.Name] = i
 	}
 
 	for _, oc := range overlay.Children {
-		// Skip whiteout markers themselves
-		if oc.Name == ".wh..wh..opq" {
+		if oc.Name == opaqueWhiteout {
 			continue
 		}
 
 		// Individual whiteout: .wh.NAME means delete NAME
-		if strings.HasPrefix(oc.Name, ".wh.") {
-			target := strings.TrimPrefix(oc.Name, ".wh.")
-			base.Children = removeChild(base.Children, target)
-			delete(baseLookup, target)
+		if target, ok := strings.CutPrefix(oc.Name, ".wh."); ok {
+			if i, exists := index[target]; exists {
+				children[i] = nil
+				delete(index, target)
+			}
 			continue
 		}
 
-		existing, exists := baseLookup[oc.Name]
-		if !exists {
-			// New entry
-			base.Children = append(base.Children, deepCopy(oc))
-			continue
+		i, exists := index[oc.Name]
+		switch {
+		case !exists:
+			index[oc.Name] = len(children)
+			children = append(children, cloneLayerNode(oc))
+		case children[i].Type == FileTypeDir && oc.Type == FileTypeDir:
+			children[i] = mergeDir(children[i], oc)
+		default:
+			// Type mismatch or file→file replacement — overlay wins
+			replacement := cloneLayerNode(oc)
+			replacement.AddedIn = children[i].AddedIn
+			children[i] = replacement
 		}
+	}
 
-		// Both are dirs — recurse
-		if existing.Type == FileTypeDir && oc.Type == FileTypeDir {
-			applyOverlay(existing, oc)
+	merged.Children = slices.DeleteFunc(children, func(c *FileNode) bool { return c == nil })
+	sortChildren(&merged)
+	aggregate(&merged)
+	return &merged
+}
+
+// stampLayer records layer as the creator and last writer of every node in a layer tree.
+func stampLayer(n *FileNode, layer int) {
+	n.AddedIn = layer
+	n.ModifiedIn = layer
+	for _, c := range n.Children {
+		stampLayer(c, layer)
+	}
+}
+
+// cloneLayerNode copies a subtree from a layer tree into a cumulative tree,
+// dropping whiteout markers, which have nothing below them to delete.
+func cloneLayerNode(n *FileNode) *FileNode {
+	cp := *n
+	cp.Children = nil
+	for _, c := range n.Children {
+		if strings.HasPrefix(c.Name, ".wh.") {
 			continue
 		}
+		cp.Children = append(cp.Children, cloneLayerNode(c))
+	}
+	sortChildren(&cp)
+	aggregate(&cp)
+	return &cp
+}
 
-		// Type mismatch or file→file replacement — overlay wins
-		replacement := deepCopy(oc)
-		for i, c := range base.Children {
-			if c.Name == oc.Name {
-				base.Children[i] = replacement
-				break
-			}
+// buildCumulativeTrees builds the merged filesystem tree at each layer, along with
+// the per-layer view of each content layer's own tar and its uncompressed size.
+// Empty layers share the previous tree, and consecutive trees share every subtree a
+// layer leaves untouched (safe because trees are immutable after construction).
+//
+// Layer tars are fetched and read by up to jobs workers at once; only the merge
+// runs in layer order. jobs < 1 means one per CPU. Layers found in reuse by
+// DiffID are rebuilt from their earlier per-layer view instead of reread.
+func buildCumulativeTrees(ctx context.Context, layers []v1.Layer, emptyFlags []bool, jobs int, reuse map[v1.Hash]layerTree) (trees, layerTrees []*FileNode, sizes []int64, reused int, err error) {
+	trees = make([]*FileNode, len(emptyFlags))
+	layerTrees = make([]*FileNode, len(emptyFlags))
+	sizes = make([]int64, len(emptyFlags))
+
+	// history index of each content layer
+	var positions []int
+	for i, empty := range emptyFlags {
+		if !empty && len(positions) < len(layers) {
+			positions = append(positions, i)
 		}
 	}
-}
 
-func removeChild(children []*FileNode, name string) []*FileNode {
-	for i, c := range children {
-		if c.Name == name {
-			return append(children[:i], children[i+1:]...)
+	raw, err := buildLayerTrees(ctx, layers[:len(positions)], positions, jobs, reuse)
+	if err != nil {
+		return nil, nil, nil, 0, err
+	}
+	for ci, i := range positions {
+		sizes[i] = raw[ci].size
+		layerTrees[i] = layerView(raw[ci].tree)
+		if raw[ci].reused {
+			reused++
 		}
 	}
-	return children
+
+	prev := &FileNode{Name: "/", Path: "/", Type: FileTypeDir}
+	ci := 0
+	for i := range emptyFlags {
+		if ci < len(positions) && positions[ci] == i {
+			prev = mergeTrees(prev, raw[ci].tree)
+			trees[i] = prev
+			ci++
+		} else if ci > 0 {
+			trees[i] = prev
+		}
+	}
+	return trees, layerTrees, sizes, reused, nil
 }
 
-// buildCumulativeTrees builds the merged filesystem tree at each layer.
-// Empty layers share the previous tree (safe because trees are immutable after construction).
-func buildCumulativeTrees(layers []v1.Layer, emptyFlags []bool) ([]*FileNode, error) {
-	trees := make([]*FileNode, len(emptyFlags))
-	var prev *FileNode
+// layerTree is a layer's tree and the uncompressed size of its tar.
+type layerTree struct {
+	tree   *FileNode
+	size   int64
+	reused bool
+}
 
-	layerIdx := 0
-	for i, empty := range emptyFlags {
-		if empty {
-			trees[i] = prev
-			continue
+// buildLayerTrees builds each layer's raw tree, stamped with its history
+// index, in a pool of jobs workers. The first error cancels the rest.
+func buildLayerTrees(ctx context.Context, layers []v1.Layer, positions []int, jobs int, reuse map[v1.Hash]layerTree) ([]layerTree, error) {
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	out := make([]layerTree, len(layers))
+	errs := make([]error, len(layers))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(jobs, len(layers)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ci := range next {
+				if diffID, err := layers[ci].DiffID(); err == nil {
+					if prev, ok := reuse[diffID]; ok {
+						out[ci] = layerTree{tree: rawFromView(prev.tree, positions[ci]), size: prev.size, reused: true}
+						continue
+					}
+				}
+				tree, size, err := buildLayerTree(ctx, layers[ci])
+				if err != nil {
+					errs[ci] = fmt.Errorf("layer %d: %w", positions[ci], err)
+					cancel()
+					continue
+				}
+				stampLayer(tree, positions[ci])
+				out[ci] = layerTree{tree: tree, size: size}
+			}
+		}()
+	}
+feed:
+	for ci := range layers {
+		select {
+		case next <- ci:
+		case <-ctx.Done():
+			break feed
 		}
-		if layerIdx >= len(layers) {
-			trees[i] = prev
+	}
+	close(next)
+	wg.Wait()
+
+	// report the layer that failed rather than the cancellations it caused
+	var cancelled error
+	for _, err := range errs {
+		if err == nil {
 			continue
 		}
-		tree, err := buildLayerTree(layers[layerIdx])
-		if err != nil {
-			return nil, fmt.Errorf("layer %d: %w", i, err)
+		if !errors.Is(err, context.Canceled) {
+			return nil, err
 		}
-		if prev == nil {
-			prev = tree
-		} else {
-			prev = mergeTrees(prev, tree)
+		if cancelled == nil {
+			cancelled = err
+		}
+	}
+	if cancelled != nil {
+		return nil, cancelled
+	}
+	// ctx may have ended before every layer was handed out
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// layerView copies a raw layer tree, turning whiteout markers into explicit
+// entries: ".wh.NAME" becomes a whiteout node for NAME, and an opaque marker
+// flags its parent directory as Opaque.
+func layerView(raw *FileNode) *FileNode {
+	cp := *raw
+	cp.Children = nil
+	for _, c := range raw.Children {
+		switch {
+		case c.Name == ".wh..wh..opq":
+			cp.Opaque = true
+		case strings.HasPrefix(c.Name, ".wh."):
+			target := strings.TrimPrefix(c.Name, ".wh.")
+			cp.Children = append(cp.Children, &FileNode{
+				Name: target,
+				Path: path.Join(path.Dir(c.Path), target),
+				Type: FileTypeWhiteout,
+			})
+		default:
+			cp.Children = append(cp.Children, layerView(c))
 		}
-		trees[i] = prev
-		layerIdx++
 	}
-	return trees, nil
+	sortChildren(&cp)
+	aggregate(&cp)
+	return &cp
+}
+
+// rawFromView inverts layerView, turning whiteout entries back into ".wh."
+// markers, and stamps every node with layer. It lets an unchanged layer be
+// merged again without rereading its tar.
+func rawFromView(view *FileNode, layer int) *FileNode {
+	cp := *view
+	cp.Opaque = false
+	cp.AddedIn, cp.ModifiedIn = layer, layer
+	cp.Children = nil
+	marker := func(name string) *FileNode {
+		return &FileNode{Name: name, Path: path.Join(view.Path, name), Type: FileTypeFile, AddedIn: layer, ModifiedIn: layer}
+	}
+	if view.Opaque {
+		cp.Children = append(cp.Children, marker(opaqueWhiteout))
+	}
+	for _, c := range view.Children {
+		if c.Type == FileTypeWhiteout {
+			cp.Children = append(cp.Children, marker(".wh."+c.Name))
+			continue
+		}
+		cp.Children = append(cp.Children, rawFromView(c, layer))
+	}
+	sortChildren(&cp)
+	aggregate(&cp)
+	return &cp
 }
 
 // computeDiff walks two trees and reports added/modified/deleted entries.
@@ -261,7 +480,12 @@ func diffWalk(prev, curr *FileNode, diffs *[]DiffEntry) {
 			collectAll(cn, ChangeAdded, diffs)
 			continue
 		}
-		if cn.Type != pn.Type || cn.Size != pn.Size || cn.LinkTarget != pn.LinkTarget {
+		if cn == pn {
+			// Shared subtree — nothing below it changed
+			continue
+		}
+		rewritten := cn.Type != FileTypeDir && cn.ModifiedIn != pn.ModifiedIn
+		if cn.Type != pn.Type || cn.Size != pn.Size || cn.LinkTarget != pn.LinkTarget || rewritten {
 			*diffs = append(*diffs, DiffEntry{
 				Path:       cn.Path,
 				Type:       cn.Type,
@@ -311,6 +535,82 @@ func buildPathLookup(root *FileNode) map[string]*FileNode {
 	return lookup
 }
 
+// findNode walks root to the node at p. Returns nil if p does not exist.
+func findNode(root *FileNode, p string) *FileNode {
+	node := root
+	rel := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if rel == "" {
+		return node
+	}
+	for _, name := range strings.Split(rel, "/") {
+		if node = childNamed(node, name); node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+func childNamed(n *FileNode, name string) *FileNode {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// ListDir returns the immediate children of the directory at dirPath in root.
+// A nil root is treated as an empty filesystem. If layerRoot is non-nil, each
+// entry's LayerSize is the bytes layerRoot holds at or below the entry's path.
+func ListDir(root, layerRoot *FileNode, dirPath string) ([]DirEntry, error) {
+	if root == nil {
+		root = &FileNode{Name: "/", Path: "/", Type: FileTypeDir}
+	}
+	dir := findNode(root, dirPath)
+	if dir == nil {
+		return nil, fmt.Errorf("directory not found: %s", dirPath)
+	}
+	if dir.Type != FileTypeDir {
+		return nil, fmt.Errorf("not a directory: %s", dirPath)
+	}
+
+	var layerDir *FileNode
+	if layerRoot != nil {
+		layerDir = findNode(layerRoot, dirPath)
+	}
+
+	entries := make([]DirEntry, 0, len(dir.Children))
+	for _, c := range dir.Children {
+		entry := DirEntry{
+			Name:        c.Name,
+			Path:        c.Path,
+			Type:        c.Type,
+			Size:        c.Size,
+			LinkTarget:  c.LinkTarget,
+			Opaque:      c.Opaque,
+			AddedIn:     c.AddedIn,
+			ModifiedIn:  c.ModifiedIn,
+			ChildCount:  len(c.Children),
+			Descendants: c.Descendants,
+			TotalSize:   nodeTotal(c),
+		}
+		if layerDir != nil {
+			if lc := childNamed(layerDir, c.Name); lc != nil {
+				entry.LayerSize = nodeTotal(lc)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// SortDirEntriesBySize orders entries largest first, keeping name order among equals.
+func SortDirEntriesBySize(entries []DirEntry) {
+	slices.SortStableFunc(entries, func(a, b DirEntry) int {
+		return cmp.Compare(b.TotalSize, a.TotalSize)
+	})
+}
+
 // resolveSymlink follows symlink chains in the tree, returning the resolved path.
 // Returns the original path if not a symlink. Returns an error for dangling or cyclic links.
 func resolveSymlink(root *FileNode, filePath string, maxHops int) (string, error) {
@@ -323,7 +623,7 @@ func resolveSymlink(root *FileNode, filePath string, maxHops int) (string, error
 	for i := 0; i < maxHops; i++ {
 		node, ok := lookup[current]
 		if !ok {
-			return "", fmt.Errorf("dangling symlink: %s not found", current)
+			return "", fmt.Errorf("dangling symlink: %s %w", current, ErrNotFound)
 		}
 		if node.Type != FileTypeSymlink {
 			return current, nil
@@ -339,7 +639,7 @@ func resolveSymlink(root *FileNode, filePath string, maxHops int) (string, error
 }
 
 // readFileFromLayer searches backward through layers to find a file at path.
-func readFileFromLayer(layers []v1.Layer, emptyFlags []bool, layerIdx int, filePath string) ([]byte, int64, error) {
+func readFileFromLayer(ctx context.Context, layers []v1.Layer, emptyFlags []bool, layerIdx int, filePath string) ([]byte, int64, error) {
 	cleanPath := "/" + strings.TrimPrefix(path.Clean(filePath), "/")
 
 	// Map layer index (including empty) to content layer indices
@@ -355,7 +655,7 @@ func readFileFromLayer(layers []v1.Layer, emptyFlags []bool, layerIdx int, fileP
 		if ci >= len(layers) {
 			continue
 		}
-		data, size, err := searchLayerTar(layers[ci], cleanPath)
+		data, size, err := searchLayerTar(ctx, layers[ci], cleanPath)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -363,17 +663,17 @@ func readFileFromLayer(layers []v1.Layer, emptyFlags []bool, layerIdx int, fileP
 			return data, size, nil
 		}
 	}
-	return nil, 0, fmt.Errorf("file not found: %s", filePath)
+	return nil, 0, fmt.Errorf("file %w: %s", ErrNotFound, filePath)
 }
 
-func searchLayerTar(layer v1.Layer, filePath string) ([]byte, int64, error) {
+func searchLayerTar(ctx context.Context, layer v1.Layer, filePath string) ([]byte, int64, error) {
 	rc, err := layer.Uncompressed()
 	if err != nil {
 		return nil, 0, fmt.Errorf("uncompress: %w", err)
 	}
 	defer rc.Close()
 
-	tr := tar.NewReader(rc)
+	tr := tar.NewReader(&ctxReader{ctx: ctx, r: rc})
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
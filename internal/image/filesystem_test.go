@@ -1,7 +1,16 @@
 package image
 
 import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
 	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 // --- mergeTrees ---
@@ -88,6 +97,111 @@ func TestMergeTrees_NewFile(t *testing.T) {
 	}
 }
 
+func TestMergeTrees_SharesUntouchedSubtrees(t *testing.T) {
+	base := &FileNode{Name: "/", Path: "/", Type: FileTypeDir, Children: []*FileNode{
+		{Name: "a", Path: "/a", Type: FileTypeDir, Children: []*FileNode{
+			{Name: "x", Path: "/a/x", Type: FileTypeFile, Size: 1},
+		}},
+		{Name: "b", Path: "/b", Type: FileTypeDir, Children: []*FileNode{
+			{Name: "y", Path: "/b/y", Type: FileTypeFile, Size: 2},
+		}},
+	}}
+	overlay := &FileNode{Name: "/", Path: "/", Type: FileTypeDir, Children: []*FileNode{
+		{Name: "b", Path: "/b", Type: FileTypeDir, Children: []*FileNode{
+			{Name: "y", Path: "/b/y", Type: FileTypeFile, Size: 3},
+		}},
+	}}
+	merged := mergeTrees(base, overlay)
+
+	if merged.Children[0] != base.Children[0] {
+		t.Error("untouched /a should be shared with base")
+	}
+	if merged.Children[1] == base.Children[1] {
+		t.Error("modified /b should be copied")
+	}
+	if merged.Children[1].Children[0].Size != 3 {
+		t.Errorf("expected merged /b/y size 3, got %d", merged.Children[1].Children[0].Size)
+	}
+	if base.Children[1].Children[0].Size != 2 {
+		t.Errorf("base /b/y should be unchanged, got size %d", base.Children[1].Children[0].Size)
+	}
+}
+
+func TestMergeTrees_DoesNotModifyBase(t *testing.T) {
+	base := &FileNode{Name: "/", Path: "/", Type: FileTypeDir, Children: []*FileNode{
+		{Name: "dir", Path: "/dir", Type: FileTypeDir, Children: []*FileNode{
+			{Name: "a", Path: "/dir/a", Type: FileTypeFile},
+			{Name: "b", Path: "/dir/b", Type: FileTypeFile},
+		}},
+	}}
+	overlay := &FileNode{Name: "/", Path: "/", Type: FileTypeDir, Children: []*FileNode{
+		{Name: "dir", Path: "/dir", Type: FileTypeDir, Children: []*FileNode{
+			{Name: ".wh.a", Path: "/dir/.wh.a", Type: FileTypeFile},
+			{Name: "c", Path: "/dir/c", Type: FileTypeFile},
+		}},
+		{Name: "new", Path: "/new", Type: FileTypeDir, Children: []*FileNode{
+			{Name: ".wh..wh..opq", Path: "/new/.wh..wh..opq", Type: FileTypeFile},
+		}},
+	}}
+	merged := mergeTrees(base, overlay)
+
+	lookup := buildPathLookup(merged)
+	for _, p := range []string{"/dir/b", "/dir/c", "/new"} {
+		if _, ok := lookup[p]; !ok {
+			t.Errorf("merged missing %s", p)
+		}
+	}
+	if _, ok := lookup["/dir/a"]; ok {
+		t.Error("merged should not have whited-out /dir/a")
+	}
+	if _, ok := lookup["/new/.wh..wh..opq"]; ok {
+		t.Error("whiteout markers should not be copied into merged tree")
+	}
+
+	baseDir := base.Children[0]
+	if len(baseDir.Children) != 2 || baseDir.Children[0].Name != "a" || baseDir.Children[1].Name != "b" {
+		t.Fatalf("base /dir modified: %v", baseDir.Children)
+	}
+}
+
+// --- layerView ---
+
+func TestLayerView_Whiteouts(t *testing.T) {
+	raw := &FileNode{Name: "/", Path: "/", Type: FileTypeDir, Children: []*FileNode{
+		{Name: ".wh.gone", Path: "/.wh.gone", Type: FileTypeFile},
+		{Name: "dir", Path: "/dir", Type: FileTypeDir, Children: []*FileNode{
+			{Name: ".wh..wh..opq", Path: "/dir/.wh..wh..opq", Type: FileTypeFile},
+			{Name: "kept", Path: "/dir/kept", Type: FileTypeFile, Size: 4},
+		}},
+	}}
+	view := layerView(raw)
+	lookup := buildPathLookup(view)
+
+	gone, ok := lookup["/gone"]
+	if !ok {
+		t.Fatal("expected whiteout entry for /gone")
+	}
+	if gone.Type != FileTypeWhiteout {
+		t.Fatalf("expected whiteout, got %s", gone.Type)
+	}
+	if _, ok := lookup["/.wh.gone"]; ok {
+		t.Error("whiteout marker should not appear by its raw name")
+	}
+
+	dir := lookup["/dir"]
+	if !dir.Opaque {
+		t.Error("expected /dir to be opaque")
+	}
+	if len(dir.Children) != 1 || dir.Children[0].Name != "kept" {
+		t.Fatalf("expected only kept in /dir, got %v", dir.Children)
+	}
+
+	// Raw tree is left untouched for merging
+	if raw.Children[0].Name != ".wh.gone" {
+		t.Error("layerView should not modify the raw tree")
+	}
+}
+
 // --- computeDiff ---
 
 func TestComputeDiff_NilPrev(t *testing.T) {
@@ -142,6 +256,19 @@ func TestComputeDiff_NestedDirs(t *testing.T) {
 	}
 }
 
+func TestComputeDiff_SameSizeRewrite(t *testing.T) {
+	prev := &FileNode{Name: "/", Path: "/", Type: FileTypeDir, Children: []*FileNode{
+		{Name: "f", Path: "/f", Type: FileTypeFile, Size: 4, ModifiedIn: 0},
+	}}
+	curr := &FileNode{Name: "/", Path: "/", Type: FileTypeDir, ModifiedIn: 1, Children: []*FileNode{
+		{Name: "f", Path: "/f", Type: FileTypeFile, Size: 4, ModifiedIn: 1},
+	}}
+	diffs := computeDiff(prev, curr)
+	if len(diffs) != 1 || diffs[0].ChangeKind != ChangeModified {
+		t.Fatalf("expected /f modified, got %v", diffs)
+	}
+}
+
 func TestComputeDiff_NoChanges(t *testing.T) {
 	tree := &FileNode{Name: "/", Path: "/", Type: FileTypeDir, Children: []*FileNode{
 		{Name: "x", Path: "/x", Type: FileTypeFile, Size: 1},
@@ -152,6 +279,86 @@ func TestComputeDiff_NoChanges(t *testing.T) {
 	}
 }
 
+// --- ListDir ---
+
+func TestListDir_Aggregates(t *testing.T) {
+	root := &FileNode{Name: "/", Path: "/", Type: FileTypeDir, Children: []*FileNode{
+		{Name: "usr", Path: "/usr", Type: FileTypeDir, Children: []*FileNode{
+			{Name: "a", Path: "/usr/a", Type: FileTypeFile, Size: 10},
+			{Name: "lib", Path: "/usr/lib", Type: FileTypeDir, Children: []*FileNode{
+				{Name: "b", Path: "/usr/lib/b", Type: FileTypeFile, Size: 5},
+			}},
+		}},
+		{Name: "f", Path: "/f", Type: FileTypeFile, Size: 3},
+	}}
+	aggregateTree(root)
+	entries, err := ListDir(root, nil, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	usr := entries[0]
+	if usr.Path != "/usr" || usr.ChildCount != 2 || usr.Descendants != 3 || usr.TotalSize != 15 {
+		t.Fatalf("unexpected /usr entry: %+v", usr)
+	}
+	if entries[1].TotalSize != 3 {
+		t.Fatalf("expected file total size 3, got %d", entries[1].TotalSize)
+	}
+
+	nested, err := ListDir(root, nil, "/usr/lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nested) != 1 || nested[0].Path != "/usr/lib/b" {
+		t.Fatalf("unexpected /usr/lib listing: %+v", nested)
+	}
+}
+
+func TestListDir_LayerSize(t *testing.T) {
+	img := testImage(t)
+	entries, err := ListDir(img.Trees[2], img.LayerTrees[2], "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := map[string]int64{}
+	for _, e := range entries {
+		sizes[e.Path] = e.LayerSize
+	}
+	// Layer 2 rewrote /etc/hello and added /var/new, but left /lib alone
+	if sizes["/etc"] != int64(len("hello2\n")) {
+		t.Errorf("expected /etc layer size %d, got %d", len("hello2\n"), sizes["/etc"])
+	}
+	if sizes["/var"] != int64(len("new\n")) {
+		t.Errorf("expected /var layer size %d, got %d", len("new\n"), sizes["/var"])
+	}
+	if sizes["/lib"] != 0 {
+		t.Errorf("expected /lib layer size 0, got %d", sizes["/lib"])
+	}
+
+	SortDirEntriesBySize(entries)
+	if entries[0].Path != "/etc" {
+		t.Errorf("expected /etc to be largest, got %s", entries[0].Path)
+	}
+}
+
+func TestListDir_Errors(t *testing.T) {
+	root := &FileNode{Name: "/", Path: "/", Type: FileTypeDir, Children: []*FileNode{
+		{Name: "f", Path: "/f", Type: FileTypeFile},
+	}}
+	if _, err := ListDir(root, nil, "/missing"); err == nil {
+		t.Error("expected error for missing dir")
+	}
+	if _, err := ListDir(root, nil, "/f"); err == nil {
+		t.Error("expected error for listing a file")
+	}
+	entries, err := ListDir(nil, nil, "/")
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected empty listing for nil root, got %v, %v", entries, err)
+	}
+}
+
 // --- resolveSymlink ---
 
 func TestResolveSymlink_Absolute(t *testing.T) {
@@ -271,9 +478,90 @@ func TestBuildCumulativeTrees(t *testing.T) {
 	}
 }
 
+func TestBuildCumulativeTrees_Aggregates(t *testing.T) {
+	img := testImage(t)
+
+	// Layer 0: /etc/hello (6) + /usr/bin/app (10) + /lib/link (0)
+	root0 := img.Trees[0]
+	if root0.TotalSize != 16 {
+		t.Errorf("tree0 total size: expected 16, got %d", root0.TotalSize)
+	}
+	if root0.Descendants != 7 {
+		t.Errorf("tree0 descendants: expected 7, got %d", root0.Descendants)
+	}
+
+	// Layer 2: /etc/hello (7) + /var/new (4) + /lib/link; /usr deleted
+	root2 := img.Trees[2]
+	if root2.TotalSize != 11 {
+		t.Errorf("tree2 total size: expected 11, got %d", root2.TotalSize)
+	}
+	if etc := findNode(root2, "/etc"); etc.TotalSize != 7 || etc.Descendants != 1 {
+		t.Errorf("tree2 /etc: expected 7 bytes/1 entry, got %d/%d", etc.TotalSize, etc.Descendants)
+	}
+
+	// Earlier trees keep their own totals
+	if root0.TotalSize != 16 {
+		t.Errorf("tree0 total size changed after merge: %d", root0.TotalSize)
+	}
+}
+
+func TestBuildCumulativeTrees_Attribution(t *testing.T) {
+	img := testImage(t)
+	tree2 := img.Trees[2]
+
+	cases := []struct {
+		path              string
+		added, modifiedIn int
+	}{
+		{"/etc/hello", 0, 2},
+		{"/etc", 0, 0}, // only implied by /etc/hello in layer 2
+		{"/lib/link", 0, 0},
+		{"/var", 2, 2},
+		{"/var/new", 2, 2},
+	}
+	for _, c := range cases {
+		n := findNode(tree2, c.path)
+		if n == nil {
+			t.Fatalf("tree2 missing %s", c.path)
+		}
+		if n.AddedIn != c.added || n.ModifiedIn != c.modifiedIn {
+			t.Errorf("%s: expected added %d/modified %d, got %d/%d", c.path, c.added, c.modifiedIn, n.AddedIn, n.ModifiedIn)
+		}
+	}
+
+	// Shared history is not rewritten
+	if n := findNode(img.Trees[0], "/etc/hello"); n.ModifiedIn != 0 {
+		t.Errorf("tree0 /etc/hello should still be modified in 0, got %d", n.ModifiedIn)
+	}
+}
+
+func TestLayerTrees(t *testing.T) {
+	img := testImage(t)
+
+	if len(img.LayerTrees) != 3 {
+		t.Fatalf("expected 3 layer trees, got %d", len(img.LayerTrees))
+	}
+	if img.LayerTrees[1] != nil {
+		t.Error("empty layer should have no layer tree")
+	}
+
+	// Layer 2 only contains its own entries, with /usr shown as a whiteout
+	lookup := buildPathLookup(img.LayerTrees[2])
+	if _, ok := lookup["/lib/link"]; ok {
+		t.Error("layer tree 2 should not contain /lib/link from layer 0")
+	}
+	usr, ok := lookup["/usr"]
+	if !ok {
+		t.Fatal("layer tree 2 missing whiteout for /usr")
+	}
+	if usr.Type != FileTypeWhiteout {
+		t.Errorf("expected /usr to be a whiteout, got %s", usr.Type)
+	}
+}
+
 func TestReadFileFromLayer_Known(t *testing.T) {
 	img := testImage(t)
-	fc, err := img.ReadFile(0, "/etc/hello")
+	fc, err := img.ReadFile(t.Context(), 0, "/etc/hello")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -285,7 +573,7 @@ func TestReadFileFromLayer_Known(t *testing.T) {
 func TestReadFileFromLayer_Modified(t *testing.T) {
 	img := testImage(t)
 	// Layer index 2 is the second content layer
-	fc, err := img.ReadFile(2, "/etc/hello")
+	fc, err := img.ReadFile(t.Context(), 2, "/etc/hello")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -296,8 +584,123 @@ func TestReadFileFromLayer_Modified(t *testing.T) {
 
 func TestReadFileFromLayer_Missing(t *testing.T) {
 	img := testImage(t)
-	_, err := img.ReadFile(0, "/nonexistent")
+	_, err := img.ReadFile(t.Context(), 0, "/nonexistent")
 	if err == nil {
 		t.Fatal("expected error for missing file")
 	}
 }
+
+func TestReadFileFromLayer_Cancelled(t *testing.T) {
+	img := testImage(t)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := img.ReadFile(ctx, 2, "/etc/hello"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestBuildCumulativeTrees_Parallel(t *testing.T) {
+	var layers []v1.Layer
+	var emptyFlags []bool
+	for i := range 12 {
+		layers = append(layers, buildTarLayer(t, []tarEntry{
+			{name: "shared", typeflag: tar.TypeReg, data: []byte(strings.Repeat("x", i+1))},
+			{name: fmt.Sprintf("f%02d", i), typeflag: tar.TypeReg, data: []byte("data")},
+		}))
+		emptyFlags = append(emptyFlags, false, true)
+	}
+
+	want, _, wantSizes, _, err := buildCumulativeTrees(t.Context(), layers, emptyFlags, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, gotSizes, _, err := buildCumulativeTrees(t.Context(), layers, emptyFlags, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if len(computeDiff(want[i], got[i])) != 0 || gotSizes[i] != wantSizes[i] {
+			t.Fatalf("layer %d differs between 1 and 4 jobs", i)
+		}
+	}
+	top := findNode(got[len(got)-1], "/shared")
+	if top == nil || top.Size != 12 || top.ModifiedIn != 22 || top.AddedIn != 0 {
+		t.Errorf("expected /shared from the last layer, got %+v", top)
+	}
+}
+
+// failingLayer is a layer whose tar can't be read.
+type failingLayer struct{ v1.Layer }
+
+func (failingLayer) Uncompressed() (io.ReadCloser, error) { return nil, errors.New("boom") }
+
+func TestBuildCumulativeTrees_Error(t *testing.T) {
+	ok := buildTarLayer(t, []tarEntry{{name: "a", typeflag: tar.TypeReg, data: []byte("a")}})
+	layers := []v1.Layer{ok, ok, failingLayer{ok}, ok}
+	_, _, _, _, err := buildCumulativeTrees(t.Context(), layers, []bool{false, false, false, false}, 2, nil)
+	if err == nil || !strings.Contains(err.Error(), "layer 2: uncompress layer: boom") {
+		t.Errorf("expected layer 2 to fail, got %v", err)
+	}
+}
+
+// --- benchmarks ---
+
+// synthLayer builds a raw layer tree with files spread over dirs/filesPerDir
+// directories. Each layer writes the same paths with its own sizes, so later
+// layers touch a fixed subset of the tree.
+func synthLayer(layer, dirs, filesPerDir int) *FileNode {
+	root := &FileNode{Name: "/", Path: "/", Type: FileTypeDir}
+	for d := 0; d < dirs; d++ {
+		dirName := fmt.Sprintf("d%03d", d)
+		dir := &FileNode{Name: dirName, Path: "/" + dirName, Type: FileTypeDir}
+		for f := 0; f < filesPerDir; f++ {
+			name := fmt.Sprintf("f%04d", f)
+			dir.Children = append(dir.Children, &FileNode{
+				Name: name,
+				Path: dir.Path + "/" + name,
+				Type: FileTypeFile,
+				Size: int64(layer + f),
+			})
+		}
+		root.Children = append(root.Children, dir)
+	}
+	return root
+}
+
+// benchmarkCumulative merges a large base layer followed by many small layers,
+// reporting the heap retained by all cumulative trees.
+func benchmarkCumulative(b *testing.B, layers int) {
+	base := synthLayer(0, 200, 1000) // 200k files
+	overlays := make([]*FileNode, layers)
+	for i := range overlays {
+		overlays[i] = synthLayer(i+1, 2, 50)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	var retained uint64
+	for n := 0; n < b.N; n++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		trees := make([]*FileNode, 0, layers+1)
+		prev := mergeTrees(&FileNode{Name: "/", Path: "/", Type: FileTypeDir}, base)
+		trees = append(trees, prev)
+		for _, o := range overlays {
+			next := mergeTrees(prev, o)
+			computeDiff(prev, next)
+			trees = append(trees, next)
+			prev = next
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		retained = after.HeapAlloc - before.HeapAlloc
+		runtime.KeepAlive(trees)
+	}
+	b.ReportMetric(float64(retained), "retained-B")
+}
+
+func BenchmarkCumulativeTrees_10Layers(b *testing.B) { benchmarkCumulative(b, 10) }
+func BenchmarkCumulativeTrees_60Layers(b *testing.B) { benchmarkCumulative(b, 60) }
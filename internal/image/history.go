@@ -0,0 +1,83 @@
+package image
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coffee-cup/peel/internal/textdiff"
+)
+
+// PathHistory lists every layer that added, rewrote or deleted filePath, oldest first.
+func (im *Image) PathHistory(filePath string) []PathChange {
+	var changes []PathChange
+	var prev *FileNode
+	for i, tree := range im.Trees {
+		if im.Layers[i].Empty || tree == nil {
+			continue
+		}
+		var prevNode *FileNode
+		if prev != nil {
+			prevNode = findNode(prev, filePath)
+		}
+		node := findNode(tree, filePath)
+		prev = tree
+
+		change := PathChange{Layer: i, Command: im.Layers[i].Command, Instruction: im.Layers[i].Instruction}
+		switch {
+		case node != nil && node.ModifiedIn == i:
+			change.ChangeKind = ChangeModified
+			if prevNode == nil {
+				change.ChangeKind = ChangeAdded
+			}
+			change.Type = node.Type
+			change.Size = node.Size
+		case node == nil && prevNode != nil:
+			change.ChangeKind = ChangeDeleted
+			change.Type = prevNode.Type
+			change.Size = prevNode.Size
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// FileHistory returns filePath's content at every layer that changed it, with a
+// unified diff against the previous version. Binary files and non-regular
+// files carry no diff, and text over maxTextBytes is diffed up to the cut.
+func (im *Image) FileHistory(ctx context.Context, filePath string) ([]FileVersion, error) {
+	changes := im.PathHistory(filePath)
+	versions := make([]FileVersion, 0, len(changes))
+
+	prevContent, prevName, prevTruncated := "", "/dev/null", false
+	for _, c := range changes {
+		v := FileVersion{PathChange: c}
+		content, name := "", "/dev/null"
+
+		binary, truncated := false, false
+		if c.ChangeKind != ChangeDeleted && c.Type == FileTypeFile {
+			fc, err := im.ReadFile(ctx, c.Layer, filePath)
+			if err != nil {
+				return nil, fmt.Errorf("layer %d: %w", c.Layer, err)
+			}
+			v.Content = fc
+			name = versionName(c.Layer, filePath)
+			if binary = fc.IsBinary; !binary {
+				content, truncated = fc.Content, fc.Truncated
+			}
+		}
+
+		if !binary && (c.Type == FileTypeFile || c.ChangeKind == ChangeDeleted) {
+			v.Diff = textdiff.Unified(prevName, name, prevContent, content, textdiff.DefaultContext)
+			v.Truncated = truncated || prevTruncated
+		}
+		prevContent, prevName, prevTruncated = content, name, truncated
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+func versionName(layer int, filePath string) string {
+	return fmt.Sprintf("layer %d:%s", layer, filePath)
+}
@@ -0,0 +1,105 @@
+package image
+
+import (
+	"archive/tar"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+func TestPathHistory(t *testing.T) {
+	img := testImage(t)
+
+	hello := img.PathHistory("/etc/hello")
+	if len(hello) != 2 {
+		t.Fatalf("expected 2 changes to /etc/hello, got %v", hello)
+	}
+	if hello[0].Layer != 0 || hello[0].ChangeKind != ChangeAdded {
+		t.Errorf("expected added in 0, got %+v", hello[0])
+	}
+	if hello[1].Layer != 2 || hello[1].ChangeKind != ChangeModified || hello[1].Command != "COPY --from=0 / /" {
+		t.Errorf("expected modified in 2, got %+v", hello[1])
+	}
+
+	usr := img.PathHistory("/usr/bin/app")
+	if len(usr) != 2 || usr[1].ChangeKind != ChangeDeleted || usr[1].Layer != 2 {
+		t.Fatalf("expected /usr/bin/app added then deleted in 2, got %v", usr)
+	}
+
+	if got := img.PathHistory("/nope"); len(got) != 0 {
+		t.Errorf("expected no history for missing path, got %v", got)
+	}
+}
+
+func TestFileHistory(t *testing.T) {
+	img := testImage(t)
+
+	versions, err := img.FileHistory(t.Context(), "/etc/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(versions))
+	}
+	if versions[0].Content == nil || versions[0].Content.Content != "hello\n" {
+		t.Fatalf("unexpected first version content: %+v", versions[0].Content)
+	}
+	if !strings.Contains(versions[0].Diff, "+hello") {
+		t.Errorf("first version diff should add hello, got:\n%s", versions[0].Diff)
+	}
+	want := "--- layer 0:/etc/hello\n+++ layer 2:/etc/hello\n@@ -1 +1 @@\n-hello\n+hello2\n"
+	if versions[1].Diff != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", versions[1].Diff, want)
+	}
+}
+
+func TestFileHistory_Deleted(t *testing.T) {
+	img := testImage(t)
+
+	versions, err := img.FileHistory(t.Context(), "/usr/bin/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(versions))
+	}
+	deleted := versions[1]
+	if deleted.ChangeKind != ChangeDeleted || deleted.Content != nil {
+		t.Fatalf("expected deletion without content, got %+v", deleted)
+	}
+	if !strings.Contains(deleted.Diff, "-#!/bin/sh") {
+		t.Errorf("deletion diff should remove the old content, got:\n%s", deleted.Diff)
+	}
+}
+
+func TestFileHistory_Truncated(t *testing.T) {
+	big := strings.Repeat("line\n", maxTextBytes/5+1)
+	base := buildTarLayer(t, []tarEntry{{name: "log", typeflag: tar.TypeReg, data: []byte(big)}})
+	top := buildTarLayer(t, []tarEntry{{name: "log", typeflag: tar.TypeReg, data: []byte("short\n")}})
+	raw, err := mutate.Append(empty.Image,
+		mutate.Addendum{Layer: base, History: v1.History{CreatedBy: "COPY log /"}},
+		mutate.Addendum{Layer: top, History: v1.History{CreatedBy: "COPY log /"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := Analyze(t.Context(), raw, "test:truncated", AnalyzeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := img.FileHistory(t.Context(), "/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(versions))
+	}
+	// The rewrite is diffed against the cut first version
+	if !versions[0].Truncated || !versions[1].Truncated {
+		t.Errorf("expected both diffs flagged truncated, got %v and %v", versions[0].Truncated, versions[1].Truncated)
+	}
+}
@@ -1,10 +1,16 @@
 package image
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
+	"github.com/coffee-cup/peel/internal/dockerfile"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
@@ -13,9 +19,21 @@ const (
 	maxBinaryBytes = 16 << 10 // 16KB
 )
 
+// AnalyzeOptions tunes Analyze.
+type AnalyzeOptions struct {
+	// Jobs is how many layers are downloaded and read at once. Zero means
+	// one per CPU.
+	Jobs int
+
+	// Previous is an earlier analysis, typically of the same ref before a
+	// rebuild. Layers it shares by DiffID are not downloaded or read again.
+	Previous *Image
+}
+
 // Analyze extracts all metadata, builds filesystem trees, and computes diffs.
-// The returned Image is immutable and safe for concurrent reads.
-func Analyze(img v1.Image, ref string) (*Image, error) {
+// The returned Image is immutable and safe for concurrent reads. Cancelling
+// ctx stops reading layers.
+func Analyze(ctx context.Context, img v1.Image, ref string, opts AnalyzeOptions) (*Image, error) {
 	raw, err := img.RawConfigFile()
 	if err != nil {
 		return nil, fmt.Errorf("raw config: %w", err)
@@ -24,12 +42,21 @@ func Analyze(img v1.Image, ref string) (*Image, error) {
 	if err := json.Unmarshal(raw, &cf); err != nil {
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
+	var extra configExtras
+	if err := json.Unmarshal(raw, &extra); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
 
 	digest, err := img.Digest()
 	if err != nil {
 		return nil, fmt.Errorf("digest: %w", err)
 	}
 
+	manifest, err := manifestInfo(img)
+	if err != nil {
+		return nil, err
+	}
+
 	layers, err := img.Layers()
 	if err != nil {
 		return nil, fmt.Errorf("layers: %w", err)
@@ -44,17 +71,21 @@ func Analyze(img v1.Image, ref string) (*Image, error) {
 
 	for i, h := range history {
 		li := LayerInfo{
-			Index:   i,
-			Command: h.CreatedBy,
-			Empty:   h.EmptyLayer,
+			Index:       i,
+			Command:     h.CreatedBy,
+			Instruction: dockerfile.Normalize(h.CreatedBy),
+			Author:      h.Author,
+			Comment:     h.Comment,
+			Empty:       h.EmptyLayer,
+		}
+		if !h.Created.IsZero() {
+			created := h.Created.UTC()
+			li.Created = &created
 		}
 		if h.EmptyLayer {
 			emptyFlags = append(emptyFlags, true)
 		} else if contentIdx < len(layers) {
-			diffID, _ := layers[contentIdx].DiffID()
-			size, _ := layers[contentIdx].Size()
-			li.DiffID = diffID.String()
-			li.Size = size
+			setBlobInfo(&li, layers[contentIdx])
 			emptyFlags = append(emptyFlags, false)
 			contentIdx++
 		} else {
@@ -67,21 +98,20 @@ func Analyze(img v1.Image, ref string) (*Image, error) {
 	// If no history at all, synthesize from layers
 	if len(history) == 0 {
 		for i, l := range layers {
-			diffID, _ := l.DiffID()
-			size, _ := l.Size()
-			layerInfos = append(layerInfos, LayerInfo{
-				Index:  i,
-				DiffID: diffID.String(),
-				Size:   size,
-			})
+			li := LayerInfo{Index: i}
+			setBlobInfo(&li, l)
+			layerInfos = append(layerInfos, li)
 			emptyFlags = append(emptyFlags, false)
 		}
 	}
 
-	trees, err := buildCumulativeTrees(layers, emptyFlags)
+	trees, layerTrees, sizes, reused, err := buildCumulativeTrees(ctx, layers, emptyFlags, opts.Jobs, opts.Previous.layerCache())
 	if err != nil {
 		return nil, fmt.Errorf("trees: %w", err)
 	}
+	for i := range layerInfos {
+		layerInfos[i].UncompressedSize = sizes[i]
+	}
 
 	// Compute diffs between consecutive cumulative trees
 	diffs := make([][]DiffEntry, len(emptyFlags))
@@ -101,31 +131,195 @@ func Analyze(img v1.Image, ref string) (*Image, error) {
 		Digest:     digest.String(),
 		Arch:       cf.Architecture,
 		OS:         cf.OS,
+		Variant:    cf.Variant,
+		Author:     cf.Author,
 		LayerCount: len(layerInfos),
 		Config: ImageConfig{
-			Env:        cf.Config.Env,
-			Entrypoint: cf.Config.Entrypoint,
-			Cmd:        cf.Config.Cmd,
-			WorkingDir: cf.Config.WorkingDir,
-			User:       cf.Config.User,
-			Labels:     cf.Config.Labels,
+			Env:          cf.Config.Env,
+			Entrypoint:   cf.Config.Entrypoint,
+			Cmd:          cf.Config.Cmd,
+			WorkingDir:   cf.Config.WorkingDir,
+			User:         cf.Config.User,
+			Labels:       cf.Config.Labels,
+			ExposedPorts: sortedKeys(cf.Config.ExposedPorts),
+			Volumes:      sortedKeys(cf.Config.Volumes),
+			Healthcheck:  healthcheck(cf.Config.Healthcheck, extra.Config.Healthcheck.StartInterval),
+			StopSignal:   cf.Config.StopSignal,
+			Shell:        cf.Config.Shell,
+			OnBuild:      cf.Config.OnBuild,
+			ArgsEscaped:  cf.Config.ArgsEscaped,
 		},
+		RootFS:   RootFS{Type: cf.RootFS.Type, DiffIDs: []string{}},
+		Manifest: manifest,
+	}
+	if !cf.Created.IsZero() {
+		created := cf.Created.UTC()
+		info.Created = &created
+	}
+	for _, d := range cf.RootFS.DiffIDs {
+		info.RootFS.DiffIDs = append(info.RootFS.DiffIDs, d.String())
 	}
 
 	return &Image{
-		Info:   info,
-		Layers: layerInfos,
-		Trees:  trees,
-		Diffs:  diffs,
-		img:    img,
+		Info:       info,
+		Layers:     layerInfos,
+		Trees:      trees,
+		LayerTrees: layerTrees,
+		Diffs:      diffs,
+		Reused:     reused,
+		img:        img,
+	}, nil
+}
+
+// layerCache indexes the image's per-layer trees by DiffID for reuse by a
+// later analysis.
+func (im *Image) layerCache() map[v1.Hash]layerTree {
+	if im == nil {
+		return nil
+	}
+	cache := make(map[v1.Hash]layerTree)
+	for i, li := range im.Layers {
+		if li.Empty || im.LayerTrees[i] == nil {
+			continue
+		}
+		h, err := v1.NewHash(li.DiffID)
+		if err != nil {
+			continue
+		}
+		cache[h] = layerTree{tree: im.LayerTrees[i], size: li.UncompressedSize}
+	}
+	return cache
+}
+
+func manifestInfo(img v1.Image) (ManifestInfo, error) {
+	m, err := img.Manifest()
+	if err != nil {
+		return ManifestInfo{}, fmt.Errorf("manifest: %w", err)
+	}
+	raw, err := img.RawManifest()
+	if err != nil {
+		return ManifestInfo{}, fmt.Errorf("raw manifest: %w", err)
+	}
+	mediaType := m.MediaType
+	if mediaType == "" {
+		// Docker v2 manifests may omit it; the image knows what it was served as
+		mediaType, _ = img.MediaType()
+	}
+	return ManifestInfo{
+		MediaType:     string(mediaType),
+		SchemaVersion: m.SchemaVersion,
+		Size:          int64(len(raw)),
+		Config: Descriptor{
+			MediaType: string(m.Config.MediaType),
+			Digest:    m.Config.Digest.String(),
+			Size:      m.Config.Size,
+		},
+		Annotations: m.Annotations,
 	}, nil
 }
 
+// configExtras holds config fields v1.ConfigFile doesn't model.
+type configExtras struct {
+	Config struct {
+		Healthcheck struct {
+			StartInterval time.Duration
+		}
+	} `json:"config"`
+}
+
+func healthcheck(h *v1.HealthConfig, startInterval time.Duration) *Healthcheck {
+	if h == nil {
+		return nil
+	}
+	hc := &Healthcheck{Test: h.Test, Retries: h.Retries}
+	durations := []struct {
+		d   time.Duration
+		dst *string
+	}{
+		{h.Interval, &hc.Interval},
+		{h.Timeout, &hc.Timeout},
+		{h.StartPeriod, &hc.StartPeriod},
+		{startInterval, &hc.StartInterval},
+	}
+	for _, d := range durations {
+		if d.d != 0 {
+			*d.dst = d.d.String()
+		}
+	}
+	return hc
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(m))
+}
+
+// RawConfig returns the image's config file as stored.
+func (im *Image) RawConfig() ([]byte, error) {
+	return im.img.RawConfigFile()
+}
+
+// RawManifest returns the image's manifest as stored.
+func (im *Image) RawManifest() ([]byte, error) {
+	return im.img.RawManifest()
+}
+
+// setBlobInfo fills in the identifiers and compressed size of a content layer.
+func setBlobInfo(li *LayerInfo, l v1.Layer) {
+	diffID, _ := l.DiffID()
+	digest, _ := l.Digest()
+	mediaType, _ := l.MediaType()
+	size, _ := l.Size()
+	li.DiffID = diffID.String()
+	li.Digest = digest.String()
+	li.MediaType = string(mediaType)
+	li.Size = size
+}
+
+// DiffRange compares the cumulative filesystem after layer from with the one
+// after layer to. from may be -1 to compare against an empty filesystem.
+func (im *Image) DiffRange(from, to int) ([]DiffEntry, error) {
+	if from < -1 || from >= len(im.Trees) {
+		return nil, fmt.Errorf("from layer %d out of range [-1, %d)", from, len(im.Trees))
+	}
+	if to < 0 || to >= len(im.Trees) {
+		return nil, fmt.Errorf("to layer %d out of range [0, %d)", to, len(im.Trees))
+	}
+	var prev *FileNode
+	if from >= 0 {
+		prev = im.Trees[from]
+	}
+	diffs := computeDiff(prev, im.Trees[to])
+	if diffs == nil {
+		diffs = []DiffEntry{}
+	}
+	return diffs, nil
+}
+
+// Dockerfile reconstructs the image's Dockerfile from its history, one
+// instruction per layer.
+func (im *Image) Dockerfile() string {
+	lines := make([]string, len(im.Layers))
+	for i, l := range im.Layers {
+		lines[i] = l.Instruction
+	}
+	return "# Reconstructed from the history of " + im.Info.Ref + "\n" +
+		"# Base image instructions are included; the original FROM line is not recorded.\n\n" +
+		dockerfile.Format(lines)
+}
+
+// ErrNotFound is returned for reads of layers or paths the image doesn't have.
+var ErrNotFound = errors.New("not found")
+
 // ReadFile reads file content from the cumulative filesystem at the given layer.
-// Resolves symlinks before reading. Searches backward through content layers.
-func (im *Image) ReadFile(layerIdx int, filePath string) (*FileContent, error) {
+// Resolves symlinks before reading. Searches backward through content layers
+// until the file is found or ctx is done. Missing layers and files give an
+// error wrapping ErrNotFound.
+func (im *Image) ReadFile(ctx context.Context, layerIdx int, filePath string) (*FileContent, error) {
 	if layerIdx < 0 || layerIdx >= len(im.Layers) {
-		return nil, fmt.Errorf("layer index %d out of range [0, %d)", layerIdx, len(im.Layers))
+		return nil, fmt.Errorf("layer index %d out of range [0, %d): %w", layerIdx, len(im.Layers), ErrNotFound)
 	}
 
 	layers, err := im.img.Layers()
@@ -152,7 +346,7 @@ func (im *Image) ReadFile(layerIdx int, filePath string) (*FileContent, error) {
 		}
 	}
 
-	data, size, err := readFileFromLayer(layers, emptyFlags, layerIdx, readPath)
+	data, size, err := readFileFromLayer(ctx, layers, emptyFlags, layerIdx, readPath)
 	if err != nil {
 		return nil, err
 	}
@@ -2,7 +2,11 @@ package image
 
 import (
 	"archive/tar"
+	"fmt"
+	"reflect"
 	"runtime"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -93,7 +97,7 @@ func TestAnalyze_ReversedDaemonHistory(t *testing.T) {
 	}
 
 	wrapped := &reversedHistoryImage{img}
-	result, err := Analyze(wrapped, "test:reversed")
+	result, err := Analyze(t.Context(), wrapped, "test:reversed", AnalyzeOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -125,6 +129,102 @@ func TestAnalyze_ReversedDaemonHistory(t *testing.T) {
 	}
 }
 
+func TestAnalyze_HistoryMetadata(t *testing.T) {
+	var data []tarEntry
+	for i := range 20 {
+		data = append(data, tarEntry{name: fmt.Sprintf("f%d", i), typeflag: tar.TypeReg, data: []byte(strings.Repeat("a", 4096))})
+	}
+	layer := buildTarLayer(t, data)
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	img, err := mutate.Append(empty.Image,
+		mutate.Addendum{
+			Layer:   layer,
+			History: v1.History{CreatedBy: "COPY . /", Created: v1.Time{Time: created}, Author: "ops@example.com", Comment: "buildkit.dockerfile.v0"},
+		},
+		mutate.Addendum{
+			History: v1.History{CreatedBy: "USER app", EmptyLayer: true},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Analyze(t.Context(), img, "test:meta", AnalyzeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := result.Layers[0]
+	if l.Created == nil || !l.Created.Equal(created) {
+		t.Errorf("created: got %v, want %v", l.Created, created)
+	}
+	if l.Author != "ops@example.com" || l.Comment != "buildkit.dockerfile.v0" {
+		t.Errorf("unexpected author/comment: %q %q", l.Author, l.Comment)
+	}
+	if !strings.HasPrefix(l.Digest, "sha256:") || l.Digest == l.DiffID || l.MediaType == "" {
+		t.Errorf("unexpected blob info: digest=%s diffID=%s mediaType=%s", l.Digest, l.DiffID, l.MediaType)
+	}
+	// 20 files of 4KB plus headers; highly repetitive data compresses well
+	if l.UncompressedSize < 20*4096 || l.UncompressedSize%512 != 0 || l.Size >= l.UncompressedSize {
+		t.Errorf("unexpected sizes: compressed=%d uncompressed=%d", l.Size, l.UncompressedSize)
+	}
+
+	if e := result.Layers[1]; e.Created != nil || e.UncompressedSize != 0 || e.Digest != "" {
+		t.Errorf("empty layer should have no created time or blob info: %+v", e)
+	}
+}
+
+func TestDiffRange(t *testing.T) {
+	img := testImage(t)
+
+	// Everything from layer 0 through 2 against an empty filesystem
+	all, err := img.DiffRange(-1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range all {
+		if d.ChangeKind != ChangeAdded {
+			t.Errorf("expected only additions from empty, got %+v", d)
+		}
+	}
+
+	// Same as the consecutive diff when the range spans one content layer
+	net, err := img.DiffRange(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(net) != len(img.Diffs[2]) {
+		t.Errorf("expected %d entries, got %d", len(img.Diffs[2]), len(net))
+	}
+
+	// Reversed range flips additions and deletions
+	rev, err := img.DiffRange(2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]ChangeKind{}
+	for _, d := range rev {
+		kinds[d.Path] = d.ChangeKind
+	}
+	if kinds["/var/new"] != ChangeDeleted || kinds["/usr/bin/app"] != ChangeAdded {
+		t.Errorf("unexpected reversed diff: %v", kinds)
+	}
+
+	if _, err := img.DiffRange(0, 3); err == nil {
+		t.Error("expected error for out of range layer")
+	}
+}
+
+func TestDockerfile(t *testing.T) {
+	img := testImage(t)
+	img.Layers[1].Instruction = ""
+
+	got := img.Dockerfile()
+	want := "ADD . /\n# (no build history)\nCOPY --from=0 / /\n"
+	if !strings.HasPrefix(got, "# Reconstructed from the history of test:latest\n") || !strings.HasSuffix(got, want) {
+		t.Fatalf("unexpected Dockerfile:\n%s", got)
+	}
+}
+
 func TestAnalyze_ViaTestImage(t *testing.T) {
 	img := testImage(t)
 
@@ -156,6 +256,9 @@ func TestAnalyze_ViaTestImage(t *testing.T) {
 	if img.Layers[1].Command != "ENV A=1" {
 		t.Errorf("unexpected command: %s", img.Layers[1].Command)
 	}
+	if img.Layers[2].Instruction != "COPY --from=0 / /" {
+		t.Errorf("unexpected instruction: %s", img.Layers[2].Instruction)
+	}
 
 	// Image info
 	if img.Info.Ref != "test:latest" {
@@ -164,4 +267,103 @@ func TestAnalyze_ViaTestImage(t *testing.T) {
 	if img.Info.Arch != "amd64" || img.Info.OS != "linux" {
 		t.Errorf("unexpected platform: %s/%s", img.Info.OS, img.Info.Arch)
 	}
+
+	// Full config
+	cfg := img.Info.Config
+	if !slices.Equal(cfg.ExposedPorts, []string{"53/udp", "8080/tcp"}) {
+		t.Errorf("unexpected ports: %v", cfg.ExposedPorts)
+	}
+	if hc := cfg.Healthcheck; hc == nil || hc.Interval != "30s" || hc.Timeout != "" || hc.Retries != 3 {
+		t.Errorf("unexpected healthcheck: %+v", hc)
+	}
+	if cfg.StopSignal != "SIGQUIT" {
+		t.Errorf("unexpected stop signal: %s", cfg.StopSignal)
+	}
+	if img.Info.RootFS.Type != "layers" || len(img.Info.RootFS.DiffIDs) != 2 || img.Info.RootFS.DiffIDs[1] != img.Layers[2].DiffID {
+		t.Errorf("unexpected rootfs: %+v", img.Info.RootFS)
+	}
+
+	// Manifest
+	m := img.Info.Manifest
+	if m.SchemaVersion != 2 || m.MediaType == "" || m.Size == 0 {
+		t.Errorf("unexpected manifest: %+v", m)
+	}
+	raw, err := img.RawConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Config.Size != int64(len(raw)) || !strings.HasPrefix(m.Config.Digest, "sha256:") {
+		t.Errorf("unexpected config descriptor: %+v", m.Config)
+	}
+}
+
+// rawConfigImage serves raw as the image's config, for fields v1.ConfigFile
+// doesn't model.
+type rawConfigImage struct {
+	v1.Image
+	raw []byte
+}
+
+func (r *rawConfigImage) RawConfigFile() ([]byte, error) { return r.raw, nil }
+
+func TestAnalyze_HealthcheckStartInterval(t *testing.T) {
+	raw := `{"architecture":"amd64","os":"linux","config":{"Healthcheck":{"Test":["CMD","true"],"StartPeriod":60000000000,"StartInterval":5000000000}},"rootfs":{"type":"layers"}}`
+	img, err := Analyze(t.Context(), &rawConfigImage{Image: empty.Image, raw: []byte(raw)}, "test:health", AnalyzeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hc := img.Info.Config.Healthcheck; hc == nil || hc.StartPeriod != "1m0s" || hc.StartInterval != "5s" {
+		t.Errorf("unexpected healthcheck: %+v", hc)
+	}
+}
+
+func TestAnalyze_ReusesPreviousLayers(t *testing.T) {
+	base := buildTarLayer(t, []tarEntry{
+		{name: "etc/", typeflag: tar.TypeDir},
+		{name: "etc/a", typeflag: tar.TypeReg, data: []byte("a")},
+		{name: "opt/", typeflag: tar.TypeDir},
+		{name: "opt/old", typeflag: tar.TypeReg, data: []byte("old")},
+	})
+	top := buildTarLayer(t, []tarEntry{
+		{name: "etc/.wh.a", typeflag: tar.TypeReg},
+		{name: "opt/", typeflag: tar.TypeDir},
+		{name: "opt/.wh..wh..opq", typeflag: tar.TypeReg},
+		{name: "opt/new", typeflag: tar.TypeReg, data: []byte("new")},
+	})
+	changed := buildTarLayer(t, []tarEntry{{name: "app", typeflag: tar.TypeReg, data: []byte("v2")}})
+
+	build := func(layers ...v1.Layer) v1.Image {
+		t.Helper()
+		img, err := mutate.AppendLayers(empty.Image, layers...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	first, err := Analyze(t.Context(), build(base, top), "test:1", AnalyzeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the unchanged top layer moves up one place
+	next := build(base, changed, top)
+	reanalyzed, err := Analyze(t.Context(), next, "test:2", AnalyzeOptions{Previous: first})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := Analyze(t.Context(), next, "test:2", AnalyzeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reanalyzed.Reused != 2 || fresh.Reused != 0 {
+		t.Errorf("expected 2 reused layers, got %d (fresh %d)", reanalyzed.Reused, fresh.Reused)
+	}
+	for i := range fresh.Trees {
+		if !reflect.DeepEqual(reanalyzed.Trees[i], fresh.Trees[i]) || !reflect.DeepEqual(reanalyzed.LayerTrees[i], fresh.LayerTrees[i]) {
+			t.Errorf("layer %d: reused trees differ from a fresh analysis", i)
+		}
+		if reanalyzed.Layers[i].UncompressedSize != fresh.Layers[i].UncompressedSize {
+			t.Errorf("layer %d: size %d, want %d", i, reanalyzed.Layers[i].UncompressedSize, fresh.Layers[i].UncompressedSize)
+		}
+	}
 }
@@ -1,55 +1,261 @@
 package image
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"runtime"
 	"strings"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/v1"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/daemon"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 )
 
-// ParsePlatform parses "os/arch" into a v1.Platform.
+// ParsePlatform parses "os/arch[/variant]" into a v1.Platform.
 // Returns the host platform if s is empty.
 func ParsePlatform(s string) (v1.Platform, error) {
 	if s == "" {
 		return v1.Platform{OS: "linux", Architecture: runtime.GOARCH}, nil
 	}
-	parts := strings.SplitN(s, "/", 2)
-	if len(parts) != 2 {
-		return v1.Platform{}, fmt.Errorf("invalid platform %q, expected os/arch", s)
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return v1.Platform{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
 	}
-	return v1.Platform{OS: parts[0], Architecture: parts[1]}, nil
+	p := v1.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+// LoadOptions configures where and how LoadImage finds an image.
+type LoadOptions struct {
+	Platform   v1.Platform
+	Registries *Registries // nil uses the default keychain
+	Source     Source      // empty means SourceAuto
+
+	// ExplicitPlatform is set when the user chose Platform. Otherwise it is
+	// the host's, and a local image built for another platform is used
+	// as is instead of pulling a matching one.
+	ExplicitPlatform bool
+
+	// Offline forbids registry access. Loads that would need it fail
+	// instead.
+	Offline bool
+
+	// ContainerdRoot is the containerd content store directory for
+	// SourceContainerd. Empty tries the usual locations.
+	ContainerdRoot string
 }
 
-// LoadImage resolves an image reference, trying the local Docker daemon first,
-// then falling back to a remote registry.
-func LoadImage(ref string, platform v1.Platform) (v1.Image, error) {
-	parsed, err := name.ParseReference(ref)
+// LoadImage resolves an image reference from opts.Source, reporting which
+// image it picked and why. The default tries the local Docker daemon first,
+// then falls back to a remote registry, via its mirror if one is configured.
+// ctx bounds the lookup and, for registry images, later layer downloads.
+func LoadImage(ctx context.Context, ref string, opts LoadOptions) (v1.Image, *Resolution, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	img, res, err := load(ctx, ref, opts)
 	if err != nil {
-		return nil, fmt.Errorf("parse %s: %w", ref, err)
+		return nil, nil, fmt.Errorf("load %s: %w", ref, err)
 	}
+	return img, res, nil
+}
 
-	// Try local daemon first
-	img, err := daemon.Image(parsed)
-	if err == nil {
-		cfg, cfgErr := img.ConfigFile()
-		if cfgErr == nil && cfg.Architecture == platform.Architecture && cfg.OS == platform.OS {
-			return img, nil
+func load(ctx context.Context, ref string, opts LoadOptions) (v1.Image, *Resolution, error) {
+	switch opts.Source {
+	case SourcePodman:
+		if opts.Offline && !strings.HasPrefix(podmanHost(), "unix://") {
+			return nil, nil, fmt.Errorf("--offline: podman host %s is remote", podmanHost())
 		}
-		// Platform mismatch or config error — fall through to remote
+		img, err := loadPodman(ctx, ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := checkLocal(img, SourcePodman, opts)
+		return img, res, err
+	case SourceContainerd:
+		return loadLocal(SourceContainerd, opts, func() (v1.Image, error) {
+			return loadContainerd(ref, opts.ContainerdRoot, opts.Platform)
+		})
+	case SourceOCI:
+		return loadLocal(SourceOCI, opts, func() (v1.Image, error) {
+			return loadOCI(ref, opts.Platform)
+		})
+	case SourceDocker:
+		return loadDaemon(ctx, ref, opts)
+	case SourceRemote:
+		if opts.Offline {
+			return nil, nil, errors.New("--offline can't load from --source remote")
+		}
+		return loadRemote(ctx, ref, opts, "")
+	case SourceAuto, "":
+		img, res, err := loadDaemon(ctx, ref, opts)
+		if err == nil {
+			return img, res, nil
+		}
+		if opts.Offline {
+			return nil, nil, fmt.Errorf("%w; --offline won't pull it from the registry", err)
+		}
+		return loadRemote(ctx, ref, opts, err.Error()+"; pulled from the registry")
+	default:
+		return nil, nil, fmt.Errorf("unknown source %q", opts.Source)
 	}
+}
 
-	// Fallback to remote registry
-	img, err = remote.Image(parsed,
+// loadLocal loads from an on-disk store, which picks from indexes by
+// platform itself.
+func loadLocal(src Source, opts LoadOptions, fn func() (v1.Image, error)) (v1.Image, *Resolution, error) {
+	img, err := fn()
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := checkLocal(img, src, opts)
+	return img, res, err
+}
+
+// loadDaemon loads ref from the local Docker daemon.
+func loadDaemon(ctx context.Context, ref string, opts LoadOptions) (v1.Image, *Resolution, error) {
+	parsed, err := opts.Registries.Parse(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	img, err := daemon.Image(parsed, daemon.WithContext(ctx))
+	if err != nil {
+		return nil, nil, fmt.Errorf("not in the local Docker daemon (%v)", err)
+	}
+	res, err := checkLocal(img, SourceDocker, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, res, nil
+}
+
+// loadRemote pulls ref from its registry, trying the mirror first, and picks
+// from an index by platform. why explains the pull when another source was
+// tried first.
+func loadRemote(ctx context.Context, ref string, opts LoadOptions, why string) (v1.Image, *Resolution, error) {
+	reg := opts.Registries
+	parsed, err := reg.Parse(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	ropts := append([]remote.Option{
 		remote.WithAuthFromKeychain(authn.DefaultKeychain),
-		remote.WithPlatform(platform),
-	)
+		remote.WithContext(ctx),
+	}, reg.Options()...)
+	for _, candidate := range reg.Candidates(parsed) {
+		var img v1.Image
+		var picked string
+		img, picked, err = fetchRemote(candidate, opts.Platform, ropts)
+		if err != nil {
+			continue
+		}
+		have, err := configPlatform(img)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !platformMatches(have, opts.Platform) && opts.ExplicitPlatform {
+			return nil, nil, fmt.Errorf("registry image is %s, not %s", have, opts.Platform)
+		}
+		res := &Resolution{Source: SourceRemote, Platform: have.String(), Requested: opts.Platform.String(), Reason: why}
+		if res.Reason == "" {
+			res.Reason = "pulled from the registry"
+		}
+		if !platformMatches(have, opts.Platform) {
+			res.Reason += "; the registry only has " + have.String()
+		}
+		if host := candidate.Context().RegistryStr(); host != parsed.Context().RegistryStr() {
+			res.Reason += " via mirror " + host
+		}
+		if picked != "" {
+			res.Reason += "; " + picked
+		}
+		return img, res, nil
+	}
+	return nil, nil, err
+}
+
+// fetchRemote fetches ref, resolving an index to the entry for platform.
+// picked describes that choice.
+func fetchRemote(ref name.Reference, platform v1.Platform, opts []remote.Option) (img v1.Image, picked string, err error) {
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return nil, "", err
+	}
+	if !desc.MediaType.IsIndex() {
+		img, err = desc.Image()
+		return img, "", err
+	}
+	idx, err := desc.ImageIndex()
+	if err != nil {
+		return nil, "", err
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, "", err
+	}
+	m, err := pickManifest(im.Manifests, platform)
 	if err != nil {
-		return nil, fmt.Errorf("load %s: %w", ref, err)
+		return nil, "", err
+	}
+	img, err = idx.Image(m.Digest)
+	return img, fmt.Sprintf("picked %s from the index", m.Platform), err
+}
+
+// Fingerprint identifies the image ref currently resolves to, cheaply enough
+// to poll for rebuilds: the config digest of a local image, or the digest a
+// registry reports for a HEAD request, which doesn't count against pull
+// limits.
+func Fingerprint(ctx context.Context, ref string, opts LoadOptions) (string, error) {
+	switch opts.Source {
+	case SourceRemote:
+		if opts.Offline {
+			return "", errors.New("--offline can't watch --source remote")
+		}
+		return headRemote(ctx, ref, opts)
+	case SourceAuto, "":
+		if img, _, err := loadDaemon(ctx, ref, opts); err == nil {
+			return configName(img)
+		} else if opts.Offline {
+			return "", err
+		}
+		return headRemote(ctx, ref, opts)
+	}
+	img, _, err := load(ctx, ref, opts)
+	if err != nil {
+		return "", err
+	}
+	return configName(img)
+}
+
+func configName(img v1.Image) (string, error) {
+	h, err := img.ConfigName()
+	if err != nil {
+		return "", err
+	}
+	return h.String(), nil
+}
+
+func headRemote(ctx context.Context, ref string, opts LoadOptions) (string, error) {
+	reg := opts.Registries
+	parsed, err := reg.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	ropts := append([]remote.Option{
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		remote.WithContext(ctx),
+	}, reg.Options()...)
+	for _, candidate := range reg.Candidates(parsed) {
+		var desc *v1.Descriptor
+		if desc, err = remote.Head(candidate, ropts...); err == nil {
+			return desc.Digest.String(), nil
+		}
 	}
-	return img, nil
+	return "", err
 }
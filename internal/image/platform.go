@@ -0,0 +1,82 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Resolution records which image LoadImage picked and why, so a platform
+// mismatch or a registry pull is never silent.
+type Resolution struct {
+	Source    Source `json:"source"`
+	Platform  string `json:"platform"`  // platform of the loaded image
+	Requested string `json:"requested"` // platform that was asked for
+	Reason    string `json:"reason"`
+}
+
+// defaultVariants are the variants assumed when a platform omits one, as
+// containerd does.
+var defaultVariants = map[string]string{"arm64": "v8", "arm": "v7"}
+
+func variant(p v1.Platform) string {
+	if p.Variant == "" {
+		return defaultVariants[p.Architecture]
+	}
+	return p.Variant
+}
+
+// platformMatches reports whether an image built for have can serve want.
+// A variant in want must match, with arm64 and arm defaulting to v8 and v7.
+func platformMatches(have, want v1.Platform) bool {
+	if have.OS != want.OS || have.Architecture != want.Architecture {
+		return false
+	}
+	return want.Variant == "" || variant(have) == variant(want)
+}
+
+// configPlatform returns the platform an image's config declares.
+func configPlatform(img v1.Image) (v1.Platform, error) {
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return v1.Platform{}, err
+	}
+	return v1.Platform{OS: cfg.OS, Architecture: cfg.Architecture, Variant: cfg.Variant}, nil
+}
+
+// pickManifest chooses the index entry for want.
+func pickManifest(manifests []v1.Descriptor, want v1.Platform) (v1.Descriptor, error) {
+	var available []string
+	for _, m := range manifests {
+		// attestation manifests are listed as unknown/unknown
+		if m.Platform == nil || m.Platform.OS == "unknown" {
+			continue
+		}
+		if platformMatches(*m.Platform, want) {
+			return m, nil
+		}
+		available = append(available, m.Platform.String())
+	}
+	return v1.Descriptor{}, fmt.Errorf("no %s image in index (has %s); pick one with --platform", want, strings.Join(available, ", "))
+}
+
+// checkLocal decides whether an image from a local store serves opts. When
+// the platform was only defaulted to the host's, a mismatched local image is
+// still used rather than pulling another, and the reason says so.
+func checkLocal(img v1.Image, src Source, opts LoadOptions) (*Resolution, error) {
+	have, err := configPlatform(img)
+	if err != nil {
+		return nil, err
+	}
+	res := &Resolution{Source: src, Platform: have.String(), Requested: opts.Platform.String()}
+	switch {
+	case platformMatches(have, opts.Platform):
+		res.Reason = fmt.Sprintf("%s image matches %s", src, opts.Platform)
+	case !opts.ExplicitPlatform:
+		res.Reason = fmt.Sprintf("%s image is %s, not the host's %s; pass --platform to require a match", src, have, opts.Platform)
+	default:
+		return nil, fmt.Errorf("%s image is %s, not %s", src, have, opts.Platform)
+	}
+	return res, nil
+}
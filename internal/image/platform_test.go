@@ -0,0 +1,109 @@
+package image
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestParsePlatform_Variant(t *testing.T) {
+	p, err := ParsePlatform("linux/arm/v6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.OS != "linux" || p.Architecture != "arm" || p.Variant != "v6" {
+		t.Errorf("unexpected platform: %+v", p)
+	}
+	for _, bad := range []string{"linux", "linux/", "linux/arm/v7/x"} {
+		if _, err := ParsePlatform(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestPlatformMatches(t *testing.T) {
+	tests := []struct {
+		have, want v1.Platform
+		match      bool
+	}{
+		{v1.Platform{OS: "linux", Architecture: "arm64"}, v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, true},
+		{v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, v1.Platform{OS: "linux", Architecture: "arm64"}, true},
+		{v1.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, false},
+		{v1.Platform{OS: "linux", Architecture: "arm"}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, true},
+		{v1.Platform{OS: "linux", Architecture: "amd64"}, v1.Platform{OS: "linux", Architecture: "arm64"}, false},
+	}
+	for _, tt := range tests {
+		if got := platformMatches(tt.have, tt.want); got != tt.match {
+			t.Errorf("platformMatches(%s, %s) = %v, want %v", tt.have, tt.want, got, tt.match)
+		}
+	}
+}
+
+func TestLoadImage_Resolution(t *testing.T) {
+	dir := t.TempDir()
+	arm := v1.Platform{OS: "linux", Architecture: "arm64"}
+	img := platformImage(t, arm)
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendImage(img); err != nil {
+		t.Fatal(err)
+	}
+
+	// a defaulted platform keeps the local image and says why
+	_, res, err := LoadImage(t.Context(), dir, LoadOptions{Platform: testPlatform, Source: SourceOCI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Platform != "linux/arm64" || res.Requested != "linux/amd64" || !strings.Contains(res.Reason, "--platform") {
+		t.Errorf("unexpected resolution: %+v", res)
+	}
+
+	if _, _, err := LoadImage(t.Context(), dir, LoadOptions{Platform: testPlatform, ExplicitPlatform: true, Source: SourceOCI}); err == nil {
+		t.Error("expected error for an explicit platform mismatch")
+	}
+	if _, _, err := LoadImage(t.Context(), dir, LoadOptions{Platform: v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, ExplicitPlatform: true, Source: SourceOCI}); err != nil {
+		t.Errorf("arm64/v8 should match arm64: %v", err)
+	}
+}
+
+func TestLoadImage_RemoteIndex(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+
+	arm := v1.Platform{OS: "linux", Architecture: "arm64"}
+	idx, imgs := platformIndex(t, testPlatform, arm)
+	ref := strings.TrimPrefix(srv.URL, "http://") + "/library/multi:latest"
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.WriteIndex(parsed, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	img, res, err := LoadImage(t.Context(), ref, LoadOptions{Platform: v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, Source: SourceRemote})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digestOf(t, img) != digestOf(t, imgs[1]) {
+		t.Error("expected the arm64 image")
+	}
+	if res.Source != SourceRemote || !strings.Contains(res.Reason, "picked linux/arm64") {
+		t.Errorf("unexpected resolution: %+v", res)
+	}
+
+	if _, _, err := LoadImage(t.Context(), ref, LoadOptions{Platform: testPlatform, Source: SourceRemote, Offline: true}); err == nil {
+		t.Error("expected --offline to refuse a registry pull")
+	}
+}
@@ -0,0 +1,175 @@
+package image
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/coffee-cup/peel/internal/config"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Registries holds how registries are reached: credentials, TLS settings
+// and mirrors. A nil *Registries uses the defaults.
+type Registries struct {
+	cfg  *config.Config
+	opts []remote.Option
+}
+
+// NewRegistries builds registry access from the peel config. authConfig
+// replaces the default Docker config, and caCerts are PEM files trusted for
+// every registry in addition to the system roots.
+func NewRegistries(cfg *config.Config, authConfig string, caCerts []string) (*Registries, error) {
+	kc, err := Keychain(cfg, authConfig)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := newTransport(cfg, caCerts)
+	if err != nil {
+		return nil, err
+	}
+	return &Registries{
+		cfg:  cfg,
+		opts: []remote.Option{remote.WithAuthFromKeychain(kc), remote.WithTransport(rt)},
+	}, nil
+}
+
+// Options returns the remote options for reaching any registry.
+func (r *Registries) Options() []remote.Option {
+	if r == nil {
+		return nil
+	}
+	return r.opts
+}
+
+// Parse parses ref, allowing plain HTTP for registries marked insecure.
+func (r *Registries) Parse(ref string) (name.Reference, error) {
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ref, err)
+	}
+	if r.insecure(parsed.Context().RegistryStr()) {
+		return name.ParseReference(ref, name.Insecure)
+	}
+	return parsed, nil
+}
+
+// ParseRepository parses a repository such as ghcr.io/org/app.
+func (r *Registries) ParseRepository(repo string) (name.Repository, error) {
+	parsed, err := name.NewRepository(repo)
+	if err != nil {
+		return name.Repository{}, fmt.Errorf("parse %s: %w", repo, err)
+	}
+	if r.insecure(parsed.RegistryStr()) {
+		return name.NewRepository(repo, name.Insecure)
+	}
+	return parsed, nil
+}
+
+// ParseRegistry parses a registry host.
+func (r *Registries) ParseRegistry(host string) (name.Registry, error) {
+	parsed, err := name.NewRegistry(host)
+	if err != nil {
+		return name.Registry{}, fmt.Errorf("parse %s: %w", host, err)
+	}
+	if r.insecure(parsed.RegistryStr()) {
+		return name.NewRegistry(host, name.Insecure)
+	}
+	return parsed, nil
+}
+
+func (r *Registries) insecure(host string) bool {
+	return r != nil && r.cfg.Registry(host).Insecure
+}
+
+// Candidates returns where to pull ref from, in order: its registry's
+// mirror, if configured, then the registry itself.
+func (r *Registries) Candidates(ref name.Reference) []name.Reference {
+	if r == nil {
+		return []name.Reference{ref}
+	}
+	mirror := r.cfg.Registry(ref.Context().RegistryStr()).Mirror
+	if mirror == "" {
+		return []name.Reference{ref}
+	}
+	var opts []name.Option
+	if r.cfg.Registry(mirror).Insecure {
+		opts = append(opts, name.Insecure)
+	}
+	// Keep the repository path, so docker.io/nginx becomes mirror/library/nginx
+	rewritten := mirror + "/" + ref.Context().RepositoryStr()
+	if d, ok := ref.(name.Digest); ok {
+		rewritten += "@" + d.DigestStr()
+	} else {
+		rewritten += ":" + ref.Identifier()
+	}
+	m, err := name.ParseReference(rewritten, opts...)
+	if err != nil {
+		return []name.Reference{ref}
+	}
+	return []name.Reference{m, ref}
+}
+
+// newTransport returns a transport trusting caCerts everywhere, and each
+// registry's own CA and insecure setting for requests to that host.
+func newTransport(cfg *config.Config, caCerts []string) (http.RoundTripper, error) {
+	base, err := tlsTransport(caCerts, false)
+	if err != nil {
+		return nil, err
+	}
+	hosts := map[string]http.RoundTripper{}
+	for host, r := range cfg.Registries {
+		if !r.Insecure && r.CACert == "" {
+			continue
+		}
+		certs := caCerts
+		if r.CACert != "" {
+			certs = append(append([]string{}, caCerts...), r.CACert)
+		}
+		t, err := tlsTransport(certs, r.Insecure)
+		if err != nil {
+			return nil, fmt.Errorf("registry %s: %w", host, err)
+		}
+		hosts[host] = t
+	}
+	return &hostTransport{hosts: hosts, fallback: base}, nil
+}
+
+func tlsTransport(caCerts []string, insecure bool) (*http.Transport, error) {
+	t := remote.DefaultTransport.(*http.Transport).Clone()
+	if len(caCerts) == 0 && !insecure {
+		return t, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, path := range caCerts {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s: no PEM certificates found", path)
+		}
+	}
+	t.TLSClientConfig = &tls.Config{RootCAs: pool, InsecureSkipVerify: insecure}
+	return t, nil
+}
+
+// hostTransport routes requests by registry host.
+type hostTransport struct {
+	hosts    map[string]http.RoundTripper
+	fallback http.RoundTripper
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt, ok := t.hosts[config.RegistryKey(strings.ToLower(req.URL.Host))]; ok {
+		return rt.RoundTrip(req)
+	}
+	return t.fallback.RoundTrip(req)
+}
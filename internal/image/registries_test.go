@@ -0,0 +1,140 @@
+package image
+
+import (
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coffee-cup/peel/internal/config"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+var testPlatform = v1.Platform{OS: "linux", Architecture: "amd64"}
+
+// pushRandom pushes a random image to host and returns its reference.
+func pushRandom(t *testing.T, host string, opts ...remote.Option) string {
+	t.Helper()
+	ref := host + "/library/app:latest"
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(parsed, img, opts...); err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+func emptyConfig() *config.Config {
+	return &config.Config{Registries: map[string]config.Registry{}}
+}
+
+func TestLoadImage_CACert(t *testing.T) {
+	srv := httptest.NewTLSServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+	ref := pushRandom(t, host, remote.WithTransport(srv.Client().Transport))
+
+	if _, _, err := LoadImage(t.Context(), ref, LoadOptions{Platform: testPlatform}); err == nil {
+		t.Fatal("expected an unknown CA to fail")
+	}
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caPath, pemData, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := NewRegistries(emptyConfig(), "", []string{caPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadImage(t.Context(), ref, LoadOptions{Platform: testPlatform, Registries: reg}); err != nil {
+		t.Fatalf("expected --ca-cert to be trusted: %v", err)
+	}
+
+	cfg := emptyConfig()
+	cfg.Set(host, config.Registry{Insecure: true})
+	reg, err = NewRegistries(cfg, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadImage(t.Context(), ref, LoadOptions{Platform: testPlatform, Registries: reg}); err != nil {
+		t.Fatalf("expected insecure registry to skip verification: %v", err)
+	}
+}
+
+func TestLoadImage_Mirror(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	mirror := strings.TrimPrefix(srv.URL, "http://")
+	pushRandom(t, mirror)
+
+	cfg := emptyConfig()
+	cfg.Set("origin.invalid", config.Registry{Mirror: mirror})
+	reg, err := NewRegistries(cfg, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadImage(t.Context(), "origin.invalid/library/app:latest", LoadOptions{Platform: testPlatform, Registries: reg}); err != nil {
+		t.Fatalf("expected the image to load from the mirror: %v", err)
+	}
+}
+
+func TestFingerprint_Remote(t *testing.T) {
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	opts := LoadOptions{Platform: testPlatform, Source: SourceRemote}
+
+	ref := pushRandom(t, host)
+	before, err := Fingerprint(t.Context(), ref, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := Fingerprint(t.Context(), ref, opts); err != nil || again != before {
+		t.Fatalf("fingerprint changed without a push: %s, %v", again, err)
+	}
+	pushRandom(t, host)
+	after, err := Fingerprint(t.Context(), ref, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after == before {
+		t.Error("expected a new fingerprint after pushing to the tag")
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	cfg := emptyConfig()
+	cfg.Set("docker.io", config.Registry{Mirror: "mirror.internal"})
+	reg, err := NewRegistries(cfg, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := reg.Parse("nginx:1.27")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reg.Candidates(ref)
+	if len(got) != 2 || got[0].String() != "mirror.internal/library/nginx:1.27" || got[1] != ref {
+		t.Errorf("unexpected candidates: %v", got)
+	}
+
+	other, _ := reg.Parse("ghcr.io/org/app@sha256:" + strings.Repeat("a", 64))
+	if got := reg.Candidates(other); len(got) != 1 {
+		t.Errorf("expected no mirror for ghcr.io, got %v", got)
+	}
+}
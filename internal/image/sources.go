@@ -0,0 +1,294 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Source is where LoadImage looks for an image.
+type Source string
+
+const (
+	SourceAuto       Source = "auto"       // Docker daemon, then registry
+	SourceDocker     Source = "docker"     // Docker daemon only
+	SourcePodman     Source = "podman"     // Podman's Docker-compatible API socket
+	SourceContainerd Source = "containerd" // containerd content store on disk, by digest
+	SourceOCI        Source = "oci"        // OCI layout directory or image tarball
+	SourceRemote     Source = "remote"     // registry only
+)
+
+// Sources lists the valid sources in the order they're documented.
+var Sources = []Source{SourceAuto, SourceDocker, SourcePodman, SourceContainerd, SourceOCI, SourceRemote}
+
+// ParseSource parses a --source value. Empty means SourceAuto.
+func ParseSource(s string) (Source, error) {
+	if s == "" {
+		return SourceAuto, nil
+	}
+	for _, src := range Sources {
+		if string(src) == s {
+			return src, nil
+		}
+	}
+	return "", fmt.Errorf("invalid source %q, expected one of %v", s, Sources)
+}
+
+// Annotations naming an image in an OCI layout's index.
+const (
+	annotationRefName        = "org.opencontainers.image.ref.name"
+	annotationContainerdName = "io.containerd.image.name"
+)
+
+// containerdRoots are content store locations for rootful containerd,
+// rootless nerdctl and k3s, tried in order.
+func containerdRoots() []string {
+	roots := []string{"/var/lib/containerd/io.containerd.content.v1.content"}
+	if home, err := os.UserHomeDir(); err == nil {
+		roots = append(roots, filepath.Join(home, ".local/share/containerd/io.containerd.content.v1.content"))
+	}
+	return append(roots, "/var/lib/rancher/k3s/agent/containerd/io.containerd.content.v1.content")
+}
+
+// podmanHost returns the Podman API socket: $CONTAINER_HOST, then the
+// rootless socket, then the rootful one.
+func podmanHost() string {
+	if h := os.Getenv("CONTAINER_HOST"); h != "" {
+		return h
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		sock := filepath.Join(dir, "podman", "podman.sock")
+		if _, err := os.Stat(sock); err == nil {
+			return "unix://" + sock
+		}
+	}
+	return "unix:///run/podman/podman.sock"
+}
+
+func loadPodman(ctx context.Context, ref string) (v1.Image, error) {
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ref, err)
+	}
+	host := podmanHost()
+	c, err := client.NewClientWithOpts(client.WithHost(host), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("podman %s: %w", host, err)
+	}
+	img, err := daemon.Image(parsed, daemon.WithClient(c), daemon.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("podman %s: %w", host, err)
+	}
+	return img, nil
+}
+
+// loadContainerd reads an image straight from a containerd content store.
+// Image names live in containerd's metadata database, so ref must carry the
+// manifest or index digest.
+func loadContainerd(ref, root string, platform v1.Platform) (v1.Image, error) {
+	digest := ref
+	if _, after, ok := strings.Cut(ref, "@"); ok {
+		digest = after
+	}
+	h, err := v1.NewHash(digest)
+	if err != nil {
+		return nil, fmt.Errorf("containerd source needs a digest (name@sha256:... or sha256:...); find it with `nerdctl images --digests` or `ctr images ls`")
+	}
+
+	roots := []string{root}
+	if root == "" {
+		roots = containerdRoots()
+	}
+	for _, r := range roots {
+		if _, err := os.Stat(filepath.Join(r, "blobs")); err != nil {
+			continue
+		}
+		return imageFromBlobs(layout.Path(r), h, platform)
+	}
+	return nil, fmt.Errorf("no containerd content store found in %s", strings.Join(roots, ", "))
+}
+
+// loadOCI reads an OCI layout directory or an image tarball. As with
+// skopeo's oci: transport, ref is path[:name] where name selects among
+// several images in the layout by its ref.name annotation.
+func loadOCI(ref string, platform v1.Platform) (v1.Image, error) {
+	path, sel := ref, ""
+	if _, err := os.Stat(path); err != nil {
+		// the name may itself contain colons, so split at the first one
+		// that leaves an existing path
+		for i := strings.Index(ref, ":"); i > 0; {
+			if _, err := os.Stat(ref[:i]); err == nil {
+				path, sel = ref[:i], ref[i+1:]
+				break
+			}
+			j := strings.Index(ref[i+1:], ":")
+			if j < 0 {
+				break
+			}
+			i += j + 1
+		}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.IsDir() {
+		// docker save, nerdctl save and ctr export all write a manifest.json
+		var tag *name.Tag
+		if sel != "" {
+			t, err := name.NewTag(sel)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", sel, err)
+			}
+			tag = &t
+		}
+		img, err := tarball.ImageFromPath(path, tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w (extract OCI-only archives and pass the directory)", path, err)
+		}
+		return img, nil
+	}
+
+	p, err := layout.FromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := p.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	desc, err := selectManifest(im.Manifests, sel)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return imageFromBlobs(p, desc.Digest, platform)
+}
+
+// selectManifest picks the index entry named sel, or the only entry if sel
+// is empty.
+func selectManifest(manifests []v1.Descriptor, sel string) (v1.Descriptor, error) {
+	if sel == "" {
+		if len(manifests) == 1 {
+			return manifests[0], nil
+		}
+		return v1.Descriptor{}, fmt.Errorf("%d images in layout; pick one with path:name", len(manifests))
+	}
+	for _, m := range manifests {
+		if m.Annotations[annotationRefName] == sel || sameImage(m.Annotations[annotationContainerdName], sel) {
+			return m, nil
+		}
+	}
+	return v1.Descriptor{}, fmt.Errorf("no image named %q in layout", sel)
+}
+
+// sameImage reports whether two image references name the same image once
+// normalized, so "app:2" matches containerd's "docker.io/library/app:2".
+func sameImage(a, b string) bool {
+	if a == "" {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	ra, err := name.ParseReference(a)
+	if err != nil {
+		return false
+	}
+	rb, err := name.ParseReference(b)
+	if err != nil {
+		return false
+	}
+	return ra.Name() == rb.Name()
+}
+
+// imageFromBlobs resolves h in a blob store to an image, descending through
+// indexes by platform.
+func imageFromBlobs(p layout.Path, h v1.Hash, platform v1.Platform) (v1.Image, error) {
+	data, err := p.Bytes(h)
+	if err != nil {
+		return nil, fmt.Errorf("blob %s: %w", h, err)
+	}
+	var m struct {
+		MediaType types.MediaType `json:"mediaType"`
+		Manifests []v1.Descriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("manifest %s: %w", h, err)
+	}
+	if !m.MediaType.IsIndex() && m.Manifests == nil {
+		return partial.CompressedToImage(&blobImage{p: p, manifest: data, mediaType: m.MediaType})
+	}
+	d, err := pickManifest(m.Manifests, platform)
+	if err != nil {
+		return nil, err
+	}
+	return imageFromBlobs(p, d.Digest, platform)
+}
+
+// blobImage is an image read from a blob store by digest. Unlike
+// layout.Path.Image it doesn't need the manifest to be listed in index.json,
+// which containerd's content store doesn't have.
+type blobImage struct {
+	p         layout.Path
+	manifest  []byte
+	mediaType types.MediaType
+}
+
+func (b *blobImage) MediaType() (types.MediaType, error) {
+	if b.mediaType == "" {
+		return types.DockerManifestSchema2, nil
+	}
+	return b.mediaType, nil
+}
+
+func (b *blobImage) RawManifest() ([]byte, error) { return b.manifest, nil }
+
+func (b *blobImage) RawConfigFile() ([]byte, error) {
+	m, err := v1.ParseManifest(bytes.NewReader(b.manifest))
+	if err != nil {
+		return nil, err
+	}
+	return b.p.Bytes(m.Config.Digest)
+}
+
+func (b *blobImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	m, err := v1.ParseManifest(bytes.NewReader(b.manifest))
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range m.Layers {
+		if desc.Digest == h {
+			return &blobLayer{p: b.p, desc: desc}, nil
+		}
+	}
+	return nil, fmt.Errorf("layer %s not in manifest", h)
+}
+
+type blobLayer struct {
+	p    layout.Path
+	desc v1.Descriptor
+}
+
+func (l *blobLayer) Digest() (v1.Hash, error)            { return l.desc.Digest, nil }
+func (l *blobLayer) Size() (int64, error)                { return l.desc.Size, nil }
+func (l *blobLayer) MediaType() (types.MediaType, error) { return l.desc.MediaType, nil }
+func (l *blobLayer) Compressed() (io.ReadCloser, error)  { return l.p.Blob(l.desc.Digest) }
@@ -0,0 +1,160 @@
+package image
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+)
+
+// platformImage builds a random image whose config declares p.
+func platformImage(t *testing.T, p v1.Platform) v1.Image {
+	t.Helper()
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = cfg.DeepCopy()
+	cfg.OS, cfg.Architecture, cfg.Variant = p.OS, p.Architecture, p.Variant
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// platformIndex builds an index with one random image per platform.
+func platformIndex(t *testing.T, platforms ...v1.Platform) (v1.ImageIndex, []v1.Image) {
+	t.Helper()
+	var idx v1.ImageIndex = empty.Index
+	var imgs []v1.Image
+	for _, p := range platforms {
+		img := platformImage(t, p)
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &p},
+		})
+		imgs = append(imgs, img)
+	}
+	return idx, imgs
+}
+
+func digestOf(t *testing.T, d interface{ Digest() (v1.Hash, error) }) v1.Hash {
+	t.Helper()
+	h, err := d.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestLoadImage_OCI(t *testing.T) {
+	dir := t.TempDir()
+	arm := v1.Platform{OS: "linux", Architecture: "arm64"}
+	idx, imgs := platformIndex(t, testPlatform, arm)
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AppendIndex(idx, layout.WithAnnotations(map[string]string{annotationRefName: "v1"})); err != nil {
+		t.Fatal(err)
+	}
+
+	img, _, err := LoadImage(t.Context(), dir, LoadOptions{Platform: arm, Source: SourceOCI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digestOf(t, img) != digestOf(t, imgs[1]) {
+		t.Error("expected the arm64 image")
+	}
+
+	if _, _, err := LoadImage(t.Context(), dir+":v1", LoadOptions{Platform: testPlatform, Source: SourceOCI}); err != nil {
+		t.Errorf("select by ref.name: %v", err)
+	}
+	if _, _, err := LoadImage(t.Context(), dir+":v2", LoadOptions{Platform: testPlatform, Source: SourceOCI}); err == nil {
+		t.Error("expected error for unknown name")
+	}
+	if _, _, err := LoadImage(t.Context(), dir, LoadOptions{Platform: v1.Platform{OS: "windows", Architecture: "amd64"}, Source: SourceOCI}); err == nil {
+		t.Error("expected error for missing platform")
+	}
+}
+
+func TestLoadImage_OCIMultiple(t *testing.T) {
+	dir := t.TempDir()
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var imgs []v1.Image
+	for _, n := range []string{"docker.io/library/app:1", "docker.io/library/app:2"} {
+		img, err := random.Image(64, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.AppendImage(img, layout.WithAnnotations(map[string]string{annotationContainerdName: n})); err != nil {
+			t.Fatal(err)
+		}
+		imgs = append(imgs, img)
+	}
+
+	if _, _, err := LoadImage(t.Context(), dir, LoadOptions{Platform: testPlatform, Source: SourceOCI}); err == nil {
+		t.Error("expected error when the layout holds several images")
+	}
+	img, _, err := LoadImage(t.Context(), dir+":app:2", LoadOptions{Platform: testPlatform, Source: SourceOCI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digestOf(t, img) != digestOf(t, imgs[1]) {
+		t.Error("expected app:2")
+	}
+}
+
+func TestLoadImage_Containerd(t *testing.T) {
+	// a containerd content store is a blob directory without index.json
+	root := t.TempDir()
+	idx, imgs := platformIndex(t, testPlatform)
+	p, err := layout.Write(root, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.WriteIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+	for _, img := range imgs {
+		if err := p.WriteImage(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ref := "example.com/app@" + digestOf(t, idx).String()
+	img, _, err := LoadImage(t.Context(), ref, LoadOptions{Platform: testPlatform, Source: SourceContainerd, ContainerdRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digestOf(t, img) != digestOf(t, imgs[0]) {
+		t.Error("expected the indexed image")
+	}
+
+	if _, _, err := LoadImage(t.Context(), "example.com/app:latest", LoadOptions{Platform: testPlatform, Source: SourceContainerd, ContainerdRoot: root}); err == nil {
+		t.Error("expected error for a ref without a digest")
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	if s, err := ParseSource(""); err != nil || s != SourceAuto {
+		t.Errorf("empty: got %q, %v", s, err)
+	}
+	if s, err := ParseSource("podman"); err != nil || s != SourcePodman {
+		t.Errorf("podman: got %q, %v", s, err)
+	}
+	if _, err := ParseSource("lxc"); err == nil {
+		t.Error("expected error for unknown source")
+	}
+}
@@ -79,12 +79,15 @@ func testImage(t *testing.T) *Image {
 	cf.OS = "linux"
 	cf.Config.Env = []string{"A=1"}
 	cf.Config.Cmd = []string{"/app"}
+	cf.Config.ExposedPorts = map[string]struct{}{"8080/tcp": {}, "53/udp": {}}
+	cf.Config.Healthcheck = &v1.HealthConfig{Test: []string{"CMD-SHELL", "true"}, Interval: 30 * time.Second, Retries: 3}
+	cf.Config.StopSignal = "SIGQUIT"
 	img, err = mutate.ConfigFile(img, cf)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	result, err := Analyze(img, "test:latest")
+	result, err := Analyze(t.Context(), img, "test:latest", AnalyzeOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
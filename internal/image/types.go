@@ -1,6 +1,10 @@
 package image
 
-import v1 "github.com/google/go-containerregistry/pkg/v1"
+import (
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
 
 type FileType string
 
@@ -8,6 +12,8 @@ const (
 	FileTypeFile    FileType = "file"
 	FileTypeDir     FileType = "dir"
 	FileTypeSymlink FileType = "symlink"
+	// FileTypeWhiteout marks a path deleted by a layer. Only appears in per-layer trees.
+	FileTypeWhiteout FileType = "whiteout"
 )
 
 type ChangeKind string
@@ -19,38 +25,115 @@ const (
 )
 
 type ImageInfo struct {
-	Ref        string      `json:"ref"`
-	Digest     string      `json:"digest"`
-	Arch       string      `json:"arch"`
-	OS         string      `json:"os"`
-	Config     ImageConfig `json:"config"`
-	LayerCount int         `json:"layerCount"`
+	Ref        string       `json:"ref"`
+	Digest     string       `json:"digest"`
+	Arch       string       `json:"arch"`
+	OS         string       `json:"os"`
+	Variant    string       `json:"variant,omitempty"`
+	Created    *time.Time   `json:"created,omitempty"`
+	Author     string       `json:"author,omitempty"`
+	Config     ImageConfig  `json:"config"`
+	RootFS     RootFS       `json:"rootfs"`
+	Manifest   ManifestInfo `json:"manifest"`
+	LayerCount int          `json:"layerCount"`
+	// Resolution is how the image was found, when it was loaded by LoadImage.
+	Resolution *Resolution `json:"resolution,omitempty"`
 }
 
 type ImageConfig struct {
-	Env        []string          `json:"env"`
-	Entrypoint []string          `json:"entrypoint"`
-	Cmd        []string          `json:"cmd"`
-	WorkingDir string            `json:"workingDir"`
-	User       string            `json:"user"`
-	Labels     map[string]string `json:"labels"`
+	Env          []string          `json:"env"`
+	Entrypoint   []string          `json:"entrypoint"`
+	Cmd          []string          `json:"cmd"`
+	WorkingDir   string            `json:"workingDir"`
+	User         string            `json:"user"`
+	Labels       map[string]string `json:"labels"`
+	ExposedPorts []string          `json:"exposedPorts,omitempty"` // sorted, e.g. "80/tcp"
+	Volumes      []string          `json:"volumes,omitempty"`      // sorted
+	Healthcheck  *Healthcheck      `json:"healthcheck,omitempty"`
+	StopSignal   string            `json:"stopSignal,omitempty"`
+	Shell        []string          `json:"shell,omitempty"`
+	OnBuild      []string          `json:"onBuild,omitempty"`
+	ArgsEscaped  bool              `json:"argsEscaped,omitempty"`
+}
+
+// Healthcheck mirrors the config's HEALTHCHECK with durations as strings
+// ("30s"). Zero values mean the runtime default.
+type Healthcheck struct {
+	Test          []string `json:"test"`
+	Interval      string   `json:"interval,omitempty"`
+	Timeout       string   `json:"timeout,omitempty"`
+	StartPeriod   string   `json:"startPeriod,omitempty"`
+	StartInterval string   `json:"startInterval,omitempty"` // between checks during StartPeriod
+	Retries       int      `json:"retries,omitempty"`
+}
+
+type RootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diffIDs"`
+}
+
+// ManifestInfo summarizes the image manifest. Per-layer descriptors are in LayerInfo.
+type ManifestInfo struct {
+	MediaType     string            `json:"mediaType"`
+	SchemaVersion int64             `json:"schemaVersion"`
+	Size          int64             `json:"size"`
+	Config        Descriptor        `json:"config"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+type Descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
 }
 
 type LayerInfo struct {
-	Index   int    `json:"index"`
-	DiffID  string `json:"diffID"`
-	Size    int64  `json:"size"`
-	Command string `json:"command"`
-	Empty   bool   `json:"empty"`
+	Index            int        `json:"index"`
+	DiffID           string     `json:"diffID"`
+	Digest           string     `json:"digest,omitempty"`    // compressed blob digest
+	MediaType        string     `json:"mediaType,omitempty"` // blob media type
+	Size             int64      `json:"size"`                // compressed blob size
+	UncompressedSize int64      `json:"uncompressedSize"`    // size of the layer's tar
+	Command          string     `json:"command"`
+	Instruction      string     `json:"instruction"` // Command normalized to a Dockerfile instruction
+	Created          *time.Time `json:"created,omitempty"`
+	Author           string     `json:"author,omitempty"`
+	Comment          string     `json:"comment,omitempty"`
+	Empty            bool       `json:"empty"`
 }
 
 type FileNode struct {
-	Name       string      `json:"name"`
-	Path       string      `json:"path"`
-	Type       FileType    `json:"type"`
-	Size       int64       `json:"size"`
-	LinkTarget string      `json:"linkTarget,omitempty"`
-	Children   []*FileNode `json:"children,omitempty"`
+	Name       string   `json:"name"`
+	Path       string   `json:"path"`
+	Type       FileType `json:"type"`
+	Size       int64    `json:"size"`
+	LinkTarget string   `json:"linkTarget,omitempty"`
+	Opaque     bool     `json:"opaque,omitempty"` // dir hides all lower-layer contents (per-layer trees only)
+	AddedIn    int      `json:"addedIn"`          // layer index that first created this path
+	ModifiedIn int      `json:"modifiedIn"`       // layer index whose tar last wrote this path
+	// Directory aggregates: recursive bytes (including the dir's own size) and number of entries below it.
+	TotalSize   int64       `json:"totalSize,omitempty"`
+	Descendants int         `json:"descendants,omitempty"`
+	Children    []*FileNode `json:"children,omitempty"`
+
+	implicit bool // dir the layer's tar has no entry for, created only to hold its children
+}
+
+// DirEntry is one immediate child in a directory listing, with aggregate
+// totals for directories so a listing alone can show where the bytes are.
+type DirEntry struct {
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	Type        FileType `json:"type"`
+	Size        int64    `json:"size"`
+	LinkTarget  string   `json:"linkTarget,omitempty"`
+	Opaque      bool     `json:"opaque,omitempty"`
+	AddedIn     int      `json:"addedIn"`
+	ModifiedIn  int      `json:"modifiedIn"`
+	ChildCount  int      `json:"childCount"`  // immediate children
+	Descendants int      `json:"descendants"` // all entries below this one
+	TotalSize   int64    `json:"totalSize"`   // recursive bytes, including the entry itself
+	LayerSize   int64    `json:"layerSize"`   // bytes the selected layer's own tar holds at or below this path
 }
 
 type DiffEntry struct {
@@ -60,6 +143,26 @@ type DiffEntry struct {
 	Size       int64      `json:"size"`
 }
 
+// PathChange records one layer that touched a path.
+type PathChange struct {
+	Layer       int        `json:"layer"`
+	Command     string     `json:"command"`
+	Instruction string     `json:"instruction"`
+	ChangeKind  ChangeKind `json:"changeKind"`
+	Type        FileType   `json:"type"`
+	Size        int64      `json:"size"`
+}
+
+// FileVersion is a path's state after one layer that changed it.
+type FileVersion struct {
+	PathChange
+	Content *FileContent `json:"content,omitempty"` // nil for deletions and non-regular files
+	Diff    string       `json:"diff,omitempty"`    // unified diff from the previous version
+	// Truncated is set when either side of Diff was cut at maxTextBytes, so
+	// only the leading bytes are compared.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
 type FileContent struct {
 	Path         string `json:"path"`
 	ResolvedPath string `json:"resolvedPath,omitempty"`
@@ -71,9 +174,11 @@ type FileContent struct {
 
 // Image holds the fully-analyzed image in memory. Immutable after Analyze().
 type Image struct {
-	Info   ImageInfo     `json:"info"`
-	Layers []LayerInfo   `json:"layers"`
-	Trees  []*FileNode   // indexed by layer index; empty layers share previous tree
-	Diffs  [][]DiffEntry // indexed by layer index
-	img    v1.Image
+	Info       ImageInfo     `json:"info"`
+	Layers     []LayerInfo   `json:"layers"`
+	Trees      []*FileNode   // indexed by layer index; empty layers share previous tree
+	LayerTrees []*FileNode   // indexed by layer index; contents of that layer's tar alone, nil for empty layers
+	Diffs      [][]DiffEntry // indexed by layer index
+	Reused     int           // content layers taken from AnalyzeOptions.Previous
+	img        v1.Image
 }
@@ -0,0 +1,312 @@
+package referrers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// maxBlobBytes caps how much of an artifact layer is read.
+const maxBlobBytes = 8 << 20 // 8MB
+
+// Layer media types and annotations written by cosign and BuildKit.
+const (
+	mediaTypeSimpleSigning = "application/vnd.dev.cosign.simplesigning.v1+json"
+	mediaTypeDSSE          = "application/vnd.dsse.envelope.v1+json"
+	mediaTypeInToto        = "application/vnd.in-toto+json"
+	mediaTypeBundlePrefix  = "application/vnd.dev.sigstore.bundle"
+
+	cosignSignature     = "dev.cosignproject.cosign/signature"
+	cosignCertificate   = "dev.sigstore.cosign/certificate"
+	cosignBundle        = "dev.sigstore.cosign/bundle"
+	bundlePredicateType = "dev.sigstore.bundle.predicateType"
+)
+
+// Detail is an artifact with its layers decoded.
+type Detail struct {
+	Artifact
+	Signatures   []Signature   `json:"signatures,omitempty"`
+	Attestations []Attestation `json:"attestations,omitempty"`
+	SBOMs        []SBOM        `json:"sboms,omitempty"`
+	Blobs        []Blob        `json:"blobs,omitempty"` // layers not decoded above
+}
+
+// Signature is a cosign signature over a simple-signing payload.
+type Signature struct {
+	Identity       string `json:"identity"`       // docker-reference claimed by the payload
+	ManifestDigest string `json:"manifestDigest"` // digest the payload signs
+	Signature      string `json:"signature"`      // base64
+	Certificate    string `json:"certificate,omitempty"`
+	HasBundle      bool   `json:"hasBundle"` // includes a transparency log entry
+	Payload        string `json:"payload"`   // raw simple-signing JSON
+}
+
+// Attestation is an in-toto statement, optionally wrapped in a DSSE envelope.
+type Attestation struct {
+	PayloadType   string          `json:"payloadType,omitempty"` // empty for bare statements
+	Signed        bool            `json:"signed"`
+	PredicateType string          `json:"predicateType"`
+	Subjects      []Subject       `json:"subjects"`
+	Provenance    *Provenance     `json:"provenance,omitempty"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// SBOM is a software bill of materials, attached directly or as an attestation predicate.
+type SBOM struct {
+	Format    string `json:"format"` // spdx, cyclonedx or syft
+	MediaType string `json:"mediaType"`
+	Content   string `json:"content"`
+	Truncated bool   `json:"truncated"`
+}
+
+// Blob describes a layer peel doesn't decode.
+type Blob struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Truncated   bool              `json:"truncated,omitempty"` // over maxBlobBytes, too large to decode
+}
+
+// Inspect fetches an attached artifact by digest and decodes its layers.
+func (f *Finder) Inspect(ctx context.Context, digest string) (*Detail, error) {
+	h, err := v1.NewHash(digest)
+	if err != nil {
+		return nil, fmt.Errorf("parse digest: %w", err)
+	}
+	desc, err := remote.Get(f.ref.Context().Digest(h.String()), f.remoteOptions(ctx)...)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", digest, err)
+	}
+	img, err := desc.Image()
+	if err != nil {
+		return nil, fmt.Errorf("artifact %s: %w", digest, err)
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("artifact manifest: %w", err)
+	}
+
+	d := &Detail{Artifact: fromDescriptor(desc.Descriptor, "", f.digest)}
+	d.Annotations = m.Annotations
+	if m.Subject != nil {
+		d.Subject = m.Subject.Digest.String()
+	}
+	if d.ArtifactType == "" {
+		// Without an explicit artifact type the config media type stands in
+		d.ArtifactType = string(m.Config.MediaType)
+		d.Kind = classify(d.ArtifactType)
+	}
+
+	for _, ld := range m.Layers {
+		layer, err := img.LayerByDigest(ld.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("layer %s: %w", ld.Digest, err)
+		}
+		data, truncated, err := readBlob(layer)
+		if err != nil {
+			return nil, fmt.Errorf("layer %s: %w", ld.Digest, err)
+		}
+		if err := d.decodeLayer(ld, data, truncated); err != nil {
+			return nil, fmt.Errorf("layer %s: %w", ld.Digest, err)
+		}
+	}
+
+	if d.Kind == KindOther {
+		switch {
+		case len(d.Signatures) > 0:
+			d.Kind = KindSignature
+		case len(d.SBOMs) > 0:
+			d.Kind = KindSBOM
+		case len(d.Attestations) > 0:
+			d.Kind = KindAttestation
+		}
+	}
+	return d, nil
+}
+
+func (d *Detail) decodeLayer(ld v1.Descriptor, data []byte, truncated bool) error {
+	mt := string(ld.MediaType)
+	switch {
+	case truncated && classify(mt) != KindSBOM:
+		// Signatures and attestations are JSON documents, so a cut-off one
+		// can't be decoded
+		b := blob(ld)
+		b.Truncated = true
+		d.Blobs = append(d.Blobs, b)
+	case mt == mediaTypeSimpleSigning:
+		sig, err := decodeSimpleSigning(data, ld.Annotations)
+		if err != nil {
+			return err
+		}
+		d.Signatures = append(d.Signatures, *sig)
+	case mt == mediaTypeDSSE:
+		att, err := decodeEnvelope(data)
+		if err != nil {
+			return err
+		}
+		d.addAttestation(att)
+	case mt == mediaTypeInToto:
+		att, err := decodeStatement(data)
+		if err != nil {
+			return err
+		}
+		d.addAttestation(att)
+	case strings.HasPrefix(mt, mediaTypeBundlePrefix):
+		var bundle struct {
+			DSSEEnvelope json.RawMessage `json:"dsseEnvelope"`
+		}
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			return fmt.Errorf("sigstore bundle: %w", err)
+		}
+		if bundle.DSSEEnvelope == nil {
+			// A plain message signature; nothing beyond the blob to show
+			d.Blobs = append(d.Blobs, blob(ld))
+			return nil
+		}
+		att, err := decodeEnvelope(bundle.DSSEEnvelope)
+		if err != nil {
+			return err
+		}
+		d.addAttestation(att)
+	case classify(mt) == KindSBOM:
+		d.SBOMs = append(d.SBOMs, SBOM{Format: sbomFormat(mt), MediaType: mt, Content: string(data), Truncated: truncated})
+	default:
+		d.Blobs = append(d.Blobs, blob(ld))
+	}
+	return nil
+}
+
+// addAttestation records an attestation, surfacing SBOM predicates as SBOMs too.
+func (d *Detail) addAttestation(att *Attestation) {
+	if format := sbomFormat(att.PredicateType); format != "" {
+		d.SBOMs = append(d.SBOMs, SBOM{Format: format, MediaType: att.PredicateType, Content: indent(att.Predicate)})
+	}
+	d.Attestations = append(d.Attestations, *att)
+}
+
+func blob(ld v1.Descriptor) Blob {
+	return Blob{MediaType: string(ld.MediaType), Digest: ld.Digest.String(), Size: ld.Size, Annotations: ld.Annotations}
+}
+
+func readBlob(l v1.Layer) ([]byte, bool, error) {
+	// Artifact layers are stored as-is, so the compressed stream is the content
+	rc, err := l.Compressed()
+	if err != nil {
+		return nil, false, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxBlobBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) > maxBlobBytes {
+		return data[:maxBlobBytes], true, nil
+	}
+	return data, false, nil
+}
+
+// simpleSigning is the payload cosign signs.
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+func decodeSimpleSigning(data []byte, annotations map[string]string) (*Signature, error) {
+	var p simpleSigning
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("simple signing payload: %w", err)
+	}
+	return &Signature{
+		Identity:       p.Critical.Identity.DockerReference,
+		ManifestDigest: p.Critical.Image.DockerManifestDigest,
+		Signature:      annotations[cosignSignature],
+		Certificate:    annotations[cosignCertificate],
+		HasBundle:      annotations[cosignBundle] != "",
+		Payload:        string(data),
+	}, nil
+}
+
+// envelope is a DSSE envelope.
+type envelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"` // base64
+	Signatures  []struct {
+		KeyID string `json:"keyid"`
+		Sig   string `json:"sig"`
+	} `json:"signatures"`
+}
+
+func decodeEnvelope(data []byte) (*Attestation, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("dsse envelope: %w", err)
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("dsse payload: %w", err)
+	}
+	att, err := decodeStatement(payload)
+	if err != nil {
+		return nil, err
+	}
+	att.PayloadType = env.PayloadType
+	att.Signed = len(env.Signatures) > 0
+	return att, nil
+}
+
+func decodeStatement(data []byte) (*Attestation, error) {
+	var st struct {
+		PredicateType string          `json:"predicateType"`
+		Subject       []Subject       `json:"subject"`
+		Predicate     json.RawMessage `json:"predicate"`
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("in-toto statement: %w", err)
+	}
+	att := &Attestation{
+		PredicateType: st.PredicateType,
+		Subjects:      st.Subject,
+		Predicate:     st.Predicate,
+	}
+	if strings.HasPrefix(st.PredicateType, "https://slsa.dev/provenance/") {
+		att.Provenance = decodeProvenance(st.PredicateType, st.Predicate)
+	}
+	return att, nil
+}
+
+func sbomFormat(mediaType string) string {
+	for _, f := range []string{"spdx", "cyclonedx", "syft"} {
+		if strings.Contains(strings.ToLower(mediaType), f) {
+			return f
+		}
+	}
+	return ""
+}
+
+func indent(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
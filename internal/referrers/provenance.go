@@ -0,0 +1,141 @@
+package referrers
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Provenance is the part of a SLSA provenance predicate worth showing at a
+// glance: who built the image, from what, and with which inputs.
+type Provenance struct {
+	SLSAVersion    string     `json:"slsaVersion"` // "0.2" or "1"
+	BuilderID      string     `json:"builderID"`
+	BuildType      string     `json:"buildType"`
+	SourceRepo     string     `json:"sourceRepo,omitempty"`
+	SourceRevision string     `json:"sourceRevision,omitempty"`
+	EntryPoint     string     `json:"entryPoint,omitempty"` // e.g. the Dockerfile or workflow path
+	Materials      []Material `json:"materials"`
+}
+
+// Material is one input to the build: a source checkout, base image or file.
+type Material struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// slsaV02 covers https://slsa.dev/provenance/v0.2, including the metadata
+// BuildKit adds.
+type slsaV02 struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	BuildType  string `json:"buildType"`
+	Invocation struct {
+		ConfigSource struct {
+			URI        string            `json:"uri"`
+			Digest     map[string]string `json:"digest"`
+			EntryPoint string            `json:"entryPoint"`
+		} `json:"configSource"`
+	} `json:"invocation"`
+	Materials []Material `json:"materials"`
+	Metadata  struct {
+		BuildKit struct {
+			VCS struct {
+				Source   string `json:"source"`
+				Revision string `json:"revision"`
+			} `json:"vcs"`
+		} `json:"https://mobyproject.org/buildkit@v1#metadata"`
+	} `json:"metadata"`
+}
+
+// slsaV1 covers https://slsa.dev/provenance/v1. External parameters are
+// builder-specific, so the common shapes for the source are tried in turn.
+type slsaV1 struct {
+	BuildDefinition struct {
+		BuildType          string `json:"buildType"`
+		ExternalParameters struct {
+			ConfigSource struct {
+				URI    string            `json:"uri"`
+				Digest map[string]string `json:"digest"`
+				Path   string            `json:"path"`
+			} `json:"configSource"`
+			Workflow struct {
+				Repository string `json:"repository"`
+				Ref        string `json:"ref"`
+				Path       string `json:"path"`
+			} `json:"workflow"`
+			Source string `json:"source"`
+		} `json:"externalParameters"`
+		ResolvedDependencies []struct {
+			URI    string            `json:"uri"`
+			Name   string            `json:"name"`
+			Digest map[string]string `json:"digest"`
+		} `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+	} `json:"runDetails"`
+}
+
+// decodeProvenance extracts a summary from a SLSA predicate, or returns nil
+// if the predicate doesn't parse.
+func decodeProvenance(predicateType string, raw json.RawMessage) *Provenance {
+	if strings.HasSuffix(predicateType, "/v0.2") {
+		var p slsaV02
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil
+		}
+		prov := &Provenance{
+			SLSAVersion: "0.2",
+			BuilderID:   p.Builder.ID,
+			BuildType:   p.BuildType,
+			SourceRepo:  p.Invocation.ConfigSource.URI,
+			EntryPoint:  p.Invocation.ConfigSource.EntryPoint,
+			Materials:   p.Materials,
+		}
+		if sha := p.Invocation.ConfigSource.Digest["sha1"]; sha != "" {
+			prov.SourceRevision = sha
+		}
+		if vcs := p.Metadata.BuildKit.VCS; vcs.Source != "" {
+			prov.SourceRepo, prov.SourceRevision = vcs.Source, vcs.Revision
+		}
+		if prov.Materials == nil {
+			prov.Materials = []Material{}
+		}
+		return prov
+	}
+
+	var p slsaV1
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil
+	}
+	ext := p.BuildDefinition.ExternalParameters
+	prov := &Provenance{
+		SLSAVersion: "1",
+		BuilderID:   p.RunDetails.Builder.ID,
+		BuildType:   p.BuildDefinition.BuildType,
+		Materials:   []Material{},
+	}
+	switch {
+	case ext.Workflow.Repository != "":
+		prov.SourceRepo, prov.SourceRevision, prov.EntryPoint = ext.Workflow.Repository, ext.Workflow.Ref, ext.Workflow.Path
+	case ext.ConfigSource.URI != "":
+		prov.SourceRepo, prov.SourceRevision, prov.EntryPoint = ext.ConfigSource.URI, ext.ConfigSource.Digest["sha1"], ext.ConfigSource.Path
+	default:
+		prov.SourceRepo = ext.Source
+	}
+	for _, d := range p.BuildDefinition.ResolvedDependencies {
+		uri := d.URI
+		if uri == "" {
+			uri = d.Name
+		}
+		prov.Materials = append(prov.Materials, Material{URI: uri, Digest: d.Digest})
+		if prov.SourceRepo == "" && strings.HasPrefix(uri, "git+") {
+			prov.SourceRepo = uri
+			prov.SourceRevision = d.Digest["gitCommit"]
+		}
+	}
+	return prov
+}
@@ -0,0 +1,204 @@
+// Package referrers discovers signatures, attestations and SBOMs attached
+// to an image in its registry, via the OCI referrers API (or its tag
+// fallback), cosign's tag scheme and BuildKit attestation manifests.
+package referrers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Kind classifies an attached artifact.
+type Kind string
+
+const (
+	KindSignature   Kind = "signature"
+	KindAttestation Kind = "attestation"
+	KindSBOM        Kind = "sbom"
+	KindOther       Kind = "other"
+)
+
+// Where an artifact was found.
+const (
+	SourceReferrers = "referrers" // OCI referrers API or its fallback tag
+	SourceTag       = "tag"       // cosign's sha256-<hex>.sig/.att/.sbom tags
+	SourceIndex     = "index"     // BuildKit attestation manifest in the image index
+)
+
+// Annotations BuildKit sets on attestation manifests in an image index.
+const (
+	dockerReferenceType   = "vnd.docker.reference.type"
+	dockerReferenceDigest = "vnd.docker.reference.digest"
+)
+
+// cosignSuffixes maps cosign's tag suffixes to the kind they hold.
+var cosignSuffixes = []struct {
+	suffix string
+	kind   Kind
+}{
+	{"sig", KindSignature},
+	{"att", KindAttestation},
+	{"sbom", KindSBOM},
+}
+
+// Artifact is a manifest attached to the image.
+type Artifact struct {
+	Digest       string            `json:"digest"`
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Kind         Kind              `json:"kind"`
+	Source       string            `json:"source"`
+	Tag          string            `json:"tag,omitempty"` // for SourceTag
+	Subject      string            `json:"subject"`       // digest the artifact is attached to
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// Finder looks up artifacts attached to one image.
+type Finder struct {
+	ref    name.Reference
+	digest v1.Hash
+	opts   []remote.Option
+}
+
+// NewFinder returns a Finder for the image with the given manifest digest,
+// loaded from ref. opts configure registry access (auth, transport).
+func NewFinder(ref name.Reference, imageDigest string, opts ...remote.Option) (*Finder, error) {
+	h, err := v1.NewHash(imageDigest)
+	if err != nil {
+		return nil, fmt.Errorf("parse digest: %w", err)
+	}
+	return &Finder{ref: ref, digest: h, opts: opts}, nil
+}
+
+// remoteOptions returns the finder's registry options with requests bound to ctx.
+func (f *Finder) remoteOptions(ctx context.Context) []remote.Option {
+	return append(slices.Clip(f.opts), remote.WithContext(ctx))
+}
+
+// List finds every artifact attached to the image. Signatures are usually
+// made over a multi-platform index rather than the platform's manifest, so
+// when ref names an index that lists the image its digest is searched too.
+func (f *Finder) List(ctx context.Context) ([]Artifact, error) {
+	opts := f.remoteOptions(ctx)
+	desc, err := remote.Get(f.ref, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("looking up %s: %w", f.ref, err)
+	}
+
+	subjects := []v1.Hash{f.digest}
+	var found []Artifact
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return nil, fmt.Errorf("index: %w", err)
+		}
+		im, err := idx.IndexManifest()
+		if err != nil {
+			return nil, fmt.Errorf("index manifest: %w", err)
+		}
+		// An image loaded from a daemon or tarball may not be in the index ref
+		// names now; the index's signatures don't cover it then.
+		if slices.ContainsFunc(im.Manifests, func(m v1.Descriptor) bool { return m.Digest == f.digest }) {
+			subjects = append(subjects, desc.Digest)
+		}
+		for _, m := range im.Manifests {
+			if m.Annotations[dockerReferenceType] == "attestation-manifest" && m.Annotations[dockerReferenceDigest] == f.digest.String() {
+				a := fromDescriptor(m, SourceIndex, f.digest)
+				a.Kind = KindAttestation
+				found = append(found, a)
+			}
+		}
+	}
+
+	repo := f.ref.Context()
+	for _, subject := range subjects {
+		idx, err := remote.Referrers(repo.Digest(subject.String()), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("referrers of %s: %w", subject, err)
+		}
+		im, err := idx.IndexManifest()
+		if err != nil {
+			return nil, fmt.Errorf("referrers index: %w", err)
+		}
+		for _, m := range im.Manifests {
+			found = append(found, fromDescriptor(m, SourceReferrers, subject))
+		}
+
+		for _, c := range cosignSuffixes {
+			tag := repo.Tag(strings.Replace(subject.String(), ":", "-", 1) + "." + c.suffix)
+			d, err := remote.Get(tag, opts...)
+			if isNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", tag, err)
+			}
+			a := fromDescriptor(d.Descriptor, SourceTag, subject)
+			a.Kind = c.kind
+			a.Tag = tag.TagStr()
+			found = append(found, a)
+		}
+	}
+
+	// The same manifest can be reachable both ways
+	seen := map[string]bool{}
+	artifacts := []Artifact{}
+	for _, a := range found {
+		if !seen[a.Digest] {
+			seen[a.Digest] = true
+			artifacts = append(artifacts, a)
+		}
+	}
+	return artifacts, nil
+}
+
+func fromDescriptor(d v1.Descriptor, source string, subject v1.Hash) Artifact {
+	a := Artifact{
+		Digest:       d.Digest.String(),
+		MediaType:    string(d.MediaType),
+		ArtifactType: d.ArtifactType,
+		Source:       source,
+		Subject:      subject.String(),
+		Size:         d.Size,
+		Annotations:  d.Annotations,
+	}
+	a.Kind = classify(a.ArtifactType)
+	// Sigstore bundles carry both signatures and attestations
+	if a.Annotations[bundlePredicateType] != "" {
+		a.Kind = KindAttestation
+	}
+	return a
+}
+
+// classify guesses a kind from an artifact or layer media type.
+func classify(mediaType string) Kind {
+	switch {
+	case mediaType == "":
+		return KindOther
+	case strings.Contains(mediaType, "cosign.artifact.sig"),
+		strings.Contains(mediaType, "simplesigning"),
+		strings.Contains(mediaType, "notary"),
+		strings.Contains(mediaType, "sigstore.bundle"):
+		return KindSignature
+	case strings.Contains(mediaType, "spdx"), strings.Contains(mediaType, "cyclonedx"), strings.Contains(mediaType, "syft"):
+		return KindSBOM
+	case strings.Contains(mediaType, "in-toto"), strings.Contains(mediaType, "dsse"), strings.Contains(mediaType, "slsa"):
+		return KindAttestation
+	}
+	return KindOther
+}
+
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,253 @@
+package referrers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const provenanceV02 = `{
+  "builder": {"id": "https://github.com/actions/runner"},
+  "buildType": "https://mobyproject.org/buildkit@v1",
+  "invocation": {"configSource": {"entryPoint": "Dockerfile"}},
+  "materials": [
+    {"uri": "pkg:docker/alpine@3.19", "digest": {"sha256": "abc"}}
+  ],
+  "metadata": {
+    "https://mobyproject.org/buildkit@v1#metadata": {
+      "vcs": {"source": "https://github.com/example/app", "revision": "deadbeef"}
+    }
+  }
+}`
+
+// registryImage pushes a random image to an in-process registry and returns
+// its reference and digest.
+func registryImage(t *testing.T) (name.Reference, v1.Image, v1.Hash) {
+	t.Helper()
+	srv := httptest.NewServer(registry.New(registry.WithReferrersSupport(true)))
+	t.Cleanup(srv.Close)
+
+	ref, err := name.ParseReference(strings.TrimPrefix(srv.URL, "http://") + "/example/app:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref, img, digest
+}
+
+// artifact builds a single-layer artifact image.
+func artifact(t *testing.T, configType types.MediaType, layerType types.MediaType, content string, annotations map[string]string) v1.Image {
+	t.Helper()
+	img, err := mutate.Append(mutate.ConfigMediaType(empty.Image, configType), mutate.Addendum{
+		Layer:       static.NewLayer([]byte(content), layerType),
+		Annotations: annotations,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mutate.MediaType(img, types.OCIManifestSchema1)
+}
+
+func attach(t *testing.T, ref name.Reference, subject v1.Image, a v1.Image) v1.Hash {
+	t.Helper()
+	desc, err := partial.Descriptor(subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a = mutate.Subject(a, *desc).(v1.Image)
+	d, err := a.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref.Context().Digest(d.String()), a); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func tag(t *testing.T, ref name.Reference, subject v1.Hash, suffix string, a v1.Image) v1.Hash {
+	t.Helper()
+	tag := ref.Context().Tag(strings.Replace(subject.String(), ":", "-", 1) + "." + suffix)
+	if err := remote.Write(tag, a); err != nil {
+		t.Fatal(err)
+	}
+	d, err := a.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestList(t *testing.T) {
+	ref, img, digest := registryImage(t)
+
+	sbom := attach(t, ref, img, artifact(t, "application/spdx+json", "application/spdx+json", `{"spdxVersion":"SPDX-2.3"}`, nil))
+	payload := `{"critical":{"identity":{"docker-reference":"example/app"},"image":{"docker-manifest-digest":"` + digest.String() + `"},"type":"cosign container image signature"}}`
+	sig := tag(t, ref, digest, "sig", artifact(t, types.OCIConfigJSON, mediaTypeSimpleSigning, payload, map[string]string{cosignSignature: "c2ln"}))
+
+	f, err := NewFinder(ref, digest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifacts, err := f.List(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 2 {
+		t.Fatalf("expected 2 artifacts, got %+v", artifacts)
+	}
+	byDigest := map[string]Artifact{}
+	for _, a := range artifacts {
+		byDigest[a.Digest] = a
+	}
+	if a := byDigest[sbom.String()]; a.Kind != KindSBOM || a.Source != SourceReferrers || a.Subject != digest.String() {
+		t.Errorf("unexpected SBOM artifact: %+v", a)
+	}
+	if a := byDigest[sig.String()]; a.Kind != KindSignature || a.Source != SourceTag || !strings.HasSuffix(a.Tag, ".sig") {
+		t.Errorf("unexpected signature artifact: %+v", a)
+	}
+}
+
+func TestList_None(t *testing.T) {
+	ref, _, digest := registryImage(t)
+	f, err := NewFinder(ref, digest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifacts, err := f.List(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 0 {
+		t.Errorf("expected no artifacts, got %+v", artifacts)
+	}
+}
+
+func TestList_Cancelled(t *testing.T) {
+	ref, _, digest := registryImage(t)
+	f, err := NewFinder(ref, digest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := f.List(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	ref, img, digest := registryImage(t)
+
+	statement := `{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"https://slsa.dev/provenance/v0.2",` +
+		`"subject":[{"name":"example/app","digest":{"sha256":"` + digest.Hex + `"}}],"predicate":` + provenanceV02 + `}`
+	env, err := json.Marshal(envelope{
+		PayloadType: "application/vnd.in-toto+json",
+		Payload:     base64.StdEncoding.EncodeToString([]byte(statement)),
+		Signatures: []struct {
+			KeyID string `json:"keyid"`
+			Sig   string `json:"sig"`
+		}{{Sig: "c2ln"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	att := tag(t, ref, digest, "att", artifact(t, types.OCIConfigJSON, mediaTypeDSSE, string(env), nil))
+	sbom := attach(t, ref, img, artifact(t, "application/vnd.cyclonedx+json", "application/vnd.cyclonedx+json", `{"bomFormat":"CycloneDX"}`, nil))
+
+	f, err := NewFinder(ref, digest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := f.Inspect(t.Context(), att.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Kind != KindAttestation || len(d.Attestations) != 1 {
+		t.Fatalf("unexpected detail: %+v", d)
+	}
+	a := d.Attestations[0]
+	if !a.Signed || a.PredicateType != "https://slsa.dev/provenance/v0.2" || len(a.Subjects) != 1 {
+		t.Errorf("unexpected attestation: %+v", a)
+	}
+	p := a.Provenance
+	if p == nil || p.BuilderID != "https://github.com/actions/runner" || p.SourceRepo != "https://github.com/example/app" ||
+		p.SourceRevision != "deadbeef" || p.EntryPoint != "Dockerfile" || len(p.Materials) != 1 {
+		t.Errorf("unexpected provenance: %+v", p)
+	}
+
+	d, err = f.Inspect(t.Context(), sbom.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Kind != KindSBOM || d.Subject != digest.String() || len(d.SBOMs) != 1 || d.SBOMs[0].Format != "cyclonedx" {
+		t.Errorf("unexpected SBOM detail: %+v", d)
+	}
+}
+
+func TestInspect_TruncatedAttestation(t *testing.T) {
+	ref, img, digest := registryImage(t)
+
+	// Cut at maxBlobBytes the statement isn't valid JSON
+	statement := `{"predicateType":"https://example.com/big","predicate":"` + strings.Repeat("a", maxBlobBytes) + `"}`
+	big := attach(t, ref, img, artifact(t, types.OCIConfigJSON, mediaTypeInToto, statement, nil))
+
+	f, err := NewFinder(ref, digest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := f.Inspect(t.Context(), big.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Attestations) != 0 || len(d.Blobs) != 1 {
+		t.Fatalf("expected the layer as a blob, got %+v", d)
+	}
+	if b := d.Blobs[0]; !b.Truncated || b.MediaType != mediaTypeInToto || b.Size != int64(len(statement)) {
+		t.Errorf("unexpected blob: %+v", b)
+	}
+}
+
+func TestDecodeProvenance_V1(t *testing.T) {
+	p := decodeProvenance("https://slsa.dev/provenance/v1", json.RawMessage(`{
+  "buildDefinition": {
+    "buildType": "https://actions.github.io/buildtypes/workflow/v1",
+    "externalParameters": {"workflow": {"repository": "https://github.com/example/app", "ref": "refs/heads/main", "path": ".github/workflows/release.yml"}},
+    "resolvedDependencies": [{"uri": "git+https://github.com/example/app@refs/heads/main", "digest": {"gitCommit": "deadbeef"}}]
+  },
+  "runDetails": {"builder": {"id": "https://github.com/actions/runner/github-hosted"}}
+}`))
+	if p == nil {
+		t.Fatal("expected provenance")
+	}
+	if p.SLSAVersion != "1" || p.SourceRepo != "https://github.com/example/app" || p.EntryPoint != ".github/workflows/release.yml" ||
+		p.BuilderID != "https://github.com/actions/runner/github-hosted" || len(p.Materials) != 1 {
+		t.Errorf("unexpected provenance: %+v", p)
+	}
+}
@@ -0,0 +1,103 @@
+package referrers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// Verification is the outcome of checking one signature against a key.
+type Verification struct {
+	Artifact string `json:"artifact"` // digest of the signature manifest
+	Subject  string `json:"subject"`  // digest the signature is attached to
+	Identity string `json:"identity"`
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// LoadPublicKey parses a PEM-encoded public key, as written by
+// `cosign generate-key-pair`.
+func LoadPublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+	switch pub.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return pub, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", pub)
+}
+
+// Verify checks every cosign signature attached to the image against pub.
+// Only the signature over the simple-signing payload and the digest it
+// claims are checked; no transparency log or certificate chain is consulted,
+// so keyless signatures never verify.
+func (f *Finder) Verify(ctx context.Context, pub crypto.PublicKey) ([]Verification, error) {
+	artifacts, err := f.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := []Verification{}
+	for _, a := range artifacts {
+		if a.Kind != KindSignature {
+			continue
+		}
+		d, err := f.Inspect(ctx, a.Digest)
+		if err != nil {
+			return nil, err
+		}
+		for _, sig := range d.Signatures {
+			v := Verification{Artifact: a.Digest, Subject: a.Subject, Identity: sig.Identity}
+			if err := verifySignature(pub, sig, a.Subject); err != nil {
+				v.Error = err.Error()
+			} else {
+				v.Verified = true
+			}
+			results = append(results, v)
+		}
+	}
+	return results, nil
+}
+
+func verifySignature(pub crypto.PublicKey, sig Signature, subject string) error {
+	if sig.ManifestDigest != subject {
+		return fmt.Errorf("payload signs %s, not %s", sig.ManifestDigest, subject)
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+	payload := []byte(sig.Payload)
+	hash := sha256.Sum256(payload)
+
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, hash[:], raw) {
+			return errors.New("signature does not match key")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], raw); err != nil {
+			return errors.New("signature does not match key")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, payload, raw) {
+			return errors.New("signature does not match key")
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", pub)
+	}
+	return nil
+}
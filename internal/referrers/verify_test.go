@@ -0,0 +1,150 @@
+package referrers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"slices"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func signPayload(t *testing.T, key *ecdsa.PrivateKey, payload string) string {
+	t.Helper()
+	hash := sha256.Sum256([]byte(payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	ref, _, digest := registryImage(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := LoadPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := `{"critical":{"identity":{"docker-reference":"example/app"},"image":{"docker-manifest-digest":"` + digest.String() + `"},"type":"cosign container image signature"}}`
+	tag(t, ref, digest, "sig", artifact(t, types.OCIConfigJSON, mediaTypeSimpleSigning, payload,
+		map[string]string{cosignSignature: signPayload(t, key, payload)}))
+
+	f, err := NewFinder(ref, digest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := f.Verify(t.Context(), pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].Verified || results[0].Identity != "example/app" {
+		t.Fatalf("expected one verified signature, got %+v", results)
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = f.Verify(t.Context(), &other.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Verified || results[0].Error == "" {
+		t.Errorf("expected verification to fail with another key, got %+v", results)
+	}
+}
+
+func TestVerify_Index(t *testing.T) {
+	ref, img, digest := registryImage(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		images   []v1.Image
+		verified bool
+	}{
+		{"image in index", []v1.Image{img, other}, true},
+		// As for an image loaded from the daemon after the tag moved on
+		{"image not in index", []v1.Image{other}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := v1.ImageIndex(empty.Index)
+			for _, im := range tt.images {
+				idx = mutate.AppendManifests(idx, mutate.IndexAddendum{Add: im})
+			}
+			if err := remote.WriteIndex(ref, idx); err != nil {
+				t.Fatal(err)
+			}
+			idxDigest, err := idx.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload := `{"critical":{"identity":{"docker-reference":"example/app"},"image":{"docker-manifest-digest":"` + idxDigest.String() + `"},"type":"cosign container image signature"}}`
+			tag(t, ref, idxDigest, "sig", artifact(t, types.OCIConfigJSON, mediaTypeSimpleSigning, payload,
+				map[string]string{cosignSignature: signPayload(t, key, payload)}))
+
+			f, err := NewFinder(ref, digest.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := f.Verify(t.Context(), &key.PublicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verified := slices.ContainsFunc(results, func(v Verification) bool { return v.Verified })
+			if verified != tt.verified {
+				t.Errorf("expected verified = %v, got %+v", tt.verified, results)
+			}
+		})
+	}
+}
+
+func TestVerifySignature_WrongDigest(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := `{"critical":{"image":{"docker-manifest-digest":"sha256:aaaa"}}}`
+	sig := Signature{ManifestDigest: "sha256:aaaa", Payload: payload, Signature: signPayload(t, key, payload)}
+	if err := verifySignature(&key.PublicKey, sig, "sha256:bbbb"); err == nil {
+		t.Error("expected a signature over another digest to fail")
+	}
+	if err := verifySignature(&key.PublicKey, sig, "sha256:aaaa"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadPublicKey_Invalid(t *testing.T) {
+	if _, err := LoadPublicKey([]byte("not a key")); err == nil {
+		t.Error("expected error for non-PEM input")
+	}
+}
@@ -1,11 +1,19 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/coffee-cup/peel/internal/dockerfile"
 	"github.com/coffee-cup/peel/internal/image"
+	"github.com/coffee-cup/peel/internal/referrers"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 func writeJSON(w http.ResponseWriter, status int, data any) {
@@ -28,7 +36,7 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	} else if s.image != nil {
 		status = "ready"
 	}
-	writeJSON(w, http.StatusOK, map[string]string{"status": status, "ref": s.ref})
+	writeJSON(w, http.StatusOK, map[string]string{"status": status, "ref": s.target.Ref})
 }
 
 func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
@@ -39,29 +47,59 @@ func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, img.Info)
 }
 
-func (s *Server) handleLayers(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleRawConfig(w http.ResponseWriter, r *http.Request) {
 	img := s.requireImage(w)
 	if img == nil {
 		return
 	}
-	writeJSON(w, http.StatusOK, img.Layers)
+	raw, err := img.RawConfig()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeRaw(w, "application/json", raw)
 }
 
-func (s *Server) handleLayerTree(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleRawManifest(w http.ResponseWriter, r *http.Request) {
 	img := s.requireImage(w)
 	if img == nil {
 		return
 	}
-	id, err := strconv.Atoi(r.PathValue("id"))
+	raw, err := img.RawManifest()
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid layer id")
+		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	if id < 0 || id >= len(img.Trees) {
-		writeError(w, http.StatusNotFound, "layer not found")
+	writeRaw(w, "application/json", raw)
+}
+
+// writeRaw sends the image's JSON byte for byte, so digests computed over
+// the response match the image's. Registry and OCI layout images keep what
+// was fetched; for daemon images go-containerregistry synthesizes the
+// manifest, which need not match the one in any registry.
+func writeRaw(w http.ResponseWriter, contentType string, raw []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(raw)
+}
+
+func (s *Server) handleLayers(w http.ResponseWriter, r *http.Request) {
+	img := s.requireImage(w)
+	if img == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, img.Layers)
+}
+
+func (s *Server) handleLayerTree(w http.ResponseWriter, r *http.Request) {
+	img := s.requireImage(w)
+	if img == nil {
+		return
+	}
+	_, tree, ok := requestTree(w, r, img)
+	if !ok {
 		return
 	}
-	tree := img.Trees[id]
 	if tree == nil {
 		writeJSON(w, http.StatusOK, &image.FileNode{
 			Name: "/", Path: "/", Type: image.FileTypeDir,
@@ -71,6 +109,56 @@ func (s *Server) handleLayerTree(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, tree)
 }
 
+func (s *Server) handleLayerDir(w http.ResponseWriter, r *http.Request) {
+	img := s.requireImage(w)
+	if img == nil {
+		return
+	}
+	id, tree, ok := requestTree(w, r, img)
+	if !ok {
+		return
+	}
+	entries, err := image.ListDir(tree, img.LayerTrees[id], "/"+r.PathValue("path"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	switch sort := r.URL.Query().Get("sort"); sort {
+	case "", "name":
+	case "size":
+		image.SortDirEntriesBySize(entries)
+	default:
+		writeError(w, http.StatusBadRequest, "invalid sort "+strconv.Quote(sort)+", expected name or size")
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// requestTree picks the layer and tree for the {id} path value and ?view= query
+// parameter. The tree is nil for layers with no content yet. Writes an error
+// response and returns false if the request is invalid.
+func requestTree(w http.ResponseWriter, r *http.Request, img *image.Image) (int, *image.FileNode, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid layer id")
+		return 0, nil, false
+	}
+	if id < 0 || id >= len(img.Trees) {
+		writeError(w, http.StatusNotFound, "layer not found")
+		return 0, nil, false
+	}
+
+	switch view := r.URL.Query().Get("view"); view {
+	case "", "cumulative":
+		return id, img.Trees[id], true
+	case "layer":
+		return id, img.LayerTrees[id], true
+	default:
+		writeError(w, http.StatusBadRequest, "invalid view "+strconv.Quote(view)+", expected cumulative or layer")
+		return 0, nil, false
+	}
+}
+
 func (s *Server) handleLayerDiff(w http.ResponseWriter, r *http.Request) {
 	img := s.requireImage(w)
 	if img == nil {
@@ -100,10 +188,380 @@ func (s *Server) handleFileContent(w http.ResponseWriter, r *http.Request) {
 	}
 	filePath := "/" + r.PathValue("path")
 
-	fc, err := img.ReadFile(layer, filePath)
+	fc, err := img.ReadFile(r.Context(), layer, filePath)
 	if err != nil {
-		writeError(w, http.StatusNotFound, err.Error())
+		writeReadError(w, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, fc)
 }
+
+func (s *Server) handleBlame(w http.ResponseWriter, r *http.Request) {
+	img := s.requireImage(w)
+	if img == nil {
+		return
+	}
+	filePath := "/" + r.PathValue("path")
+
+	changes := img.PathHistory(filePath)
+	if len(changes) == 0 {
+		writeError(w, http.StatusNotFound, "path not found in any layer: "+filePath)
+		return
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	img := s.requireImage(w)
+	if img == nil {
+		return
+	}
+	filePath := "/" + r.PathValue("path")
+
+	versions, err := img.FileHistory(r.Context(), filePath)
+	if err != nil {
+		writeReadError(w, err)
+		return
+	}
+	if len(versions) == 0 {
+		writeError(w, http.StatusNotFound, "path not found in any layer: "+filePath)
+		return
+	}
+	writeJSON(w, http.StatusOK, versions)
+}
+
+func (s *Server) handleCompare(w http.ResponseWriter, r *http.Request) {
+	img := s.requireImage(w)
+	if img == nil {
+		return
+	}
+	from, err := image.ParseFileRef(r.URL.Query().Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "from: "+err.Error())
+		return
+	}
+	to, err := image.ParseFileRef(r.URL.Query().Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "to: "+err.Error())
+		return
+	}
+
+	toImg := img
+	switch r.URL.Query().Get("to_image") {
+	case "":
+	case "previous":
+		s.mu.RLock()
+		back := s.back
+		s.mu.RUnlock()
+		if back == nil {
+			writeError(w, http.StatusNotFound, "no previous image to compare with")
+			return
+		}
+		toImg = back.image
+	default:
+		writeError(w, http.StatusBadRequest, `to_image: expected "previous"`)
+		return
+	}
+
+	fd, err := image.CompareFiles(r.Context(), img, from, toImg, to)
+	if err != nil {
+		writeReadError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, fd)
+}
+
+// writeReadError reports why file content couldn't be read: 404 for layers
+// and paths the image doesn't have, 503 when the read was cancelled, and 500
+// for failures such as a layer that couldn't be fetched.
+func writeReadError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, image.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusServiceUnavailable
+	}
+	writeError(w, status, err.Error())
+}
+
+func (s *Server) handleDiffRange(w http.ResponseWriter, r *http.Request) {
+	img := s.requireImage(w)
+	if img == nil {
+		return
+	}
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from layer")
+		return
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to layer")
+		return
+	}
+
+	diffs, err := img.DiffRange(from, to)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, diffs)
+}
+
+type dockerfileResponse struct {
+	Content string            `json:"content"` // reconstructed from history
+	Source  *dockerfileSource `json:"source,omitempty"`
+}
+
+type dockerfileSource struct {
+	Path      string               `json:"path"`
+	Content   string               `json:"content"`
+	Alignment dockerfile.Alignment `json:"alignment"`
+}
+
+func (s *Server) handleDockerfile(w http.ResponseWriter, r *http.Request) {
+	img := s.requireImage(w)
+	if img == nil {
+		return
+	}
+	resp := dockerfileResponse{Content: img.Dockerfile()}
+
+	s.mu.RLock()
+	src := s.source
+	s.mu.RUnlock()
+	if src != nil {
+		instructions := make([]string, len(img.Layers))
+		for i, l := range img.Layers {
+			instructions[i] = l.Instruction
+		}
+		resp.Source = &dockerfileSource{
+			Path:      src.path,
+			Content:   src.content,
+			Alignment: dockerfile.Align(src.file, instructions),
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// requireFinder returns the referrers finder or writes an error response.
+func (s *Server) requireFinder(w http.ResponseWriter) *referrers.Finder {
+	if s.requireImage(w) == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.finder == nil {
+		writeError(w, http.StatusNotFound, "image was not loaded from a registry")
+		return nil
+	}
+	return s.finder
+}
+
+func (s *Server) handleReferrers(w http.ResponseWriter, r *http.Request) {
+	f := s.requireFinder(w)
+	if f == nil {
+		return
+	}
+	artifacts, err := f.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, artifacts)
+}
+
+func (s *Server) handleReferrer(w http.ResponseWriter, r *http.Request) {
+	f := s.requireFinder(w)
+	if f == nil {
+		return
+	}
+	digest := r.PathValue("digest")
+	if _, err := v1.NewHash(digest); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid digest")
+		return
+	}
+	detail, err := f.Inspect(r.Context(), digest)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	f := s.requireFinder(w)
+	if f == nil {
+		return
+	}
+	s.mu.RLock()
+	key := s.key
+	s.mu.RUnlock()
+	if key == nil {
+		writeError(w, http.StatusNotFound, "no verification key; start peel with --key")
+		return
+	}
+	results, err := f.Verify(r.Context(), key)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handleRegistry serves /api/registry/{host}/catalog and
+// /api/registry/{repo}/tags. Both take ?n= and ?last= for paging.
+func (s *Server) handleRegistry(w http.ResponseWriter, r *http.Request) {
+	n := image.DefaultPageSize
+	if v := r.URL.Query().Get("n"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			writeError(w, http.StatusBadRequest, "invalid n")
+			return
+		}
+		n = min(parsed, image.MaxPageSize)
+	}
+	last := r.URL.Query().Get("last")
+
+	s.mu.RLock()
+	reg, platform, offline := s.registries, s.platform, s.offline
+	s.mu.RUnlock()
+	if offline {
+		writeError(w, http.StatusForbidden, "registry access is disabled with --offline")
+		return
+	}
+	if platform.OS == "" {
+		platform, _ = image.ParsePlatform("")
+	}
+
+	path := r.PathValue("path")
+	if host, ok := strings.CutSuffix(path, "/catalog"); ok {
+		page, err := reg.Catalog(r.Context(), host, last, n)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, page)
+		return
+	}
+	if repo, ok := strings.CutSuffix(path, "/tags"); ok {
+		page, err := reg.Tags(r.Context(), repo, platform, last, n)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, page)
+		return
+	}
+	writeError(w, http.StatusNotFound, "expected /api/registry/{host}/catalog or /api/registry/{repo}/tags")
+}
+
+// handleReload starts re-resolving the image in the background. Progress is
+// reported on /api/events.
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	job, err := s.startReload(s.Target())
+	if err != nil {
+		writeReloadError(w, err)
+		return
+	}
+	// the reload outlives this request
+	go s.runReload(context.WithoutCancel(r.Context()), job)
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "loading", "ref": job.target.Ref})
+}
+
+// handleOpen switches to another image, loading it in the background like
+// a reload. Going back to the previously shown image is immediate.
+func (s *Server) handleOpen(w http.ResponseWriter, r *http.Request) {
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "expected an application/json body")
+		return
+	}
+	var t Target
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	t.Ref = strings.TrimSpace(t.Ref)
+	if t.Ref == "" {
+		writeError(w, http.StatusBadRequest, "ref is required")
+		return
+	}
+	if t.Platform != "" {
+		if _, err := image.ParsePlatform(t.Platform); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	src, err := image.ParseSource(t.Source)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.RLock()
+	if t.Source == "" {
+		src = s.imageSource
+	}
+	home := s.home
+	s.mu.RUnlock()
+	// These read paths on disk, which a page shouldn't get to choose
+	if (src == image.SourceOCI || src == image.SourceContainerd) && t != home {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("images from --source %s can only be opened from the command line", src))
+		return
+	}
+
+	job, err := s.startOpen(t)
+	if err != nil {
+		writeReloadError(w, err)
+		return
+	}
+	if job == nil {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ready", "ref": t.Ref})
+		return
+	}
+	go s.runReload(context.WithoutCancel(r.Context()), job)
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "loading", "ref": t.Ref})
+}
+
+// writeReloadError reports why a load couldn't start.
+func writeReloadError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errNoLoader):
+		status = http.StatusNotImplemented
+	case errors.Is(err, errReloading):
+		status = http.StatusConflict
+	}
+	writeError(w, status, err.Error())
+}
+
+// handleEvents streams load events as server-sent events until the client
+// disconnects.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	events, unsubscribe := s.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-events:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
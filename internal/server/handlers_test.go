@@ -2,25 +2,37 @@ package server
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 
 	"github.com/coffee-cup/peel/internal/image"
+	"github.com/coffee-cup/peel/internal/referrers"
 )
 
 func testServer(t *testing.T) *httptest.Server {
 	t.Helper()
 	img := buildTestImage(t)
-	analyzed, err := image.Analyze(img, "test:latest")
+	analyzed, err := image.Analyze(t.Context(), img, "test:latest", image.AnalyzeOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -109,6 +121,105 @@ func TestLayerDiff(t *testing.T) {
 	}
 }
 
+func TestLayerTree_LayerView(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/layers/1/tree?view=layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var tree image.FileNode
+	json.NewDecoder(resp.Body).Decode(&tree)
+	for _, c := range tree.Children {
+		if c.Name == "usr" {
+			t.Fatal("layer view should only contain entries from layer 1")
+		}
+	}
+}
+
+func TestLayerTree_InvalidView(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/layers/0/tree?view=bogus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestLayerDir(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/layers/0/dir/usr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var entries []image.DirEntry
+	json.NewDecoder(resp.Body).Decode(&entries)
+	if len(entries) != 1 || entries[0].Path != "/usr/bin" {
+		t.Fatalf("expected /usr/bin, got %+v", entries)
+	}
+	if entries[0].ChildCount != 1 || entries[0].TotalSize != int64(len("#!/bin/sh\n")) {
+		t.Fatalf("unexpected aggregates: %+v", entries[0])
+	}
+}
+
+func TestLayerDir_Root(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	for _, url := range []string{"/api/layers/0/dir", "/api/layers/0/dir/"} {
+		resp, err := http.Get(srv.URL + url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("%s: expected 200, got %d", url, resp.StatusCode)
+		}
+		var entries []image.DirEntry
+		json.NewDecoder(resp.Body).Decode(&entries)
+		if len(entries) != 2 {
+			t.Fatalf("%s: expected 2 root entries, got %d", url, len(entries))
+		}
+	}
+}
+
+func TestLayerDir_SortBySize(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/layers/0/dir/?sort=size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var entries []image.DirEntry
+	json.NewDecoder(resp.Body).Decode(&entries)
+	if len(entries) != 2 || entries[0].Path != "/usr" {
+		t.Fatalf("expected /usr first, got %+v", entries)
+	}
+}
+
+func TestLayerDir_NotFound(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/layers/0/dir/nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
 func TestLayerTree_NotFound(t *testing.T) {
 	srv := testServer(t)
 	defer srv.Close()
@@ -150,6 +261,785 @@ func TestFileContent_NotFound(t *testing.T) {
 	}
 }
 
+func TestBlame(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/blame/etc/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var changes []image.PathChange
+	json.NewDecoder(resp.Body).Decode(&changes)
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", changes)
+	}
+	if changes[0].ChangeKind != image.ChangeAdded || changes[1].ChangeKind != image.ChangeModified {
+		t.Fatalf("unexpected change kinds: %+v", changes)
+	}
+}
+
+func TestBlame_NotFound(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/blame/nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/history/etc/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var versions []image.FileVersion
+	json.NewDecoder(resp.Body).Decode(&versions)
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(versions))
+	}
+	if versions[1].Layer != 1 || versions[1].Content.Content != "hello2\n" || versions[1].Diff == "" {
+		t.Fatalf("unexpected second version: %+v", versions[1])
+	}
+}
+
+func TestDiffRange(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/diff?from=-1&to=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var diffs []image.DiffEntry
+	json.NewDecoder(resp.Body).Decode(&diffs)
+	paths := map[string]bool{}
+	for _, d := range diffs {
+		paths[d.Path] = true
+	}
+	if !paths["/etc/hello"] || !paths["/var/new"] {
+		t.Fatalf("expected changes from both layers, got %+v", diffs)
+	}
+}
+
+func TestDiffRange_Invalid(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	for url, want := range map[string]int{
+		"/api/diff?from=a&to=1": 400,
+		"/api/diff?from=0&to=9": 404,
+	} {
+		resp, err := http.Get(srv.URL + url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("%s: expected %d, got %d", url, want, resp.StatusCode)
+		}
+	}
+}
+
+func TestRawConfigAndManifest(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/config/raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cf v1.ConfigFile
+	if err := json.NewDecoder(resp.Body).Decode(&cf); err != nil {
+		t.Fatal(err)
+	}
+	if cf.Architecture != "amd64" || len(cf.History) != 2 {
+		t.Errorf("unexpected config: %+v", cf)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/manifest/raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m v1.Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Layers) != 2 || m.Config.Digest.String() == "" {
+		t.Errorf("unexpected manifest: %+v", m)
+	}
+}
+
+func TestDockerfile(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var body dockerfileResponse
+	json.NewDecoder(resp.Body).Decode(&body)
+	if !strings.HasSuffix(body.Content, "ADD . /\nCOPY . /\n") {
+		t.Fatalf("unexpected Dockerfile:\n%s", body.Content)
+	}
+	if body.Source != nil {
+		t.Fatalf("expected no source Dockerfile, got %+v", body.Source)
+	}
+}
+
+func TestDockerfile_Source(t *testing.T) {
+	analyzed, err := image.Analyze(t.Context(), buildTestImage(t), "test:latest", image.AnalyzeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New("test:latest")
+	s.SetImage(analyzed)
+	if err := s.SetDockerfile("Dockerfile", []byte("FROM scratch\nADD . /\nCOPY . /\nCMD [\"/app\"]\n")); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body dockerfileResponse
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.Source == nil || body.Source.Path != "Dockerfile" {
+		t.Fatalf("expected source Dockerfile, got %+v", body.Source)
+	}
+	a := body.Source.Alignment
+	if len(a.Matches) != 2 || a.Matches[0].Line != 2 || a.Matches[1].Line != 3 {
+		t.Errorf("unexpected matches: %+v", a.Matches)
+	}
+	if len(a.Unmatched) != 1 || a.Unmatched[0].Keyword != "CMD" {
+		t.Errorf("expected CMD unmatched, got %+v", a.Unmatched)
+	}
+
+	if err := s.SetDockerfile("Dockerfile", []byte("RUN true\n")); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+func TestReferrers(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/referrers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 without a registry, got %d", resp.StatusCode)
+	}
+
+	reg := httptest.NewServer(registry.New(registry.WithReferrersSupport(true)))
+	defer reg.Close()
+	ref, err := name.ParseReference(strings.TrimPrefix(reg.URL, "http://") + "/test:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := buildTestImage(t)
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+	analyzed, err := image.Analyze(t.Context(), img, ref.String(), image.AnalyzeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := referrers.NewFinder(ref, analyzed.Info.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(ref.String())
+	s.SetImage(analyzed)
+	s.SetReferrers(f)
+	withReg := httptest.NewServer(s)
+	defer withReg.Close()
+
+	resp, err = http.Get(withReg.URL + "/api/referrers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var artifacts []referrers.Artifact
+	if err := json.NewDecoder(resp.Body).Decode(&artifacts); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || artifacts == nil || len(artifacts) != 0 {
+		t.Errorf("expected empty list, got %d %+v", resp.StatusCode, artifacts)
+	}
+
+	resp, err = http.Get(withReg.URL + "/api/referrers/nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for bad digest, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(withReg.URL + "/api/verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 without a key, got %d", resp.StatusCode)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetVerifyKey(&key.PublicKey)
+	resp, err = http.Get(withReg.URL + "/api/verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []referrers.Verification
+	json.NewDecoder(resp.Body).Decode(&results)
+	if resp.StatusCode != http.StatusOK || results == nil || len(results) != 0 {
+		t.Errorf("expected no signatures, got %d %+v", resp.StatusCode, results)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	reg := httptest.NewServer(registry.New())
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+	ref, err := name.ParseReference(host + "/team/app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, buildTestImage(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := testServer(t)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/registry/" + host + "/catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var catalog image.CatalogPage
+	json.NewDecoder(resp.Body).Decode(&catalog)
+	if resp.StatusCode != http.StatusOK || len(catalog.Repositories) != 1 || catalog.Repositories[0] != "team/app" {
+		t.Fatalf("unexpected catalog: %d %+v", resp.StatusCode, catalog)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/registry/" + host + "/team/app/tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tags image.TagPage
+	json.NewDecoder(resp.Body).Decode(&tags)
+	if resp.StatusCode != http.StatusOK || len(tags.Tags) != 1 || tags.Tags[0].Tag != "v1" || tags.Tags[0].Digest == "" {
+		t.Fatalf("unexpected tags: %d %+v", resp.StatusCode, tags)
+	}
+
+	for path, status := range map[string]int{
+		"/api/registry/" + host + "/catalog?n=0": http.StatusBadRequest,
+		"/api/registry/" + host + "/bogus":       http.StatusNotFound,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Errorf("%s: expected %d, got %d", path, status, resp.StatusCode)
+		}
+	}
+}
+
+func TestRegistry_Offline(t *testing.T) {
+	s := New("test:latest")
+	s.SetOffline()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/registry/registry.invalid/catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestReload(t *testing.T) {
+	first := buildTestImage(t)
+	second, err := mutate.AppendLayers(first, buildTarLayer(t, []tarEntry{
+		{name: "etc/extra", typeflag: tar.TypeReg, data: []byte("extra\n")},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := []v1.Image{first, second}
+	loads := 0
+
+	s := New("test:latest")
+	s.SetLoader(func(ctx context.Context, target Target, previous *image.Image) (*Loaded, error) {
+		img := images[min(loads, len(images)-1)]
+		loads++
+		analyzed, err := image.Analyze(ctx, img, target.Ref, image.AnalyzeOptions{Previous: previous})
+		return &Loaded{Image: analyzed}, err
+	})
+	if err := s.Reload(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewScanner(resp.Body)
+	next := func() Event {
+		t.Helper()
+		for events.Scan() {
+			if data, ok := strings.CutPrefix(events.Text(), "data: "); ok {
+				var ev Event
+				if err := json.Unmarshal([]byte(data), &ev); err != nil {
+					t.Fatal(err)
+				}
+				return ev
+			}
+		}
+		t.Fatalf("event stream ended: %v", events.Err())
+		return Event{}
+	}
+	// wait for the subscription before reloading
+	if !events.Scan() || events.Text() != ": connected" {
+		t.Fatalf("unexpected stream start %q", events.Text())
+	}
+
+	post, err := http.Post(srv.URL+"/api/reload", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", post.StatusCode)
+	}
+	if ev := next(); ev.Type != "loading" {
+		t.Fatalf("expected loading event, got %+v", ev)
+	}
+	ev := next()
+	if ev.Type != "loaded" || !ev.Changed || ev.Reused != 2 {
+		t.Fatalf("expected a changed image reusing 2 layers, got %+v", ev)
+	}
+
+	resp2, err := http.Get(srv.URL + "/api/image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info image.ImageInfo
+	json.NewDecoder(resp2.Body).Decode(&info)
+	if info.Digest != ev.Digest {
+		t.Errorf("expected /api/image to serve the reloaded image")
+	}
+}
+
+func TestReload_NoLoader(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Post(srv.URL+"/api/reload", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("expected 501, got %d", resp.StatusCode)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	first := buildTestImage(t)
+	second, err := mutate.AppendLayers(first, buildTarLayer(t, []tarEntry{
+		{name: "etc/extra", typeflag: tar.TypeReg, data: []byte("extra\n")},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := map[string]v1.Image{"test:latest": first, "test:next": second}
+	var loaded []string
+
+	s := New("test:latest")
+	s.SetLoader(func(ctx context.Context, target Target, previous *image.Image) (*Loaded, error) {
+		loaded = append(loaded, target.Ref)
+		analyzed, err := image.Analyze(ctx, images[target.Ref], target.Ref, image.AnalyzeOptions{Previous: previous})
+		return &Loaded{Image: analyzed}, err
+	})
+	if err := s.Reload(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	open := func(body string) int {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"/api/open", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	currentRef := func() string {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/api/image")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var info image.ImageInfo
+		json.NewDecoder(resp.Body).Decode(&info)
+		return info.Ref
+	}
+
+	if code := open(`{"ref":"test:next"}`); code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", code)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Target().Ref != "test:next" || currentRef() != "test:next" {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for test:next to load")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// going back swaps the previous image in without loading it again
+	if code := open(`{"ref":"test:latest"}`); code != http.StatusOK {
+		t.Fatalf("expected 200 going back, got %d", code)
+	}
+	if ref := currentRef(); ref != "test:latest" {
+		t.Errorf("expected test:latest after going back, got %s", ref)
+	}
+	if len(loaded) != 2 {
+		t.Errorf("expected 2 loads, got %v", loaded)
+	}
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	for _, body := range []string{`{}`, `{"ref":"a","platform":"linux"}`, `{"ref":"a","source":"floppy"}`, `not json`} {
+		resp, err := http.Post(srv.URL+"/api/open", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestOpen_Forbidden(t *testing.T) {
+	s := New("./layout")
+	s.SetSource(image.SourceOCI)
+	s.SetLoader(func(ctx context.Context, target Target, previous *image.Image) (*Loaded, error) {
+		return nil, errors.New("not loaded in this test")
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	post := func(body, contentType string, header http.Header) int {
+		t.Helper()
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, srv.URL+"/api/open", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = header.Clone()
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.Header.Set("Content-Type", contentType)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	cases := []struct {
+		name        string
+		body        string
+		contentType string
+		header      http.Header
+		want        int
+	}{
+		{"form post", `{"ref":"a","source":"remote"}`, "text/plain", nil, http.StatusUnsupportedMediaType},
+		{"cross-site", `{"ref":"a","source":"remote"}`, "application/json", http.Header{"Sec-Fetch-Site": {"cross-site"}}, http.StatusForbidden},
+		{"other origin", `{"ref":"a","source":"remote"}`, "application/json", http.Header{"Origin": {"http://evil.example"}}, http.StatusForbidden},
+		{"explicit oci", `{"ref":"/etc","source":"oci"}`, "application/json", nil, http.StatusForbidden},
+		{"containerd", `{"ref":"a@sha256:abc","source":"containerd"}`, "application/json", nil, http.StatusForbidden},
+		{"command line source", `{"ref":"/etc"}`, "application/json", nil, http.StatusForbidden},
+		{"remote", `{"ref":"a","source":"remote"}`, "application/json", http.Header{"Sec-Fetch-Site": {"same-origin"}}, http.StatusAccepted},
+	}
+	for _, c := range cases {
+		if got := post(c.body, c.contentType, c.header); got != c.want {
+			t.Errorf("%s: expected %d, got %d", c.name, c.want, got)
+		}
+		// let the accepted load fail so the next can start
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			s.mu.RLock()
+			reloading := s.reloading
+			s.mu.RUnlock()
+			if !reloading || time.Now().After(deadline) {
+				break
+			}
+		}
+	}
+
+	// The command line's own image can be reopened
+	if got := post(`{"ref":"./layout"}`, "application/json", nil); got != http.StatusAccepted {
+		t.Errorf("home: expected 202, got %d", got)
+	}
+}
+
+func TestHostCheck(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+
+	// A DNS-rebound page is same-origin with itself
+	rebound := "rebind.example:" + port
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/open", strings.NewReader(`{"ref":"a","source":"remote"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = rebound
+	req.Header.Set("Origin", "http://"+rebound)
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("rebound host: expected 403, got %d", resp.StatusCode)
+	}
+
+	s := srv.Config.Handler.(*Server)
+	cases := []struct {
+		listen string
+		host   string
+		want   bool
+	}{
+		{"", "localhost:8080", true},
+		{"", "LOCALHOST", true},
+		{"", "127.0.0.1:8080", true},
+		{"", "[::1]:8080", true},
+		{"", "", true},
+		{"", "rebind.example:8080", false},
+		{"", "192.168.1.5:8080", false},
+		{"192.168.1.5", "192.168.1.5:8080", true},
+		{"192.168.1.5", "192.168.1.6:8080", false},
+		{"0.0.0.0", "192.168.1.6:8080", true},
+		{"0.0.0.0", "rebind.example:8080", false},
+		{"[::]", "[fe80::1]:8080", true},
+		{"peel.internal", "peel.internal:8080", true},
+		{"peel.internal", "rebind.example:8080", false},
+	}
+	for _, c := range cases {
+		s.SetListenHost(c.listen)
+		if got := s.allowedHost(c.host); got != c.want {
+			t.Errorf("listening on %q, host %q: expected %v, got %v", c.listen, c.host, c.want, got)
+		}
+	}
+}
+
+func TestClientRoutes(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	for _, path := range []string{"/", "/layers/1/etc/passwd", "/layers/0..1/usr/share/nginx/html/index.html"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+			t.Errorf("%s: expected the app, got %d %s", path, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/api/nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected unknown API routes to 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	analyzed, err := image.Analyze(t.Context(), buildTestImage(t), "test:latest", image.AnalyzeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New("test:latest")
+	s.SetImage(analyzed)
+	snap, err := s.Snapshot(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, url := range []string{
+		"/api/image",
+		"/api/layers",
+		"/api/dockerfile",
+		"/api/layers/1/diff",
+		"/api/layers/0/dir/?view=cumulative",
+		"/api/layers/1/dir/usr/bin?view=cumulative",
+		"/api/layers/1/dir/var?view=layer",
+	} {
+		if _, ok := snap.Responses[url]; !ok {
+			t.Errorf("expected %s in snapshot", url)
+		}
+	}
+	if _, ok := snap.Responses["/api/layers/1/dir/usr?view=layer"]; ok {
+		t.Error("expected no listing for a directory the layer doesn't touch")
+	}
+	// the first layer's own tree is also its cumulative one
+	if snap.Responses["/api/layers/0/dir/usr/bin?view=cumulative"] != snap.Responses["/api/layers/0/dir/usr/bin?view=layer"] {
+		t.Error("expected identical listings to share a body")
+	}
+
+	var entries []image.DirEntry
+	if err := json.Unmarshal(snap.Bodies[snap.Responses["/api/layers/1/dir/etc?view=cumulative"]], &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Path != "/etc/hello" {
+		t.Errorf("unexpected /etc listing: %+v", entries)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/compare?from=0:/etc/hello&to=1:/etc/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var fd image.FileDiff
+	json.NewDecoder(resp.Body).Decode(&fd)
+	if !strings.Contains(fd.Diff, "+hello2") {
+		t.Fatalf("unexpected diff: %q", fd.Diff)
+	}
+}
+
+func TestCompare_BadRef(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/compare?from=3:&to=/etc/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestCompare_PreviousImage(t *testing.T) {
+	first := buildTestImage(t)
+	second, err := mutate.AppendLayers(first, buildTarLayer(t, []tarEntry{
+		{name: "etc/hello", typeflag: tar.TypeReg, data: []byte("hello3\n")},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := map[string]v1.Image{"test:latest": first, "test:next": second}
+	s := New("test:latest")
+	s.SetLoader(func(ctx context.Context, target Target, previous *image.Image) (*Loaded, error) {
+		analyzed, err := image.Analyze(ctx, images[target.Ref], target.Ref, image.AnalyzeOptions{})
+		return &Loaded{Image: analyzed}, err
+	})
+	if err := s.Reload(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	get := func(query string) (int, image.FileDiff) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/api/compare?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var fd image.FileDiff
+		json.NewDecoder(resp.Body).Decode(&fd)
+		return resp.StatusCode, fd
+	}
+
+	if code, _ := get("from=/etc/hello&to=/etc/hello&to_image=previous"); code != http.StatusNotFound {
+		t.Errorf("expected 404 before another image was opened, got %d", code)
+	}
+
+	job, err := s.startOpen(Target{Ref: "test:next"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.runReload(t.Context(), job); err != nil {
+		t.Fatal(err)
+	}
+
+	code, fd := get("from=/etc/hello&to=/etc/hello&to_image=previous")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if !strings.Contains(fd.Diff, "--- test:next layer 2:/etc/hello\n+++ test:latest layer 1:/etc/hello\n") ||
+		!strings.Contains(fd.Diff, "-hello3\n+hello2\n") {
+		t.Errorf("unexpected diff:\n%s", fd.Diff)
+	}
+	if code, _ := get("from=/etc/hello&to=/etc/hello&to_image=next"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown to_image, got %d", code)
+	}
+}
+
+func TestFileContent_Errors(t *testing.T) {
+	srv := testServer(t)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/compare?from=/nope&to=/etc/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing file: expected 404, got %d", resp.StatusCode)
+	}
+
+	// A cancelled read isn't a missing file
+	s := srv.Config.Handler.(*Server)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/files/0/etc/hello", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("cancelled read: expected 503, got %d", rec.Code)
+	}
+}
+
 // --- test image builder ---
 
 type tarEntry struct {
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/coffee-cup/peel/internal/image"
+	"github.com/coffee-cup/peel/internal/referrers"
+)
+
+// Target is an image to load. Empty Platform and Source fall back to the
+// command line's.
+type Target struct {
+	Ref      string `json:"ref"`
+	Platform string `json:"platform,omitempty"`
+	Source   string `json:"source,omitempty"`
+}
+
+// Loaded is the result of a load.
+type Loaded struct {
+	Image *image.Image
+	// Finder looks up artifacts attached to the image in its registry, nil
+	// when it wasn't loaded from one.
+	Finder *referrers.Finder
+}
+
+// Loader resolves and analyzes t. previous is the image being replaced,
+// nil on the first load, so unchanged layers can be reused.
+type Loader func(ctx context.Context, t Target, previous *image.Image) (*Loaded, error)
+
+// Event is sent to /api/events subscribers as a load progresses.
+type Event struct {
+	Type    string `json:"type"` // "loading", "loaded" or "error"
+	Ref     string `json:"ref"`
+	Digest  string `json:"digest,omitempty"`
+	Changed bool   `json:"changed,omitempty"` // the digest differs from the previous image
+	Layers  int    `json:"layers,omitempty"`
+	Reused  int    `json:"reused,omitempty"` // content layers not downloaded again
+	Error   string `json:"error,omitempty"`
+}
+
+var (
+	errNoLoader  = errors.New("reloading is not available")
+	errReloading = errors.New("a reload is already in progress")
+)
+
+// SetLoader sets how the image is (re)loaded.
+func (s *Server) SetLoader(load Loader) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loader = load
+}
+
+// Target returns the image the server shows, or is loading on first start.
+func (s *Server) Target() Target {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.target
+}
+
+// opened is an image that was shown, kept so going back to it is instant.
+type opened struct {
+	target Target
+	image  *image.Image
+	finder *referrers.Finder
+}
+
+// reload is one run of the loader.
+type reload struct {
+	load     Loader
+	target   Target
+	previous *image.Image
+}
+
+// startReload claims the single reload slot to load t.
+func (s *Server) startReload(t Target) (*reload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loader == nil {
+		return nil, errNoLoader
+	}
+	if s.reloading {
+		return nil, errReloading
+	}
+	s.reloading = true
+	return &reload{load: s.loader, target: t, previous: s.image}, nil
+}
+
+// Reload re-resolves the current target and swaps in the result, reusing
+// the layers it shares with the current image. A failed reload keeps the
+// current image.
+func (s *Server) Reload(ctx context.Context) error {
+	r, err := s.startReload(s.Target())
+	if err != nil {
+		return err
+	}
+	return s.runReload(ctx, r)
+}
+
+// startOpen claims the reload slot to switch to t. Going back to the
+// previously shown image swaps it in without loading, returning nil.
+func (s *Server) startOpen(t Target) (*reload, error) {
+	s.mu.Lock()
+	back := s.back
+	if back == nil || back.target != t || t == s.target || s.loader == nil || s.reloading {
+		s.mu.Unlock()
+		return s.startReload(t)
+	}
+	s.back = nil
+	if s.image != nil {
+		s.back = &opened{target: s.target, image: s.image, finder: s.finder}
+	}
+	s.target, s.image, s.finder, s.loadErr = back.target, back.image, back.finder, nil
+	s.mu.Unlock()
+
+	s.publish(Event{
+		Type:    "loaded",
+		Ref:     t.Ref,
+		Digest:  back.image.Info.Digest,
+		Changed: true,
+		Layers:  back.image.Info.LayerCount,
+	})
+	return nil, nil
+}
+
+func (s *Server) runReload(ctx context.Context, r *reload) error {
+	defer func() {
+		s.mu.Lock()
+		s.reloading = false
+		s.mu.Unlock()
+	}()
+
+	s.publish(Event{Type: "loading", Ref: r.target.Ref})
+	l, err := r.load(ctx, r.target, r.previous)
+	if err != nil {
+		s.mu.Lock()
+		if s.image == nil {
+			s.loadErr = err
+		}
+		s.mu.Unlock()
+		s.publish(Event{Type: "error", Ref: r.target.Ref, Error: err.Error()})
+		return err
+	}
+
+	img := l.Image
+	changed := r.previous == nil || r.previous.Info.Digest != img.Info.Digest
+	s.mu.Lock()
+	if r.target != s.target {
+		if s.image != nil {
+			s.back = &opened{target: s.target, image: s.image, finder: s.finder}
+		}
+		s.target = r.target
+		changed = true
+	}
+	if changed {
+		s.image = img
+		s.finder = l.Finder
+	}
+	s.loadErr = nil
+	s.mu.Unlock()
+	s.publish(Event{
+		Type:    "loaded",
+		Ref:     r.target.Ref,
+		Digest:  img.Info.Digest,
+		Changed: changed,
+		Layers:  img.Info.LayerCount,
+		Reused:  img.Reused,
+	})
+	return nil
+}
+
+// subscribe registers for events until the returned func is called.
+func (s *Server) subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 16)
+	s.mu.Lock()
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan Event]struct{})
+	}
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}
+}
+
+// publish sends ev to every subscriber, dropping it for any that have
+// fallen behind.
+func (s *Server) publish(ev Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
@@ -1,38 +1,143 @@
 package server
 
 import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"sync"
 
+	"github.com/coffee-cup/peel/internal/dockerfile"
 	"github.com/coffee-cup/peel/internal/embed"
 	"github.com/coffee-cup/peel/internal/image"
+	"github.com/coffee-cup/peel/internal/referrers"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 type Server struct {
 	mu      sync.RWMutex
 	image   *image.Image
-	ref     string
+	target  Target
 	loadErr error
+	source  *sourceDockerfile
+	finder  *referrers.Finder
+	key     crypto.PublicKey
+
+	// registries and platform are used to browse registries, unless
+	// offline forbids it
+	registries *image.Registries
+	platform   v1.Platform
+	offline    bool
+
+	// listenHost is the --host the server listens on, which requests may
+	// name besides loopback
+	listenHost string
+
+	// home is the image named on the command line and imageSource its
+	// --source, which images opened from the UI default to
+	home        Target
+	imageSource image.Source
+
+	// loader (re)loads images; reloading guards against overlapping runs.
+	// back is the image shown before the last one opened.
+	loader      Loader
+	reloading   bool
+	back        *opened
+	subscribers map[chan Event]struct{}
+
 	mux     *http.ServeMux
+	handler http.Handler
+}
+
+// sourceDockerfile is the Dockerfile the image was built from, when given.
+type sourceDockerfile struct {
+	path    string
+	content string
+	file    *dockerfile.File
 }
 
 func New(ref string) *Server {
-	s := &Server{ref: ref, mux: http.NewServeMux()}
+	s := &Server{target: Target{Ref: ref}, home: Target{Ref: ref}, imageSource: image.SourceAuto, mux: http.NewServeMux()}
 
 	s.mux.HandleFunc("GET /api/health", s.handleHealth)
 	s.mux.HandleFunc("GET /api/image", s.handleImage)
+	s.mux.HandleFunc("GET /api/config/raw", s.handleRawConfig)
+	s.mux.HandleFunc("GET /api/manifest/raw", s.handleRawManifest)
 	s.mux.HandleFunc("GET /api/layers", s.handleLayers)
 	s.mux.HandleFunc("GET /api/layers/{id}/tree", s.handleLayerTree)
+	s.mux.HandleFunc("GET /api/layers/{id}/dir", s.handleLayerDir)
+	s.mux.HandleFunc("GET /api/layers/{id}/dir/{path...}", s.handleLayerDir)
 	s.mux.HandleFunc("GET /api/layers/{id}/diff", s.handleLayerDiff)
 	s.mux.HandleFunc("GET /api/files/{layer}/{path...}", s.handleFileContent)
+	s.mux.HandleFunc("GET /api/blame/{path...}", s.handleBlame)
+	s.mux.HandleFunc("GET /api/history/{path...}", s.handleHistory)
+	s.mux.HandleFunc("GET /api/diff", s.handleDiffRange)
+	s.mux.HandleFunc("GET /api/compare", s.handleCompare)
+	s.mux.HandleFunc("GET /api/dockerfile", s.handleDockerfile)
+	s.mux.HandleFunc("GET /api/referrers", s.handleReferrers)
+	s.mux.HandleFunc("GET /api/referrers/{digest}", s.handleReferrer)
+	s.mux.HandleFunc("GET /api/verify", s.handleVerify)
+	s.mux.HandleFunc("GET /api/registry/{path...}", s.handleRegistry)
+	s.mux.HandleFunc("POST /api/reload", s.handleReload)
+	s.mux.HandleFunc("POST /api/open", s.handleOpen)
+	s.mux.HandleFunc("GET /api/events", s.handleEvents)
 
+	s.mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not found")
+	})
 	s.mux.Handle("/", embed.FileServer())
 
+	// Pages on other sites can't make the browser POST to a local peel
+	csrf := http.NewCrossOriginProtection()
+	csrf.SetDenyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusForbidden, "cross-origin request rejected")
+	}))
+	s.handler = csrf.Handler(s.mux)
 	return s
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.mux.ServeHTTP(w, r)
+	// A page that rebinds its own name to 127.0.0.1 is same-origin as far as
+	// the checks above can tell, but its requests still carry that name
+	if !s.allowedHost(r.Host) {
+		writeError(w, http.StatusForbidden, "unknown host "+r.Host)
+		return
+	}
+	s.handler.ServeHTTP(w, r)
+}
+
+// allowedHost reports whether a request's Host names this server: loopback,
+// the --host it listens on, or when that is every interface, any address.
+// Requests made in process have no Host.
+func (s *Server) allowedHost(hostport string) bool {
+	if hostport == "" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = strings.Trim(hostport, "[]")
+	}
+	host = strings.ToLower(host)
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return true
+	}
+
+	s.mu.RLock()
+	listen := s.listenHost
+	s.mu.RUnlock()
+	if listen == "" {
+		return false
+	}
+	if lip := net.ParseIP(listen); lip != nil {
+		return ip != nil && (lip.IsUnspecified() || lip.Equal(ip))
+	}
+	return host == strings.ToLower(listen)
 }
 
 func (s *Server) SetImage(img *image.Image) {
@@ -41,6 +146,66 @@ func (s *Server) SetImage(img *image.Image) {
 	s.image = img
 }
 
+// SetDockerfile parses the Dockerfile the image was built from so layers can
+// be correlated with the lines that produced them.
+func (s *Server) SetDockerfile(path string, content []byte) error {
+	f, err := dockerfile.Parse(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.source = &sourceDockerfile{path: path, content: string(content), file: f}
+	return nil
+}
+
+// SetReferrers enables lookups of signatures, attestations and SBOMs
+// attached to the image in its registry.
+func (s *Server) SetReferrers(f *referrers.Finder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finder = f
+}
+
+// SetVerifyKey sets the public key attached cosign signatures are checked against.
+func (s *Server) SetVerifyKey(pub crypto.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.key = pub
+}
+
+// SetRegistries sets how registries are reached when browsing them, and the
+// platform tags are described for.
+func (s *Server) SetRegistries(reg *image.Registries, platform v1.Platform) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registries = reg
+	s.platform = platform
+}
+
+// SetSource sets the command line's --source, which images opened from the
+// UI use unless they name another.
+func (s *Server) SetSource(src image.Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.imageSource = src
+}
+
+// SetListenHost sets the --host the server listens on, so requests may name
+// it as well as loopback.
+func (s *Server) SetListenHost(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listenHost = strings.Trim(host, "[]")
+}
+
+// SetOffline disables registry browsing, for --offline.
+func (s *Server) SetOffline() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offline = true
+}
+
 func (s *Server) SetError(err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -56,7 +221,7 @@ func (s *Server) requireImage(w http.ResponseWriter) *image.Image {
 	if s.loadErr != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{
 			"status": "error",
-			"ref":    s.ref,
+			"ref":    s.target.Ref,
 			"error":  s.loadErr.Error(),
 		})
 		return nil
@@ -64,7 +229,7 @@ func (s *Server) requireImage(w http.ResponseWriter) *image.Image {
 	if s.image == nil {
 		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
 			"status": "loading",
-			"ref":    s.ref,
+			"ref":    s.target.Ref,
 		})
 		return nil
 	}
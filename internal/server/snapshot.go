@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/coffee-cup/peel/internal/image"
+)
+
+// Snapshot is a set of API responses keyed by the URL the frontend requests
+// them with. Identical responses, such as a directory that doesn't change
+// between layers, are stored once.
+type Snapshot struct {
+	Responses map[string]int    `json:"responses"` // URL → index into Bodies
+	Bodies    []json.RawMessage `json:"bodies"`
+}
+
+// Snapshot renders what the UI reads for the current image: metadata, the
+// reconstructed Dockerfile, each layer's diff and every directory listing in
+// both tree views. File contents, blame and history are left out to keep it
+// small.
+func (s *Server) Snapshot(ctx context.Context) (*Snapshot, error) {
+	s.mu.RLock()
+	img := s.image
+	s.mu.RUnlock()
+	if img == nil {
+		return nil, errors.New("no image loaded")
+	}
+
+	snap := &Snapshot{Responses: make(map[string]int)}
+	seen := make(map[string]int)
+	get := func(path, query string) ([]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		key := path
+		if query != "" {
+			key += "?" + query
+		}
+		u := url.URL{Path: path, RawQuery: query}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		rec := &recorder{header: make(http.Header), status: http.StatusOK}
+		s.ServeHTTP(rec, req)
+		if rec.status != http.StatusOK {
+			return nil, fmt.Errorf("%s: %d %s", key, rec.status, strings.TrimSpace(rec.body.String()))
+		}
+		body := bytes.TrimSpace(rec.body.Bytes())
+		i, ok := seen[string(body)]
+		if !ok {
+			i = len(snap.Bodies)
+			seen[string(body)] = i
+			snap.Bodies = append(snap.Bodies, body)
+		}
+		snap.Responses[key] = i
+		return body, nil
+	}
+
+	for _, path := range []string{"/api/image", "/api/layers", "/api/dockerfile"} {
+		if _, err := get(path, ""); err != nil {
+			return nil, err
+		}
+	}
+	for i := range img.Trees {
+		if _, err := get(fmt.Sprintf("/api/layers/%d/diff", i), ""); err != nil {
+			return nil, err
+		}
+		views := []struct {
+			name string
+			tree *image.FileNode
+		}{{"cumulative", img.Trees[i]}, {"layer", img.LayerTrees[i]}}
+		for _, view := range views {
+			if view.tree == nil {
+				continue // nothing to list before the first layer with content
+			}
+			for dirs := []string{""}; len(dirs) > 0; {
+				dir := dirs[0]
+				dirs = dirs[1:]
+				body, err := get(fmt.Sprintf("/api/layers/%d/dir/%s", i, dir), "view="+view.name)
+				if err != nil {
+					return nil, err
+				}
+				var entries []image.DirEntry
+				if err := json.Unmarshal(body, &entries); err != nil {
+					return nil, err
+				}
+				for _, e := range entries {
+					if e.Type == image.FileTypeDir {
+						dirs = append(dirs, strings.TrimPrefix(e.Path, "/"))
+					}
+				}
+			}
+		}
+	}
+	return snap, nil
+}
+
+// recorder captures a response rendered in-process.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header         { return r.header }
+func (r *recorder) Write(b []byte) (int, error) { return r.body.Write(b) }
+func (r *recorder) WriteHeader(status int)      { r.status = status }
@@ -0,0 +1,292 @@
+// Package textdiff produces unified diffs between two texts.
+package textdiff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines shown around each change.
+const DefaultContext = 3
+
+// Op is the kind of a single diff line.
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Insert Op = '+'
+	Delete Op = '-'
+)
+
+// Line is one line of an edit script.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines splits s into lines, dropping the final empty line after a trailing newline.
+func Lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxCost bounds the edit distance Edits searches for. Texts further apart
+// are treated as rewritten, keeping time and memory bounded for large,
+// unrelated files.
+const maxCost = 1000
+
+// Edits returns a shortest edit script turning a into b, using Myers'
+// algorithm. Lines shared at the start and end are matched first; if the rest
+// needs more than maxCost edits it is replaced wholesale.
+func Edits(a, b []string) []Line {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	out := make([]Line, 0, len(a)+len(b)-prefix-suffix)
+	for _, l := range a[:prefix] {
+		out = append(out, Line{Equal, l})
+	}
+	am, bm := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if middle, ok := myers(am, bm); ok {
+		out = append(out, middle...)
+	} else {
+		for _, l := range am {
+			out = append(out, Line{Delete, l})
+		}
+		for _, l := range bm {
+			out = append(out, Line{Insert, l})
+		}
+	}
+	for _, l := range a[len(a)-suffix:] {
+		out = append(out, Line{Equal, l})
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// myers returns a shortest edit script turning a into b, or false if it takes
+// more than maxCost edits.
+func myers(a, b []string) ([]Line, bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil, true
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds the diagonals -d-1..d+1 of V before step d, all that
+	// backtracking reads, so memory grows with the edit distance only
+	var trace [][]int
+
+	for d := 0; d <= min(max, maxCost); d++ {
+		trace = append(trace, slices.Clone(v[offset-d-1:offset+d+2]))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // step down: insertion
+			} else {
+				x = v[offset+k-1] + 1 // step right: deletion
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrack walks the saved V arrays from the end to recover the edit script.
+func backtrack(trace [][]int, a, b []string) []Line {
+	x, y := len(a), len(b)
+	var rev []Line
+	for d := len(trace) - 1; d >= 0; d-- {
+		v, offset := trace[d], d+1
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, Line{Equal, a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				rev = append(rev, Line{Insert, b[y]})
+			} else {
+				x--
+				rev = append(rev, Line{Delete, a[x]})
+			}
+		}
+	}
+	out := make([]Line, len(rev))
+	for i, l := range rev {
+		out[len(rev)-1-i] = l
+	}
+	return out
+}
+
+// noNewline ends the last line of a text without a trailing newline. Lines
+// never contain "\n", so a marked line only matches another marked line.
+const noNewline = "\n\\ No newline at end of file"
+
+// Unified returns a unified diff of a and b with the given number of context
+// lines. Returns "" if the texts are identical.
+func Unified(fromName, toName, a, b string, context int) string {
+	edits := Edits(markedLines(a), markedLines(b))
+	hunks := hunks(edits, context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.aStart, h.aLen), hunkRange(h.bStart, h.bLen))
+		for _, l := range h.lines {
+			sb.WriteByte(byte(l.Op))
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// markedLines is Lines with noNewline appended to a final line that has no
+// newline, so the marker is printed after it and a missing newline is a change.
+func markedLines(s string) []string {
+	lines := Lines(s)
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		lines[len(lines)-1] += noNewline
+	}
+	return lines
+}
+
+type hunk struct {
+	aStart, aLen int
+	bStart, bLen int
+	lines        []Line
+}
+
+// hunks groups an edit script into hunks separated by more than 2*context equal lines.
+func hunks(edits []Line, context int) []hunk {
+	var out []hunk
+	i := 0
+	aLine, bLine := 0, 0 // lines consumed before edits[i]
+	for i < len(edits) {
+		// Skip to the next change
+		for i < len(edits) && edits[i].Op == Equal {
+			i++
+			aLine++
+			bLine++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		lead := min(context, i)
+		start := i - lead
+		h := hunk{aStart: aLine - lead, bStart: bLine - lead}
+		aLine -= lead
+		bLine -= lead
+
+		// Extend until a run of equal lines longer than 2*context (or the end)
+		end := start
+		for end < len(edits) {
+			if edits[end].Op != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].Op == Equal {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end = min(end+context, run)
+				break
+			}
+			end = run
+		}
+
+		h.lines = edits[start:end]
+		for _, l := range h.lines {
+			if l.Op != Insert {
+				h.aLen++
+				aLine++
+			}
+			if l.Op != Delete {
+				h.bLen++
+				bLine++
+			}
+		}
+		out = append(out, h)
+		i = end
+	}
+	return out
+}
+
+// hunkRange formats a hunk header range; start is zero-based.
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// HexDump formats data as 16-byte rows of offset, hex bytes and printable
+// ASCII, so binary content can be diffed line by line.
+func HexDump(data []byte) string {
+	var sb strings.Builder
+	for off := 0; off < len(data); off += 16 {
+		row := data[off:min(off+16, len(data))]
+		fmt.Fprintf(&sb, "%08x ", off)
+		for i := 0; i < 16; i++ {
+			if i < len(row) {
+				fmt.Fprintf(&sb, " %02x", row[i])
+			} else {
+				sb.WriteString("   ")
+			}
+		}
+		sb.WriteString("  |")
+		for _, b := range row {
+			if b >= 0x20 && b <= 0x7e {
+				sb.WriteByte(b)
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteString("|\n")
+	}
+	return sb.String()
+}
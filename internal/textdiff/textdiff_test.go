@@ -0,0 +1,126 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnified_Identical(t *testing.T) {
+	if got := Unified("a", "b", "x\ny\n", "x\ny\n", DefaultContext); got != "" {
+		t.Fatalf("expected empty diff, got %q", got)
+	}
+}
+
+func TestUnified_SingleChange(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	b := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n"
+	want := `--- a
++++ b
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`
+	if got := Unified("a", "b", a, b, DefaultContext); got != want {
+		t.Fatalf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnified_SeparateHunks(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 20; i++ {
+		line := strings.Repeat("x", i+1)
+		a = append(a, line)
+		b = append(b, line)
+	}
+	b[1] = "changed-early"
+	b[18] = "changed-late"
+	got := Unified("a", "b", strings.Join(a, "\n")+"\n", strings.Join(b, "\n")+"\n", 1)
+	if n := strings.Count(got, "@@ -"); n != 2 {
+		t.Fatalf("expected 2 hunks, got %d:\n%s", n, got)
+	}
+	if !strings.Contains(got, "@@ -1,3 +1,3 @@") || !strings.Contains(got, "@@ -18,3 +18,3 @@") {
+		t.Fatalf("unexpected hunk headers:\n%s", got)
+	}
+}
+
+func TestUnified_AddedFromEmpty(t *testing.T) {
+	want := "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+one\n+two\n"
+	if got := Unified("a", "b", "", "one\ntwo\n", DefaultContext); got != want {
+		t.Fatalf("unexpected diff:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestEdits_Minimal(t *testing.T) {
+	edits := Edits(Lines("a\nb\nc\n"), Lines("a\nc\nd\n"))
+	var ops strings.Builder
+	for _, e := range edits {
+		ops.WriteByte(byte(e.Op))
+	}
+	if got := ops.String(); got != " - +" {
+		t.Fatalf("expected \" - +\", got %q", got)
+	}
+}
+
+func TestEdits_SharedEnds(t *testing.T) {
+	edits := Edits(Lines("a\nb\nx\ny\nc\nd\n"), Lines("a\nb\nz\nc\nd\n"))
+	var ops strings.Builder
+	for _, e := range edits {
+		ops.WriteByte(byte(e.Op))
+	}
+	if got := ops.String(); got != "  --+  " {
+		t.Fatalf("expected \"  --+  \", got %q", got)
+	}
+}
+
+func TestEdits_LargeRewrite(t *testing.T) {
+	// Every line differs, far past maxCost: the whole text is replaced
+	// rather than searched for a shortest script
+	const n = 200_000
+	a := make([]string, n)
+	b := make([]string, n)
+	for i := range n {
+		a[i] = fmt.Sprintf("a%d", i)
+		b[i] = fmt.Sprintf("b%d", i)
+	}
+	edits := Edits(a, b)
+	if len(edits) != 2*n {
+		t.Fatalf("expected %d edits, got %d", 2*n, len(edits))
+	}
+	if edits[0] != (Line{Delete, "a0"}) || edits[n-1] != (Line{Delete, a[n-1]}) || edits[n] != (Line{Insert, "b0"}) {
+		t.Fatalf("expected every line deleted then inserted, got %v %v %v", edits[0], edits[n-1], edits[n])
+	}
+
+	got := Unified("a", "b", strings.Join(a, "\n")+"\n", strings.Join(b, "\n")+"\n", DefaultContext)
+	if h := strings.Count(got, "@@ -"); h != 1 || !strings.Contains(got, "@@ -1,200000 +1,200000 @@") {
+		t.Fatalf("expected one whole-file hunk, got %d: %.200s", h, got)
+	}
+}
+
+func TestUnified_NoNewlineAtEnd(t *testing.T) {
+	want := "--- a\n+++ b\n@@ -1,2 +1,2 @@\n x\n-y\n+y\n\\ No newline at end of file\n"
+	if got := Unified("a", "b", "x\ny\n", "x\ny", DefaultContext); got != want {
+		t.Fatalf("unexpected diff:\n%q\nwant:\n%q", got, want)
+	}
+
+	// Both without a newline: the marker follows the context line
+	want = "--- a\n+++ b\n@@ -1,2 +1,2 @@\n-x\n+w\n y\n\\ No newline at end of file\n"
+	if got := Unified("a", "b", "x\ny", "w\ny", DefaultContext); got != want {
+		t.Fatalf("unexpected diff:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestHexDump(t *testing.T) {
+	got := HexDump([]byte("hi\x00there, binary!"))
+	want := "00000000  68 69 00 74 68 65 72 65 2c 20 62 69 6e 61 72 79  |hi.there, binary|\n" +
+		"00000010  21                                               |!|\n"
+	if got != want {
+		t.Fatalf("unexpected dump:\n%q\nwant:\n%q", got, want)
+	}
+}
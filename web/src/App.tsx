@@ -1,13 +1,24 @@
-import { useState, useCallback, useEffect, useRef, useSyncExternalStore } from "react";
+import { useState, useCallback, useEffect, useMemo, useRef, useSyncExternalStore } from "react";
 import { Panel, Group, Separator } from "react-resizable-panels";
 import { useImage } from "./hooks/useImage";
 import { useLayerData } from "./hooks/useLayerData";
 import { useFileContent } from "./hooks/useFileContent";
 import { useKeyboardNav } from "./hooks/useKeyboardNav";
+import { useDockerfile } from "./hooks/useDockerfile";
+import { useReload } from "./hooks/useReload";
+import { useURLState, parseURL, type Selection } from "./hooks/useURLState";
 import { LayerList } from "./components/LayerList";
 import { FileTree, type FileTreeHandle } from "./components/FileTree";
 import { FileViewer } from "./components/FileViewer";
 import { MetadataPanel } from "./components/MetadataPanel";
+import { DockerfileView } from "./components/DockerfileView";
+import { ReferrersView } from "./components/ReferrersView";
+import { SignatureBadge } from "./components/SignatureBadge";
+import { RegistryBrowser } from "./components/RegistryBrowser";
+import { OpenImageDialog } from "./components/OpenImageDialog";
+import type { TreeView, TreeSort } from "./types";
+import { parentDirs } from "./utils";
+import { isReport } from "./api";
 
 function useMediaQuery(query: string): boolean {
   const subscribe = useCallback(
@@ -24,16 +35,46 @@ function useMediaQuery(query: string): boolean {
 
 function App() {
   const { image, layers, loading: imageLoading, error: imageError } = useImage();
-  const [selectedLayer, setSelectedLayer] = useState<number | null>(null);
-  const [selectedFile, setSelectedFile] = useState<string | null>(null);
-  const [changesOnly, setChangesOnly] = useState(false);
+  // A shared link restores its selection
+  const [initial] = useState(() => parseURL());
+  const [selectedLayer, setSelectedLayer] = useState<number | null>(initial.layer);
+  const [selectedFile, setSelectedFile] = useState<string | null>(initial.file);
+  const [selectedLine, setSelectedLine] = useState<number | null>(initial.line);
+  const [changesOnly, setChangesOnly] = useState(initial.changesOnly);
+  const [treeView, setTreeView] = useState<TreeView>("cumulative");
+  const [treeSort, setTreeSort] = useState<TreeSort>("name");
 
-  const { tree, diff, loading: layerLoading } = useLayerData(selectedLayer);
-  const { file, loading: fileLoading } = useFileContent(selectedLayer, selectedFile);
+  const [rangeStart, setRangeStart] = useState<number | null>(initial.rangeStart);
+  // What the viewer panel shows instead of the selected file
+  const [overlay, setOverlay] = useState<"dockerfile" | "referrers" | "registry" | null>(null);
+
+  const { diff, loading: layerLoading } = useLayerData(selectedLayer, rangeStart);
+  const { file, loading: fileLoading, error: fileError } = useFileContent(selectedLayer, selectedFile);
+  const { dockerfile } = useDockerfile();
+  const reload = useReload();
+  const [openDialog, setOpenDialog] = useState(false);
 
   const fileTreeRef = useRef<FileTreeHandle>(null);
   const expandedCache = useRef<Map<number, Set<string>>>(new Map());
-  const lastExpanded = useRef<Set<string> | undefined>(undefined);
+  const lastExpanded = useRef<Set<string> | undefined>(initial.file ? parentDirs(initial.file) : undefined);
+
+  const selection = useMemo<Selection>(
+    () => ({ layer: selectedLayer, rangeStart, file: selectedFile, line: selectedLine, changesOnly }),
+    [selectedLayer, rangeStart, selectedFile, selectedLine, changesOnly],
+  );
+  // Selections belong to the image they were made in, so this also resets
+  // what was expanded
+  const applySelection = useCallback((sel: Selection) => {
+    setSelectedLayer(sel.layer);
+    setRangeStart(sel.rangeStart);
+    setSelectedFile(sel.file);
+    setSelectedLine(sel.line);
+    setChangesOnly(sel.changesOnly);
+    setOverlay(null);
+    expandedCache.current.clear();
+    lastExpanded.current = sel.file ? parentDirs(sel.file) : undefined;
+  }, []);
+  useURLState(image?.ref ?? null, selection, applySelection, reload.open, reload.error);
   const onTreeShiftTab = useCallback(() => {
     fileTreeRef.current?.toggleAllFolders();
   }, []);
@@ -54,13 +95,32 @@ function App() {
 
   const isWide = useMediaQuery("(min-width: 1024px)");
 
-  const handleLayerSelect = useCallback((index: number) => {
+  const handleLayerSelect = useCallback(
+    (index: number, extend = false) => {
+      // Shift-click selects a range; the tree shows the top of it and the diff spans all of it
+      if (extend && selectedLayer !== null && index !== selectedLayer) {
+        const anchor = rangeStart ?? selectedLayer;
+        setRangeStart(Math.min(anchor, index));
+        setSelectedLayer(Math.max(anchor, index));
+      } else {
+        setRangeStart(null);
+        setSelectedLayer(index);
+      }
+      setSelectedFile(null);
+      setSelectedLine(null);
+    },
+    [selectedLayer, rangeStart],
+  );
+
+  // Jump to another layer while keeping the current file open
+  const handleJumpToLayer = useCallback((index: number) => {
     setSelectedLayer(index);
-    setSelectedFile(null);
   }, []);
 
   const handleSelectFile = useCallback((path: string) => {
     setSelectedFile(path);
+    setSelectedLine(null);
+    setOverlay(null);
   }, []);
 
   if (imageLoading) {
@@ -77,12 +137,34 @@ function App() {
         <div className="max-w-md text-center space-y-2">
           <div className="text-sm text-red-400">Failed to load image</div>
           <div className="text-xs text-stone-500 font-mono break-all">{imageError.message}</div>
+          <button
+            type="button"
+            className="text-[11px] px-2 py-0.5 rounded font-medium text-stone-500 hover:text-stone-300 hover:bg-stone-800 outline-none disabled:opacity-50"
+            disabled={reload.reloading}
+            onClick={reload.reload}
+          >
+            {reload.reloading ? "Retrying…" : "Retry"}
+          </button>
+          <button
+            type="button"
+            className="text-[11px] px-2 py-0.5 rounded font-medium text-stone-500 hover:text-stone-300 hover:bg-stone-800 outline-none"
+            onClick={() => setOpenDialog(true)}
+          >
+            Open another image
+          </button>
         </div>
+        {openDialog && <OpenImageDialog currentRef={null} onOpen={reload.open} onClose={() => setOpenDialog(false)} />}
       </div>
     );
   }
 
-  if (selectedLayer === null && layers.length > 0) {
+  // A reload can bring in an image with fewer layers
+  if (selectedLayer !== null && layers.length > 0 && selectedLayer >= layers.length) {
+    setRangeStart(null);
+    setSelectedLayer(null);
+    setSelectedFile(null);
+    setSelectedLine(null);
+  } else if (selectedLayer === null && layers.length > 0) {
     const first = layers.find((l) => !l.empty) ?? layers[0];
     setSelectedLayer(first.index);
   }
@@ -99,6 +181,81 @@ function App() {
             {image.ref}
           </span>
         )}
+        <SignatureBadge onClick={() => setOverlay("referrers")} />
+        {isReport && (
+          <span className="text-[11px] px-2 py-0.5 rounded bg-stone-800 text-stone-400" title="A static report: file contents and registry lookups need peel running">
+            report
+          </span>
+        )}
+        <div className="ml-auto flex items-center gap-2">
+          {rangeStart !== null && (
+            <button
+              type="button"
+              className="text-[11px] px-2 py-0.5 rounded bg-accent/20 text-accent outline-none"
+              title="Clear layer range"
+              onClick={() => setRangeStart(null)}
+            >
+              layers {rangeStart}–{selectedLayer} ×
+            </button>
+          )}
+          {!isReport && (
+            <>
+            {reload.error && (
+              <span className="text-[11px] text-red-400 truncate max-w-xs" title={reload.error}>
+                load failed
+              </span>
+            )}
+            <button
+              type="button"
+              className="text-[11px] px-2 py-0.5 rounded font-medium transition-colors outline-none text-stone-500 hover:text-stone-300 hover:bg-stone-800 disabled:opacity-50"
+              title="Open another image in place of this one"
+              disabled={reload.reloading}
+              onClick={() => setOpenDialog(true)}
+            >
+              Open
+            </button>
+            <button
+              type="button"
+              className="text-[11px] px-2 py-0.5 rounded font-medium transition-colors outline-none text-stone-500 hover:text-stone-300 hover:bg-stone-800 disabled:opacity-50"
+              title={reload.error ?? "Load the image again, reusing unchanged layers"}
+              disabled={reload.reloading}
+              onClick={reload.reload}
+            >
+              {reload.reloading ? "Loading…" : "Reload"}
+            </button>
+            <button
+              type="button"
+              className={`text-[11px] px-2 py-0.5 rounded font-medium transition-colors outline-none ${
+                overlay === "registry" ? "bg-accent/20 text-accent" : "text-stone-500 hover:text-stone-300 hover:bg-stone-800"
+              }`}
+              title="Browse repositories and tags in the registry"
+              onClick={() => setOverlay((v) => (v === "registry" ? null : "registry"))}
+            >
+              Browse
+            </button>
+            <button
+              type="button"
+              className={`text-[11px] px-2 py-0.5 rounded font-medium transition-colors outline-none ${
+                overlay === "referrers" ? "bg-accent/20 text-accent" : "text-stone-500 hover:text-stone-300 hover:bg-stone-800"
+              }`}
+              title="Signatures, attestations and SBOMs attached in the registry"
+              onClick={() => setOverlay((v) => (v === "referrers" ? null : "referrers"))}
+            >
+              Attached
+            </button>
+            </>
+          )}
+          <button
+            type="button"
+            className={`text-[11px] px-2 py-0.5 rounded font-medium transition-colors outline-none ${
+              overlay === "dockerfile" ? "bg-accent/20 text-accent" : "text-stone-500 hover:text-stone-300 hover:bg-stone-800"
+            }`}
+            title="Dockerfile reconstructed from the image history"
+            onClick={() => setOverlay((v) => (v === "dockerfile" ? null : "dockerfile"))}
+          >
+            Dockerfile
+          </button>
+        </div>
       </header>
 
       <div className="flex-1 min-h-0 p-0.5">
@@ -114,7 +271,13 @@ function App() {
               <LayerList
                 layers={layers}
                 selected={selectedLayer}
+                rangeStart={rangeStart}
                 onSelect={handleLayerSelect}
+                alignment={dockerfile?.source?.alignment}
+                onShowSource={(index) => {
+                  handleLayerSelect(index);
+                  setOverlay("dockerfile");
+                }}
               />
             </div>
             <div className="border-t border-border overflow-auto p-3">
@@ -135,9 +298,9 @@ function App() {
                 className={`h-full overflow-hidden outline-none ${activePanel === "tree" ? borderActive : borderInactive}`}
               >
                 <FileTree
-                  key={selectedLayer ?? undefined}
+                  key={`${rangeStart}-${selectedLayer}-${treeView}`}
                   ref={fileTreeRef}
-                  tree={tree}
+                  layer={selectedLayer}
                   diff={diff}
                   selectedFile={selectedFile}
                   onSelectFile={handleSelectFile}
@@ -146,6 +309,10 @@ function App() {
                   onExpandedChange={handleExpandedChange}
                   changesOnly={changesOnly}
                   onChangesOnlyChange={setChangesOnly}
+                  view={treeView}
+                  onViewChange={setTreeView}
+                  sortBy={treeSort}
+                  onSortByChange={setTreeSort}
                 />
               </div>
             </Panel>
@@ -164,13 +331,34 @@ function App() {
                 tabIndex={-1}
                 className={`h-full overflow-hidden outline-none ${activePanel === "viewer" ? borderActive : borderInactive}`}
               >
-                <FileViewer file={file} loading={fileLoading} />
+                {overlay === "dockerfile" ? (
+                  <DockerfileView
+                    selectedLayer={selectedLayer}
+                    onSelectLayer={handleLayerSelect}
+                    onClose={() => setOverlay(null)}
+                  />
+                ) : overlay === "referrers" ? (
+                  <ReferrersView onClose={() => setOverlay(null)} />
+                ) : overlay === "registry" && image ? (
+                  <RegistryBrowser currentRef={image.ref} onOpen={(ref) => reload.open({ ref })} onClose={() => setOverlay(null)} />
+                ) : (
+                  <FileViewer
+                    file={file}
+                    loading={fileLoading}
+                    error={fileError}
+                    selectedLayer={selectedLayer}
+                    onSelectLayer={handleJumpToLayer}
+                    line={selectedLine}
+                    onSelectLine={setSelectedLine}
+                  />
+                )}
               </div>
             </Panel>
           </Group>
         </Panel>
       </Group>
       </div>
+      {openDialog && <OpenImageDialog currentRef={image?.ref ?? null} onOpen={reload.open} onClose={() => setOpenDialog(false)} />}
     </div>
   );
 }
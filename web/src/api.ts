@@ -1,4 +1,4 @@
-import type { ImageInfo, LayerInfo, FileNode, DiffEntry, FileContent } from "./types";
+import type { ImageInfo, LayerInfo, FileNode, DirEntry, DiffEntry, FileContent, FileDiff, FileVersion, PathChange, TreeView, DockerfileInfo, Artifact, ArtifactDetail, Verification, CatalogPage, TagPage, OpenTarget } from "./types";
 
 export class LoadingError extends Error {
   ref: string;
@@ -9,7 +9,21 @@ export class LoadingError extends Error {
   }
 }
 
+/** API responses embedded by `peel report`, or null when served by peel. */
+const report: { responses: Record<string, number>; bodies: unknown[] } | null = (() => {
+  const el = typeof document === "undefined" ? null : document.getElementById("peel-report");
+  return el?.textContent ? JSON.parse(el.textContent) : null;
+})();
+
+/** The app was opened from a static report, so only embedded responses are available. */
+export const isReport = report !== null;
+
 async function fetchJSON<T>(url: string): Promise<T> {
+  if (report) {
+    const i = report.responses[url];
+    if (i === undefined) throw new Error("Not included in this report");
+    return report.bodies[i] as T;
+  }
   const res = await fetch(url);
   if (!res.ok) {
     const body = await res.json().catch(() => null);
@@ -21,11 +35,41 @@ async function fetchJSON<T>(url: string): Promise<T> {
   return res.json();
 }
 
+async function postJSON<T>(url: string, body?: unknown): Promise<T> {
+  if (report) throw new Error("Not available in a report");
+  const res = await fetch(url, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  const data = await res.json().catch(() => null);
+  if (!res.ok) throw new Error(data?.error ?? `${res.status} ${res.statusText}`);
+  return data;
+}
+
 export const api = {
   image: () => fetchJSON<ImageInfo>("/api/image"),
   layers: () => fetchJSON<LayerInfo[]>("/api/layers"),
-  layerTree: (id: number) => fetchJSON<FileNode>(`/api/layers/${id}/tree`),
+  layerTree: (id: number, view: TreeView = "cumulative") =>
+    fetchJSON<FileNode>(`/api/layers/${id}/tree?view=${view}`),
+  layerDir: (id: number, path: string, view: TreeView = "cumulative") =>
+    fetchJSON<DirEntry[]>(`/api/layers/${id}/dir/${path.replace(/^\//, "")}?view=${view}`),
   layerDiff: (id: number) => fetchJSON<DiffEntry[]>(`/api/layers/${id}/diff`),
+  diffRange: (from: number, to: number) => fetchJSON<DiffEntry[]>(`/api/diff?from=${from}&to=${to}`),
   fileContent: (layer: number, path: string) =>
     fetchJSON<FileContent>(`/api/files/${layer}/${path.replace(/^\//, "")}`),
+  blame: (path: string) => fetchJSON<PathChange[]>(`/api/blame/${path.replace(/^\//, "")}`),
+  compare: (from: string, to: string) =>
+    fetchJSON<FileDiff>(`/api/compare?from=${encodeURIComponent(from)}&to=${encodeURIComponent(to)}`),
+  dockerfile: () => fetchJSON<DockerfileInfo>("/api/dockerfile"),
+  referrers: () => fetchJSON<Artifact[]>("/api/referrers"),
+  referrer: (digest: string) => fetchJSON<ArtifactDetail>(`/api/referrers/${digest}`),
+  verify: () => fetchJSON<Verification[]>("/api/verify"),
+  catalog: (host: string, last = "") =>
+    fetchJSON<CatalogPage>(`/api/registry/${host}/catalog?last=${encodeURIComponent(last)}`),
+  tags: (repository: string, last = "") =>
+    fetchJSON<TagPage>(`/api/registry/${repository}/tags?last=${encodeURIComponent(last)}`),
+  history: (path: string) => fetchJSON<FileVersion[]>(`/api/history/${path.replace(/^\//, "")}`),
+  reload: () => postJSON<{ status: string; ref: string }>("/api/reload"),
+  open: (target: OpenTarget) => postJSON<{ status: string; ref: string }>("/api/open", target),
 };
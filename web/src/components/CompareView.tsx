@@ -0,0 +1,66 @@
+import { useState } from "react";
+import { useQuery } from "@tanstack/react-query";
+import { api } from "../api";
+import type { FileDiff } from "../types";
+import { DiffView } from "./DiffView";
+
+interface CompareViewProps {
+  path: string;
+  layer: number;
+}
+
+/** Diff the open file against any other path at any layer. */
+export function CompareView({ path, layer }: CompareViewProps) {
+  const [otherLayer, setOtherLayer] = useState(Math.max(0, layer - 1));
+  const [otherPath, setOtherPath] = useState(path);
+  const from = `${otherLayer}:${otherPath}`;
+  const to = `${layer}:${path}`;
+
+  const { data, isPending, error } = useQuery<FileDiff>({
+    queryKey: ["compare", from, to],
+    queryFn: () => api.compare(from, to),
+    enabled: otherPath !== "",
+  });
+
+  return (
+    <div className="flex flex-col">
+      <form
+        className="flex items-center gap-2 px-3 py-2 border-b border-border text-xs"
+        onSubmit={(e) => e.preventDefault()}
+      >
+        <span className="text-stone-500 shrink-0">against layer</span>
+        <input
+          type="number"
+          min={0}
+          value={otherLayer}
+          onChange={(e) => setOtherLayer(Math.max(0, Number(e.target.value)))}
+          className="w-14 px-1.5 py-0.5 rounded bg-stone-800 border border-border font-mono text-stone-200 outline-none focus:border-accent/50"
+        />
+        <input
+          type="text"
+          value={otherPath}
+          onChange={(e) => setOtherPath(e.target.value)}
+          spellCheck={false}
+          className="flex-1 min-w-0 px-1.5 py-0.5 rounded bg-stone-800 border border-border font-mono text-stone-200 outline-none focus:border-accent/50"
+        />
+      </form>
+      {isPending ? (
+        <div className="flex items-center justify-center h-32 text-stone-500 text-sm">Loading…</div>
+      ) : error ? (
+        <div className="p-3 text-xs text-red-400 font-mono">{error.message}</div>
+      ) : data.diff === "" ? (
+        <div className="p-3 text-xs text-stone-500">Files are identical</div>
+      ) : (
+        <>
+          {(data.isBinary || data.truncated) && (
+            <div className="px-3 py-1.5 text-[11px] text-stone-500">
+              {data.isBinary && "Binary content, compared as hex dumps. "}
+              {data.truncated && "Content truncated; only the leading bytes are compared."}
+            </div>
+          )}
+          <DiffView diff={data.diff} path={data.isBinary ? undefined : path} />
+        </>
+      )}
+    </div>
+  );
+}
@@ -0,0 +1,89 @@
+import { useEffect, useState } from "react";
+import type { ThemedToken } from "shiki";
+import { detectLanguage } from "../lang";
+import { getHighlighter } from "../highlight";
+
+const lineStyles: Record<string, string> = {
+  "+": "bg-change-added/10",
+  "-": "bg-change-deleted/10",
+};
+
+const markerStyles: Record<string, string> = {
+  "+": "text-change-added",
+  "-": "text-change-deleted",
+};
+
+// Headers, hunk ranges and "\ No newline at end of file" markers are not file content
+function isBodyLine(line: string): boolean {
+  return !line.startsWith("---") && !line.startsWith("+++") && !line.startsWith("@@") && !line.startsWith("\\");
+}
+
+/**
+ * Renders a unified diff with added/removed lines tinted. When `path` has a
+ * known language, line contents are syntax highlighted.
+ */
+export function DiffView({ diff, path }: { diff: string; path?: string }) {
+  const lines = diff.replace(/\n$/, "").split("\n");
+  const tokens = useBodyTokens(lines, path);
+
+  let body = 0;
+  return (
+    <pre className="text-xs leading-relaxed font-mono overflow-x-auto">
+      {lines.map((line, i) => {
+        if (!isBodyLine(line)) {
+          const style = line.startsWith("@@") ? "text-accent/70" : "text-stone-500";
+          return (
+            <div key={i} className={`px-3 whitespace-pre ${style}`}>
+              {line}
+            </div>
+          );
+        }
+        const marker = line[0] ?? " ";
+        const lineTokens = tokens?.[body++];
+        return (
+          <div key={i} className={`px-3 whitespace-pre ${lineStyles[marker] ?? ""}`}>
+            <span className={`select-none ${markerStyles[marker] ?? "text-stone-600"}`}>{marker}</span>
+            {lineTokens ? (
+              lineTokens.map((t, j) => (
+                <span key={j} style={{ color: t.color }}>
+                  {t.content}
+                </span>
+              ))
+            ) : (
+              <span className={markerStyles[marker] ?? "text-stone-300"}>{line.slice(1)}</span>
+            )}
+          </div>
+        );
+      })}
+    </pre>
+  );
+}
+
+/** Highlight the content of each diff body line, in order. Null until ready or if no language matches. */
+function useBodyTokens(lines: string[], path?: string): ThemedToken[][] | null {
+  const [tokens, setTokens] = useState<ThemedToken[][] | null>(null);
+  const lang = path ? detectLanguage(path) : null;
+  const code = lines
+    .filter(isBodyLine)
+    .map((l) => l.slice(1))
+    .join("\n");
+
+  useEffect(() => {
+    setTokens(null);
+    if (!lang) return;
+    let cancelled = false;
+    getHighlighter().then((hl) => {
+      if (cancelled) return;
+      try {
+        setTokens(hl.codeToTokens(code, { lang, theme: "rose-pine" }).tokens);
+      } catch {
+        setTokens(null);
+      }
+    });
+    return () => {
+      cancelled = true;
+    };
+  }, [code, lang]);
+
+  return tokens;
+}
@@ -0,0 +1,250 @@
+import { useEffect, useMemo, useRef, useState } from "react";
+import type { ThemedToken } from "shiki";
+import { getHighlighter } from "../highlight";
+import { useDockerfile } from "../hooks/useDockerfile";
+import type { DockerfileAlignment, DockerfileInstruction, DockerfileMatch } from "../types";
+import { SyntaxView } from "./FileViewer";
+
+type DockerfileTab = "source" | "reconstructed";
+
+interface DockerfileViewProps {
+  selectedLayer: number | null;
+  onSelectLayer: (index: number) => void;
+  onClose: () => void;
+}
+
+/**
+ * The Dockerfile passed with --dockerfile, with each line linked to the layer
+ * it produced, or one reconstructed from history when none was given.
+ */
+export function DockerfileView({ selectedLayer, onSelectLayer, onClose }: DockerfileViewProps) {
+  const { dockerfile, loading, error } = useDockerfile();
+  const [tab, setTab] = useState<DockerfileTab>("source");
+  const source = dockerfile?.source;
+  const active = source ? tab : "reconstructed";
+
+  return (
+    <div className="flex flex-col h-full overflow-hidden">
+      <div className="flex items-center gap-3 px-3 h-8 border-b border-border shrink-0">
+        <span className="font-mono text-xs text-stone-200 truncate">{source?.path ?? "Dockerfile"}</span>
+        {active === "reconstructed" && <span className="text-xs text-stone-500 shrink-0">reconstructed from history</span>}
+        <div className="ml-auto flex items-center gap-1 shrink-0">
+          {source &&
+            (["source", "reconstructed"] as const).map((t) => (
+              <button
+                key={t}
+                type="button"
+                className={`px-2 py-0.5 rounded text-[11px] font-medium transition-colors outline-none ${
+                  tab === t ? "bg-accent/20 text-accent" : "text-stone-500 hover:text-stone-300 hover:bg-stone-800"
+                }`}
+                onClick={() => setTab(t)}
+              >
+                {t === "source" ? "Source" : "Reconstructed"}
+              </button>
+            ))}
+          <button
+            type="button"
+            className="px-2 py-0.5 rounded text-[11px] font-medium text-stone-500 hover:text-stone-300 hover:bg-stone-800 outline-none"
+            onClick={onClose}
+          >
+            Close
+          </button>
+        </div>
+      </div>
+      <div className="flex-1 overflow-auto">
+        {loading ? (
+          <div className="flex items-center justify-center h-32 text-stone-500 text-sm">Loading…</div>
+        ) : error || !dockerfile ? (
+          <div className="p-3 text-xs text-red-400 font-mono">{error}</div>
+        ) : source && active === "source" ? (
+          <SourceView
+            content={source.content}
+            alignment={source.alignment}
+            selectedLayer={selectedLayer}
+            onSelectLayer={onSelectLayer}
+          />
+        ) : (
+          <SyntaxView path="Dockerfile" content={dockerfile.content} />
+        )}
+      </div>
+    </div>
+  );
+}
+
+interface LineInfo {
+  match?: DockerfileMatch;
+  unmatched?: DockerfileInstruction;
+  first: boolean;
+}
+
+function SourceView({
+  content,
+  alignment,
+  selectedLayer,
+  onSelectLayer,
+}: {
+  content: string;
+  alignment: DockerfileAlignment;
+  selectedLayer: number | null;
+  onSelectLayer: (index: number) => void;
+}) {
+  const lines = useMemo(() => content.replace(/\n$/, "").split("\n"), [content]);
+  const tokens = useDockerfileTokens(content);
+  const containerRef = useRef<HTMLPreElement>(null);
+
+  const info = useMemo(() => {
+    const m = new Map<number, LineInfo>();
+    for (const match of alignment.matches) {
+      for (let l = match.line; l <= match.endLine; l++) m.set(l, { match, first: l === match.line });
+    }
+    for (const inst of alignment.unmatched) {
+      for (let l = inst.line; l <= inst.endLine; l++) m.set(l, { unmatched: inst, first: l === inst.line });
+    }
+    return m;
+  }, [alignment]);
+
+  const selected = alignment.matches.find((m) => m.layer === selectedLayer);
+
+  // Jump to the instruction that produced the selected layer
+  useEffect(() => {
+    if (!selected) return;
+    containerRef.current
+      ?.querySelector(`[data-line="${selected.line}"]`)
+      ?.scrollIntoView({ block: "center" });
+  }, [selected]);
+
+  const jumpToLine = (line: number) => {
+    containerRef.current?.querySelector(`[data-line="${line}"]`)?.scrollIntoView({ block: "center" });
+  };
+
+  return (
+    <div className="flex flex-col">
+      <AlignmentSummary alignment={alignment} onSelectLayer={onSelectLayer} />
+      <pre ref={containerRef} className="text-xs leading-relaxed font-mono overflow-x-auto py-2">
+        {lines.map((text, i) => {
+          const n = i + 1;
+          const li = info.get(n);
+          const isSelected = li?.match && li.match === selected;
+          const bg = isSelected
+            ? "bg-accent/15"
+            : li?.unmatched
+              ? "bg-change-deleted/10"
+              : li?.match && !li.match.exact
+                ? "bg-amber-500/10"
+                : "";
+          return (
+            <div
+              key={n}
+              data-line={n}
+              className={`flex whitespace-pre ${bg} ${li?.match ? "cursor-pointer hover:bg-stone-800/50" : ""}`}
+              onClick={li?.match ? () => onSelectLayer(li.match!.layer) : undefined}
+            >
+              <span className="w-10 shrink-0 pr-3 text-right text-stone-600 select-none">{n}</span>
+              <span className="w-12 shrink-0 select-none">
+                {li?.first && li.match && (
+                  <span
+                    className={`text-[10px] px-1 rounded ${li.match.exact ? "bg-stone-800 text-stone-400" : "bg-amber-500/20 text-amber-400"}`}
+                    title={li.match.exact ? `layer ${li.match.layer}` : `layer ${li.match.layer}: history differs from this line`}
+                  >
+                    {li.match.layer}
+                    {!li.match.exact && "!"}
+                  </span>
+                )}
+                {li?.first && li.unmatched && (
+                  <span className="text-[10px] px-1 rounded bg-change-deleted/20 text-change-deleted" title="no layer in the image">
+                    ✗
+                  </span>
+                )}
+              </span>
+              <span className="pr-3">
+                {tokens?.[i]
+                  ? tokens[i].map((t, j) => (
+                      <span key={j} style={{ color: t.color }}>
+                        {t.content}
+                      </span>
+                    ))
+                  : <span className="text-stone-300">{text}</span>}
+                {li?.first && li.match?.fromLine && (
+                  <button
+                    type="button"
+                    className="ml-3 text-[10px] text-accent/70 hover:text-accent outline-none"
+                    onClick={(e) => {
+                      e.stopPropagation();
+                      jumpToLine(li.match!.fromLine!);
+                    }}
+                  >
+                    ↑ stage {li.match.fromStage} (line {li.match.fromLine})
+                  </button>
+                )}
+              </span>
+            </div>
+          );
+        })}
+      </pre>
+    </div>
+  );
+}
+
+function AlignmentSummary({
+  alignment,
+  onSelectLayer,
+}: {
+  alignment: DockerfileAlignment;
+  onSelectLayer: (index: number) => void;
+}) {
+  const inexact = alignment.matches.filter((m) => !m.exact).length;
+  if (inexact === 0 && alignment.unmatched.length === 0 && alignment.extra.length === 0) {
+    return (
+      <div className="px-3 py-1.5 border-b border-border text-[11px] text-stone-500">
+        {alignment.matches.length} layers match this Dockerfile
+      </div>
+    );
+  }
+  return (
+    <div className="px-3 py-1.5 border-b border-border text-[11px] text-amber-400 flex flex-wrap gap-x-3 gap-y-0.5">
+      <span className="text-stone-500">{alignment.matches.length} layers matched</span>
+      {inexact > 0 && <span>{inexact} differ from their line</span>}
+      {alignment.unmatched.length > 0 && (
+        <span className="text-change-deleted">{alignment.unmatched.length} instructions without a layer</span>
+      )}
+      {alignment.extra.length > 0 && (
+        <span>
+          layers not in Dockerfile:{" "}
+          {alignment.extra.map((l) => (
+            <button
+              key={l}
+              type="button"
+              className="ml-1 underline decoration-dotted hover:text-amber-300 outline-none"
+              onClick={() => onSelectLayer(l)}
+            >
+              {l}
+            </button>
+          ))}
+        </span>
+      )}
+    </div>
+  );
+}
+
+/** Highlight Dockerfile content line by line. Null until ready. */
+function useDockerfileTokens(content: string): ThemedToken[][] | null {
+  const [tokens, setTokens] = useState<ThemedToken[][] | null>(null);
+
+  useEffect(() => {
+    setTokens(null);
+    let cancelled = false;
+    getHighlighter().then((hl) => {
+      if (cancelled) return;
+      try {
+        setTokens(hl.codeToTokens(content.replace(/\n$/, ""), { lang: "dockerfile", theme: "rose-pine" }).tokens);
+      } catch {
+        setTokens(null);
+      }
+    });
+    return () => {
+      cancelled = true;
+    };
+  }, [content]);
+
+  return tokens;
+}
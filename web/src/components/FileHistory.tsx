@@ -0,0 +1,71 @@
+import { useQuery } from "@tanstack/react-query";
+import { api } from "../api";
+import type { ChangeKind, FileVersion } from "../types";
+import { formatBytes, cleanCommand } from "../utils";
+import { DiffView } from "./DiffView";
+
+const changeBadges: Record<ChangeKind, string> = {
+  added: "bg-change-added/20 text-change-added",
+  modified: "bg-change-modified/20 text-change-modified",
+  deleted: "bg-change-deleted/20 text-change-deleted",
+};
+
+interface FileHistoryProps {
+  path: string;
+  selectedLayer: number | null;
+  onSelectLayer?: (index: number) => void;
+}
+
+/** Timeline of every layer that changed a path, with diffs between versions. */
+export function FileHistory({ path, selectedLayer, onSelectLayer }: FileHistoryProps) {
+  const { data, isPending, error } = useQuery<FileVersion[]>({
+    queryKey: ["history", path],
+    queryFn: () => api.history(path),
+  });
+
+  if (isPending) {
+    return <div className="flex items-center justify-center h-32 text-stone-500 text-sm">Loading…</div>;
+  }
+  if (error) {
+    return <div className="p-3 text-xs text-red-400 font-mono">{error.message}</div>;
+  }
+
+  return (
+    <ol className="flex flex-col">
+      {data.map((v) => (
+        <li key={v.layer} className="border-b border-border">
+          <button
+            type="button"
+            className={`w-full flex items-center gap-2 px-3 py-1.5 text-left text-xs cursor-pointer outline-none hover:bg-stone-800/50 ${
+              v.layer === selectedLayer ? "bg-accent/10" : ""
+            }`}
+            onClick={() => onSelectLayer?.(v.layer)}
+          >
+            <span className="shrink-0 w-5 h-5 rounded bg-stone-800 text-[10px] font-mono flex items-center justify-center text-stone-400">
+              {v.layer}
+            </span>
+            <span className={`shrink-0 text-[10px] px-1.5 py-0.5 rounded ${changeBadges[v.changeKind]}`}>
+              {v.changeKind}
+            </span>
+            <span className="flex-1 min-w-0 truncate font-mono text-stone-400">{v.instruction || cleanCommand(v.command)}</span>
+            {v.changeKind !== "deleted" && (
+              <span className="shrink-0 text-stone-500 font-mono">{formatBytes(v.size)}</span>
+            )}
+          </button>
+          {v.diff ? (
+            <>
+              {v.truncated && (
+                <div className="px-3 py-1.5 text-[11px] text-stone-500">
+                  Content truncated; only the leading bytes are compared.
+                </div>
+              )}
+              <DiffView diff={v.diff} path={path} />
+            </>
+          ) : (
+            v.content?.isBinary && <div className="px-3 pb-2 text-[11px] text-stone-500">binary content changed</div>
+          )}
+        </li>
+      ))}
+    </ol>
+  );
+}
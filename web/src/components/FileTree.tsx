@@ -1,7 +1,9 @@
 import { useState, useMemo, useCallback, useRef, useEffect, useImperativeHandle, forwardRef } from "react";
-import type { FileNode, DiffEntry, ChangeKind } from "../types";
+import { useQueryClient } from "@tanstack/react-query";
+import type { DirEntry, DiffEntry, ChangeKind, TreeView, TreeSort } from "../types";
 import { formatBytes } from "../utils";
 import { useTreeKeyboard, type VisibleNode } from "../hooks/useTreeKeyboard";
+import { useDirListings, dirQueryKey } from "../hooks/useDirListings";
 
 const changeDots: Record<ChangeKind, string> = {
   added: "bg-change-added",
@@ -14,7 +16,7 @@ export interface FileTreeHandle {
 }
 
 interface FileTreeProps {
-  tree: FileNode | null;
+  layer: number | null;
   diff: DiffEntry[];
   selectedFile: string | null;
   onSelectFile: (path: string) => void;
@@ -23,55 +25,113 @@ interface FileTreeProps {
   onExpandedChange?: (expanded: Set<string>) => void;
   changesOnly: boolean;
   onChangesOnlyChange: (v: boolean) => void;
+  view: TreeView;
+  onViewChange: (v: TreeView) => void;
+  sortBy: TreeSort;
+  onSortByChange: (v: TreeSort) => void;
 }
 
-/** Collect all dir paths from a tree, optionally filtering by max depth. */
-function collectDirPaths(node: FileNode, depth: number, maxDepth?: number): string[] {
-  const paths: string[] = [];
-  if (node.type !== "dir") return paths;
-  if (node.path !== "/") paths.push(node.path);
-  if (maxDepth !== undefined && depth >= maxDepth) return paths;
-  for (const child of node.children ?? []) {
-    paths.push(...collectDirPaths(child, depth + 1, maxDepth));
-  }
-  return paths;
+type Listings = (path: string) => DirEntry[] | undefined;
+
+function parentDir(path: string): string {
+  const i = path.lastIndexOf("/");
+  return i <= 0 ? "/" : path.slice(0, i);
+}
+
+function sortBySize(entries: DirEntry[]): DirEntry[] {
+  return [...entries].sort((a, b) => b.totalSize - a.totalSize);
 }
 
-/** Filter tree to only nodes present in diffMap + their ancestor dirs. Returns null if nothing matches. */
-function filterTreeToChanges(node: FileNode, diffMap: Map<string, ChangeKind>): FileNode | null {
-  if (node.type !== "dir") {
-    return diffMap.has(node.path) ? node : null;
+function sortEntries(entries: DirEntry[]) {
+  entries.sort((a, b) => {
+    if ((a.type === "dir") !== (b.type === "dir")) return a.type === "dir" ? -1 : 1;
+    return a.name < b.name ? -1 : a.name > b.name ? 1 : 0;
+  });
+}
+
+/** Build directory listings containing only changed paths + their ancestor dirs. */
+function listingsFromDiff(diff: DiffEntry[], layer: number): Map<string, DirEntry[]> {
+  const listings = new Map<string, DirEntry[]>([["/", []]]);
+  const entries = new Map<string, DirEntry>();
+
+  const ensure = (
+    path: string,
+    entry: Omit<DirEntry, "addedIn" | "modifiedIn" | "childCount" | "descendants" | "totalSize" | "layerSize">,
+  ) => {
+    const existing = entries.get(path);
+    if (existing) return existing;
+    const parent = parentDir(path);
+    if (parent !== "/") {
+      ensure(parent, { name: parent.slice(parent.lastIndexOf("/") + 1), path: parent, type: "dir", size: 0 });
+    }
+    const created: DirEntry = {
+      ...entry,
+      addedIn: layer,
+      modifiedIn: layer,
+      childCount: 0,
+      descendants: 0,
+      totalSize: 0,
+      layerSize: 0,
+    };
+    entries.set(path, created);
+    if (!listings.has(parent)) listings.set(parent, []);
+    listings.get(parent)!.push(created);
+    if (created.type === "dir" && !listings.has(path)) listings.set(path, []);
+    return created;
+  };
+
+  for (const d of diff) {
+    ensure(d.path, { name: d.path.slice(d.path.lastIndexOf("/") + 1), path: d.path, type: d.type, size: d.size });
+    // Roll the change up into every ancestor's totals
+    for (let p = parentDir(d.path); p !== "/"; p = parentDir(p)) {
+      const ancestor = entries.get(p)!;
+      ancestor.descendants++;
+      if (d.changeKind !== "deleted") ancestor.totalSize += d.size;
+    }
+    if (d.changeKind !== "deleted") entries.get(d.path)!.totalSize += d.size;
+  }
+  for (const [path, children] of listings) {
+    sortEntries(children);
+    const entry = entries.get(path);
+    if (entry) entry.childCount = children.length;
   }
-  const filteredChildren: FileNode[] = [];
-  for (const child of node.children ?? []) {
-    const filtered = filterTreeToChanges(child, diffMap);
-    if (filtered) filteredChildren.push(filtered);
+  for (const entry of entries.values()) entry.layerSize = entry.totalSize;
+  return listings;
+}
+
+/** Collect dir paths reachable through loaded listings, up to maxDepth. */
+function collectDirPaths(listings: Listings, path: string, depth: number, maxDepth: number): string[] {
+  const paths: string[] = [];
+  for (const child of listings(path) ?? []) {
+    if (child.type !== "dir") continue;
+    paths.push(child.path);
+    if (depth < maxDepth) paths.push(...collectDirPaths(listings, child.path, depth + 1, maxDepth));
   }
-  if (filteredChildren.length === 0 && !diffMap.has(node.path)) return null;
-  return { ...node, children: filteredChildren };
+  return paths;
 }
 
-/** Flatten tree + expanded set into visible nodes list. Skips root "/". */
+/** Flatten loaded listings + expanded set into visible nodes list. Skips root "/". */
 function flattenTree(
-  node: FileNode,
+  listings: Listings,
+  path: string,
   expanded: Set<string>,
   depth: number,
   parentPath: string | null,
   out: VisibleNode[],
-  nodeMap: Map<string, FileNode>,
+  nodeMap: Map<string, DirEntry>,
 ) {
-  for (const child of node.children ?? []) {
+  for (const child of listings(path) ?? []) {
     const isDir = child.type === "dir";
     out.push({ path: child.path, depth, isDir, parentPath });
     nodeMap.set(child.path, child);
     if (isDir && expanded.has(child.path)) {
-      flattenTree(child, expanded, depth + 1, child.path, out, nodeMap);
+      flattenTree(listings, child.path, expanded, depth + 1, child.path, out, nodeMap);
     }
   }
 }
 
 export const FileTree = forwardRef<FileTreeHandle, FileTreeProps>(function FileTree(
-  { tree, diff, selectedFile, onSelectFile, loading, initialExpanded, onExpandedChange, changesOnly, onChangesOnlyChange },
+  { layer, diff, selectedFile, onSelectFile, loading, initialExpanded, onExpandedChange, changesOnly, onChangesOnlyChange, view, onViewChange, sortBy, onSortByChange },
   ref,
 ) {
   const [expanded, setExpanded] = useState<Set<string>>(
@@ -81,6 +141,7 @@ export const FileTree = forwardRef<FileTreeHandle, FileTreeProps>(function FileT
   const [focusedIndex, setFocusedIndex] = useState(0);
   const containerRef = useRef<HTMLDivElement>(null);
   const rowRefs = useRef<Map<number, HTMLDivElement>>(new Map());
+  const queryClient = useQueryClient();
 
   const diffMap = useMemo(() => {
     const m = new Map<string, ChangeKind>();
@@ -88,10 +149,31 @@ export const FileTree = forwardRef<FileTreeHandle, FileTreeProps>(function FileT
     return m;
   }, [diff]);
 
-  // State-during-render: set depth-1 defaults when tree first loads
-  if (!initialized && tree) {
+  const showChanges = changesOnly && view !== "layer";
+  const changeListings = useMemo(
+    () => (showChanges && layer !== null ? listingsFromDiff(diff, layer) : null),
+    [showChanges, diff, layer],
+  );
+
+  // Only the root and expanded directories are fetched
+  const requestedPaths = useMemo(() => ["/", ...[...expanded].sort()], [expanded]);
+  const { listings: fetched, rootPending } = useDirListings(layer, view, requestedPaths, !showChanges);
+
+  const listings = useCallback<Listings>(
+    (path) => {
+      const entries = changeListings
+        ? changeListings.get(path)
+        : (fetched.get(path) ?? queryClient.getQueryData<DirEntry[]>(dirQueryKey(layer, view, path)));
+      return entries && sortBy === "size" ? sortBySize(entries) : entries;
+    },
+    [changeListings, fetched, queryClient, layer, view, sortBy],
+  );
+  const rootListing = listings("/");
+
+  // State-during-render: set depth-1 defaults when the root first loads
+  if (!initialized && rootListing) {
     setInitialized(true);
-    setExpanded(new Set(collectDirPaths(tree, 0, 1)));
+    setExpanded(new Set(collectDirPaths(listings, "/", 0, 0)));
     setFocusedIndex(0);
   }
 
@@ -99,18 +181,12 @@ export const FileTree = forwardRef<FileTreeHandle, FileTreeProps>(function FileT
     onExpandedChange?.(expanded);
   }, [expanded, onExpandedChange]);
 
-  const displayTree = useMemo(() => {
-    if (!tree) return null;
-    if (!changesOnly) return tree;
-    return filterTreeToChanges(tree, diffMap);
-  }, [tree, changesOnly, diffMap]);
-
   const { visibleNodes, nodeMap } = useMemo(() => {
     const nodes: VisibleNode[] = [];
-    const map = new Map<string, FileNode>();
-    if (displayTree) flattenTree(displayTree, expanded, 0, null, nodes, map);
+    const map = new Map<string, DirEntry>();
+    flattenTree(listings, "/", expanded, 0, null, nodes, map);
     return { visibleNodes: nodes, nodeMap: map };
-  }, [displayTree, expanded]);
+  }, [listings, expanded]);
 
   // Clamp focused index
   useEffect(() => {
@@ -130,23 +206,35 @@ export const FileTree = forwardRef<FileTreeHandle, FileTreeProps>(function FileT
     }
   }, [focusedIndex]);
 
+  const isSelectable = useCallback(
+    (path: string) => nodeMap.get(path)?.type !== "whiteout" && diffMap.get(path) !== "deleted",
+    [nodeMap, diffMap],
+  );
+
+  const selectFile = useCallback(
+    (path: string) => {
+      if (isSelectable(path)) onSelectFile(path);
+    },
+    [isSelectable, onSelectFile],
+  );
+
   const handleKeyDown = useTreeKeyboard({
     visibleNodes,
     focusedIndex,
     setFocusedIndex,
     expanded,
     setExpanded,
-    onSelectFile,
+    onSelectFile: selectFile,
   });
 
   const toggleAllFolders = useCallback(() => {
-    if (!tree) return;
+    if (!rootListing) return;
     if (expanded.size > 0) {
       setExpanded(new Set());
     } else {
-      setExpanded(new Set(collectDirPaths(tree, 0, 3)));
+      setExpanded(new Set(collectDirPaths(listings, "/", 0, 2)));
     }
-  }, [tree, expanded]);
+  }, [rootListing, listings, expanded]);
 
   useImperativeHandle(ref, () => ({ toggleAllFolders }), [toggleAllFolders]);
 
@@ -163,12 +251,13 @@ export const FileTree = forwardRef<FileTreeHandle, FileTreeProps>(function FileT
           return next;
         });
       } else {
-        onSelectFile(node.path);
+        selectFile(node.path);
       }
     },
-    [visibleNodes, onSelectFile],
+    [visibleNodes, selectFile],
   );
 
+  const treeLoading = loading || (!showChanges && rootPending);
   const changesCount = diff.length;
 
   return (
@@ -178,11 +267,32 @@ export const FileTree = forwardRef<FileTreeHandle, FileTreeProps>(function FileT
         <span className="text-xs font-medium text-stone-400">Files</span>
         <button
           type="button"
-          className={`ml-auto flex items-center gap-1.5 px-2 py-0.5 rounded text-[11px] font-medium transition-colors outline-none ${
-            changesOnly
+          title="Sort by name or by total size"
+          className="ml-2 px-2 py-0.5 rounded text-[11px] font-medium text-stone-500 hover:text-stone-300 hover:bg-stone-800 transition-colors outline-none"
+          onClick={() => onSortByChange(sortBy === "size" ? "name" : "size")}
+        >
+          {sortBy === "size" ? "By size" : "By name"}
+        </button>
+        <button
+          type="button"
+          title="Show only what this layer's tar contains"
+          className={`ml-auto px-2 py-0.5 rounded text-[11px] font-medium transition-colors outline-none ${
+            view === "layer"
               ? "bg-accent/20 text-accent"
               : "text-stone-500 hover:text-stone-300 hover:bg-stone-800"
           }`}
+          onClick={() => onViewChange(view === "layer" ? "cumulative" : "layer")}
+        >
+          Layer only
+        </button>
+        <button
+          type="button"
+          disabled={view === "layer"}
+          className={`flex items-center gap-1.5 px-2 py-0.5 rounded text-[11px] font-medium transition-colors outline-none ${
+            changesOnly
+              ? "bg-accent/20 text-accent"
+              : "text-stone-500 hover:text-stone-300 hover:bg-stone-800"
+          } disabled:opacity-40 disabled:pointer-events-none`}
           onClick={() => onChangesOnlyChange(!changesOnly)}
         >
           Changes
@@ -206,13 +316,13 @@ export const FileTree = forwardRef<FileTreeHandle, FileTreeProps>(function FileT
         className="flex-1 overflow-y-auto p-1 text-xs font-mono outline-none"
         onKeyDown={handleKeyDown}
       >
-        {loading ? (
+        {treeLoading ? (
           <div className="flex items-center justify-center h-32 text-stone-500">
             Loading…
           </div>
         ) : visibleNodes.length === 0 ? (
           <div className="text-stone-500 p-3">
-            {changesOnly ? "No changes" : "Empty layer"}
+            {showChanges ? "No changes" : "Empty layer"}
           </div>
         ) : (
           visibleNodes.map((vn, i) => {
@@ -221,6 +331,7 @@ export const FileTree = forwardRef<FileTreeHandle, FileTreeProps>(function FileT
             const active = vn.path === selectedFile;
             const focused = i === focusedIndex;
             const isSymlink = fileNode.type === "symlink";
+            const isWhiteout = fileNode.type === "whiteout" || change === "deleted";
 
             return (
               <div
@@ -241,25 +352,60 @@ export const FileTree = forwardRef<FileTreeHandle, FileTreeProps>(function FileT
               >
                 {/* Expand/collapse icon */}
                 <span className="w-4 shrink-0 text-center text-stone-500">
-                  {vn.isDir ? (expanded.has(vn.path) ? "▾" : "▸") : isSymlink ? "↗" : " "}
+                  {vn.isDir ? (expanded.has(vn.path) ? "▾" : "▸") : isSymlink ? "↗" : isWhiteout ? "✕" : " "}
                 </span>
 
                 {/* Name */}
-                <span className={`flex-1 truncate ${vn.isDir ? "text-stone-200" : ""}`}>
+                <span
+                  className={`flex-1 truncate ${vn.isDir ? "text-stone-200" : ""} ${
+                    isWhiteout ? "line-through text-change-deleted" : ""
+                  }`}
+                >
                   {fileNode.name}
                   {isSymlink && fileNode.linkTarget && (
                     <span className="text-stone-600"> → {fileNode.linkTarget}</span>
                   )}
+                  {fileNode.opaque && (
+                    <span
+                      className="ml-1.5 text-[10px] px-1 rounded bg-change-deleted/20 text-change-deleted"
+                      title="Opaque whiteout: hides everything below this directory from earlier layers"
+                    >
+                      opaque
+                    </span>
+                  )}
+                </span>
+
+                {/* Blame column: layer that last wrote this path */}
+                <span
+                  className={`w-6 text-right tabular-nums shrink-0 ${
+                    fileNode.modifiedIn === layer ? "text-accent/70" : "text-stone-700"
+                  }`}
+                  title={
+                    isWhiteout
+                      ? undefined
+                      : `added in layer ${fileNode.addedIn}, last written in layer ${fileNode.modifiedIn}`
+                  }
+                >
+                  {!isWhiteout && view !== "layer" ? fileNode.modifiedIn : ""}
                 </span>
 
-                {/* Size column */}
-                <span className="w-14 text-right tabular-nums text-stone-600 shrink-0">
-                  {!vn.isDir && fileNode.size > 0 ? formatBytes(fileNode.size) : ""}
+                {/* Size column: directories show the total of everything below them */}
+                <span
+                  className={`w-14 text-right tabular-nums shrink-0 ${vn.isDir ? "text-stone-700" : "text-stone-600"}`}
+                  title={
+                    vn.isDir
+                      ? `${fileNode.descendants} entries · ${formatBytes(fileNode.layerSize)} from this layer`
+                      : undefined
+                  }
+                >
+                  {vn.isDir
+                    ? fileNode.totalSize > 0 ? formatBytes(fileNode.totalSize) : ""
+                    : fileNode.size > 0 ? formatBytes(fileNode.size) : ""}
                 </span>
 
                 {/* Change dot column */}
                 <span className="w-3 flex justify-center shrink-0">
-                  {change && (
+                  {change && view !== "layer" && (
                     <span
                       className={`w-1.5 h-1.5 rounded-full ${changeDots[change]}`}
                     />
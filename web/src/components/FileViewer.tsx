@@ -1,15 +1,32 @@
-import { useState, useEffect } from "react";
+import { Fragment, useState, useEffect, useRef } from "react";
 import type { FileContent } from "../types";
 import { formatBytes } from "../utils";
 import { detectLanguage } from "../lang";
 import { getHighlighter } from "../highlight";
+import { FileHistory } from "./FileHistory";
+import { CompareView } from "./CompareView";
+
+type ViewerTab = "content" | "history" | "compare";
+
+const tabLabels: Record<ViewerTab, string> = {
+  content: "Content",
+  history: "History",
+  compare: "Compare",
+};
 
 interface FileViewerProps {
   file: FileContent | null;
   loading: boolean;
+  error?: string | null;
+  selectedLayer: number | null;
+  onSelectLayer?: (index: number) => void;
+  line?: number | null;
+  onSelectLine?: (line: number | null) => void;
 }
 
-export function FileViewer({ file, loading }: FileViewerProps) {
+export function FileViewer({ file, loading, error, selectedLayer, onSelectLayer, line, onSelectLine }: FileViewerProps) {
+  const [tab, setTab] = useState<ViewerTab>("content");
+
   if (loading) {
     return (
       <div className="flex items-center justify-center h-full text-stone-500 text-sm">
@@ -21,7 +38,7 @@ export function FileViewer({ file, loading }: FileViewerProps) {
   if (!file) {
     return (
       <div className="flex items-center justify-center h-full text-stone-500 text-sm">
-        Select a file to view its contents
+        {error ?? "Select a file to view its contents"}
       </div>
     );
   }
@@ -48,21 +65,50 @@ export function FileViewer({ file, loading }: FileViewerProps) {
             binary
           </span>
         )}
+        <div className="ml-auto flex items-center gap-1 shrink-0">
+          {(["content", "history", "compare"] as const).map((t) => (
+            <button
+              key={t}
+              type="button"
+              className={`px-2 py-0.5 rounded text-[11px] font-medium transition-colors outline-none ${
+                tab === t ? "bg-accent/20 text-accent" : "text-stone-500 hover:text-stone-300 hover:bg-stone-800"
+              }`}
+              onClick={() => setTab(t)}
+            >
+              {tabLabels[t]}
+            </button>
+          ))}
+        </div>
       </div>
       <div className="flex-1 overflow-auto">
-        {file.isBinary ? (
+        {tab === "history" ? (
+          <FileHistory path={file.path} selectedLayer={selectedLayer} onSelectLayer={onSelectLayer} />
+        ) : tab === "compare" && selectedLayer !== null ? (
+          <CompareView key={`${selectedLayer}:${file.path}`} path={file.path} layer={selectedLayer} />
+        ) : file.isBinary ? (
           <HexView content={file.content} />
         ) : (
-          <SyntaxView path={file.path} content={file.content} />
+          <SyntaxView path={file.path} content={file.content} line={line ?? null} onSelectLine={onSelectLine} />
         )}
       </div>
     </div>
   );
 }
 
-function SyntaxView({ path, content }: { path: string; content: string }) {
+interface SyntaxViewProps {
+  path: string;
+  content: string;
+  /** highlighted line, 1-based */
+  line?: number | null;
+  /** numbers the lines and makes them selectable */
+  onSelectLine?: (line: number | null) => void;
+}
+
+export function SyntaxView({ path, content, line = null, onSelectLine }: SyntaxViewProps) {
   const [html, setHtml] = useState<string | null>(null);
+  const containerRef = useRef<HTMLDivElement>(null);
   const lang = detectLanguage(path);
+  const numbered = onSelectLine !== undefined;
 
   useEffect(() => {
     if (!lang) {
@@ -73,24 +119,74 @@ function SyntaxView({ path, content }: { path: string; content: string }) {
     getHighlighter().then((hl) => {
       if (cancelled) return;
       try {
-        const result = hl.codeToHtml(content, { lang, theme: "rose-pine" });
+        const result = hl.codeToHtml(content, {
+          lang,
+          theme: "rose-pine",
+          transformers: numbered
+            ? [
+                {
+                  line(node, n) {
+                    node.properties["data-line"] = n;
+                  },
+                },
+              ]
+            : [],
+        });
         setHtml(result);
       } catch {
         setHtml(null);
       }
     });
     return () => { cancelled = true; };
-  }, [content, lang]);
+  }, [content, lang, numbered]);
+
+  // Mark the selected line and bring it into view
+  useEffect(() => {
+    const root = containerRef.current;
+    if (!root) return;
+    root.querySelectorAll(".line-selected").forEach((el) => el.classList.remove("line-selected"));
+    if (line === null) return;
+    const el = root.querySelector(`[data-line="${line}"]`);
+    el?.classList.add("line-selected");
+    el?.scrollIntoView({ block: "nearest" });
+  }, [html, content, line]);
+
+  const handleClick = (e: React.MouseEvent) => {
+    if (!onSelectLine || !window.getSelection()?.isCollapsed) return;
+    const el = (e.target as HTMLElement).closest("[data-line]");
+    if (!el) return;
+    const n = Number(el.getAttribute("data-line"));
+    onSelectLine(n === line ? null : n);
+  };
 
   if (html) {
     return (
       <div
-        className="text-xs leading-relaxed [&_pre]:!bg-transparent [&_pre]:p-3 [&_pre]:overflow-x-auto"
+        ref={containerRef}
+        className={`text-xs leading-relaxed [&_pre]:!bg-transparent [&_pre]:p-3 [&_pre]:overflow-x-auto ${numbered ? "line-numbers" : ""}`}
+        onClick={handleClick}
         dangerouslySetInnerHTML={{ __html: html }}
       />
     );
   }
 
+  if (numbered) {
+    return (
+      <div ref={containerRef} className="line-numbers" onClick={handleClick}>
+        <pre className="p-3 text-xs leading-relaxed text-stone-300 overflow-x-auto whitespace-pre">
+          {content.split("\n").map((text, i) => (
+            <Fragment key={i}>
+              {i > 0 && "\n"}
+              <span className="line" data-line={i + 1}>
+                {text}
+              </span>
+            </Fragment>
+          ))}
+        </pre>
+      </div>
+    );
+  }
+
   return (
     <pre className="p-3 text-xs leading-relaxed text-stone-300 overflow-x-auto whitespace-pre">
       {content}
@@ -1,15 +1,23 @@
-import { useCallback } from "react";
+import { useCallback, useMemo } from "react";
 import { Tooltip } from "@base-ui-components/react/tooltip";
-import type { LayerInfo } from "../types";
+import type { DockerfileAlignment, LayerInfo } from "../types";
 import { formatBytes, cleanCommand } from "../utils";
 
 interface LayerListProps {
   layers: LayerInfo[];
   selected: number | null;
-  onSelect: (index: number) => void;
+  rangeStart: number | null;
+  /** `extend` is set on shift-click to select a range ending at the current selection. */
+  onSelect: (index: number, extend?: boolean) => void;
+  /** Correlation with the --dockerfile source, when one was given. */
+  alignment?: DockerfileAlignment;
+  onShowSource?: (index: number) => void;
 }
 
-export function LayerList({ layers, selected, onSelect }: LayerListProps) {
+export function LayerList({ layers, selected, rangeStart, onSelect, alignment, onShowSource }: LayerListProps) {
+  const matches = useMemo(() => new Map(alignment?.matches.map((m) => [m.layer, m] as const)), [alignment]);
+  const extra = useMemo(() => new Set(alignment?.extra), [alignment]);
+
   const handleKeyDown = useCallback(
     (e: React.KeyboardEvent) => {
       if (selected === null) return;
@@ -29,7 +37,9 @@ export function LayerList({ layers, selected, onSelect }: LayerListProps) {
       <div className="flex flex-col overflow-y-auto" onKeyDown={handleKeyDown}>
         {layers.map((layer) => {
           const active = layer.index === selected;
-          const cmd = cleanCommand(layer.command);
+          const inRange =
+            rangeStart !== null && selected !== null && layer.index >= rangeStart && layer.index < selected;
+          const cmd = layer.instruction || cleanCommand(layer.command);
           return (
             <Tooltip.Root
               key={layer.index}
@@ -43,9 +53,11 @@ export function LayerList({ layers, selected, onSelect }: LayerListProps) {
                     className={`flex items-center gap-2 px-3 py-2 text-left text-sm border-l-2 transition-colors cursor-pointer outline-none ${
                       active
                         ? "bg-accent/10 border-accent text-stone-100"
-                        : "border-transparent hover:bg-stone-800/50 text-stone-300"
+                        : inRange
+                          ? "bg-accent/5 border-accent/40 text-stone-200"
+                          : "border-transparent hover:bg-stone-800/50 text-stone-300"
                     } ${layer.empty ? "opacity-40" : ""}`}
-                    onClick={() => onSelect(layer.index)}
+                    onClick={(e) => onSelect(layer.index, e.shiftKey)}
                   />
                 }
               >
@@ -55,6 +67,13 @@ export function LayerList({ layers, selected, onSelect }: LayerListProps) {
                 <span className="flex-1 min-w-0 truncate font-mono text-xs">
                   {cmd || "(empty)"}
                 </span>
+                {(matches.has(layer.index) || extra.has(layer.index)) && (
+                  <SourceBadge
+                    line={matches.get(layer.index)?.line}
+                    exact={matches.get(layer.index)?.exact ?? false}
+                    onClick={() => onShowSource?.(layer.index)}
+                  />
+                )}
                 {layer.size > 0 && (
                   <span className="shrink-0 text-xs text-stone-500 font-mono">
                     {formatBytes(layer.size)}
@@ -66,6 +85,7 @@ export function LayerList({ layers, selected, onSelect }: LayerListProps) {
                   <Tooltip.Positioner sideOffset={8}>
                     <Tooltip.Popup className="max-w-sm rounded bg-stone-800 px-3 py-2 text-xs font-mono text-stone-200 shadow-lg border border-stone-700 z-50">
                       {layer.command}
+                      <LayerDetails layer={layer} />
                     </Tooltip.Popup>
                   </Tooltip.Positioner>
                 </Tooltip.Portal>
@@ -77,3 +97,48 @@ export function LayerList({ layers, selected, onSelect }: LayerListProps) {
     </Tooltip.Provider>
   );
 }
+
+/** Dockerfile line that produced a layer; amber when the two disagree or the layer has no line. */
+function SourceBadge({ line, exact, onClick }: { line?: number; exact: boolean; onClick: () => void }) {
+  return (
+    <span
+      role="link"
+      className={`shrink-0 text-[10px] font-mono px-1 rounded ${
+        exact ? "text-stone-500 hover:text-stone-300" : "bg-amber-500/20 text-amber-400"
+      }`}
+      title={line === undefined ? "not in Dockerfile" : exact ? `Dockerfile line ${line}` : `Dockerfile line ${line} differs from history`}
+      onClick={(e) => {
+        e.stopPropagation();
+        onClick();
+      }}
+    >
+      {line === undefined ? "?" : `L${line}`}
+    </span>
+  );
+}
+
+/** History and blob metadata shown under the command in a layer's tooltip. */
+function LayerDetails({ layer }: { layer: LayerInfo }) {
+  const rows: [string, string][] = [];
+  if (layer.created) rows.push(["created", new Date(layer.created).toLocaleString()]);
+  if (layer.author) rows.push(["author", layer.author]);
+  if (layer.comment) rows.push(["comment", layer.comment]);
+  if (!layer.empty && layer.uncompressedSize > 0) {
+    const ratio = layer.size > 0 ? ` (${(layer.uncompressedSize / layer.size).toFixed(1)}×)` : "";
+    rows.push(["size", `${formatBytes(layer.size)} compressed, ${formatBytes(layer.uncompressedSize)} uncompressed${ratio}`]);
+  }
+  if (layer.digest) rows.push(["digest", layer.digest.replace(/^sha256:/, "").slice(0, 12)]);
+  if (layer.mediaType) rows.push(["type", layer.mediaType]);
+  if (rows.length === 0) return null;
+
+  return (
+    <div className="mt-2 pt-2 border-t border-stone-700 grid grid-cols-[auto_1fr] gap-x-3 gap-y-0.5 text-[11px]">
+      {rows.map(([label, value]) => (
+        <div key={label} className="contents">
+          <span className="text-stone-500">{label}</span>
+          <span className="text-stone-300 break-all">{value}</span>
+        </div>
+      ))}
+    </div>
+  );
+}
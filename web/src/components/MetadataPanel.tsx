@@ -1,5 +1,6 @@
 import { Collapsible } from "@base-ui-components/react/collapsible";
 import type { ImageInfo } from "../types";
+import { formatBytes, formatHealthcheck } from "../utils";
 
 interface MetadataPanelProps {
   image: ImageInfo | null;
@@ -17,7 +18,8 @@ export function MetadataPanel({ image }: MetadataPanelProps) {
       <Collapsible.Panel className="overflow-hidden transition-all duration-150 h-[var(--collapsible-panel-height)] data-[starting-style]:h-0 data-[ending-style]:h-0">
         <div className="mt-2 p-3 rounded bg-panel border border-border text-xs font-mono grid grid-cols-[auto_auto] gap-x-4 gap-y-1.5 overflow-x-auto">
           <Row label="digest" value={image.digest} />
-          <Row label="platform" value={`${image.os}/${image.arch}`} />
+          <Row label="platform" value={[image.os, image.arch, image.variant].filter(Boolean).join("/")} />
+          {image.resolution && <Row label="source" value={`${image.resolution.source}: ${image.resolution.reason}`} />}
           <Row
             label="entrypoint"
             value={image.config.entrypoint?.join(" ") ?? "—"}
@@ -25,6 +27,17 @@ export function MetadataPanel({ image }: MetadataPanelProps) {
           <Row label="cmd" value={image.config.cmd?.join(" ") ?? "—"} />
           <Row label="workdir" value={image.config.workingDir || "—"} />
           <Row label="user" value={image.config.user || "—"} />
+          {image.created && <Row label="created" value={new Date(image.created).toLocaleString()} />}
+          {image.author && <Row label="author" value={image.author} />}
+          {image.config.exposedPorts && <Row label="ports" value={image.config.exposedPorts.join(" ")} />}
+          {image.config.volumes && <Row label="volumes" value={image.config.volumes.join(" ")} />}
+          {image.config.healthcheck && <Row label="healthcheck" value={formatHealthcheck(image.config.healthcheck)} />}
+          {image.config.stopSignal && <Row label="stopsignal" value={image.config.stopSignal} />}
+          {image.config.shell && <Row label="shell" value={JSON.stringify(image.config.shell)} />}
+          {image.config.argsEscaped && <Row label="argsEscaped" value="true" />}
+          {image.config.onBuild && image.config.onBuild.length > 0 && (
+            <ListRow label="onbuild" items={image.config.onBuild} />
+          )}
           {image.config.env && image.config.env.length > 0 && (
             <>
               <span className="text-stone-500">env</span>
@@ -51,6 +64,24 @@ export function MetadataPanel({ image }: MetadataPanelProps) {
                 </div>
               </>
             )}
+          <Row label="rootfs" value={`${image.rootfs.type}, ${image.rootfs.diffIDs.length} diff IDs`} />
+          <Row label="manifest" value={`${image.manifest.mediaType} (${formatBytes(image.manifest.size)})`} />
+          <Row label="config" value={`${image.manifest.config.digest} (${formatBytes(image.manifest.config.size)})`} />
+          {image.manifest.annotations && Object.keys(image.manifest.annotations).length > 0 && (
+            <ListRow
+              label="annotations"
+              items={Object.entries(image.manifest.annotations).map(([k, v]) => `${k}=${v}`)}
+            />
+          )}
+          <span className="text-stone-500">raw</span>
+          <span className="flex gap-3">
+            <a className="text-accent/80 hover:text-accent" href="/api/config/raw" target="_blank" rel="noreferrer">
+              config.json
+            </a>
+            <a className="text-accent/80 hover:text-accent" href="/api/manifest/raw" target="_blank" rel="noreferrer">
+              manifest.json
+            </a>
+          </span>
         </div>
       </Collapsible.Panel>
     </Collapsible.Root>
@@ -65,3 +96,18 @@ function Row({ label, value }: { label: string; value: string }) {
     </>
   );
 }
+
+function ListRow({ label, items }: { label: string; items: string[] }) {
+  return (
+    <>
+      <span className="text-stone-500">{label}</span>
+      <div className="flex flex-col gap-0.5">
+        {items.map((item, i) => (
+          <span key={i} className="text-stone-300 whitespace-nowrap">
+            {item}
+          </span>
+        ))}
+      </div>
+    </>
+  );
+}
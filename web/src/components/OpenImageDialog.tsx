@@ -0,0 +1,84 @@
+import { useEffect, useRef, useState } from "react";
+import type { OpenTarget } from "../types";
+
+// containerd and OCI layouts read paths on disk, so the server only opens them from the command line
+const sources = ["auto", "docker", "podman", "remote"];
+
+interface OpenImageDialogProps {
+  currentRef: string | null;
+  onOpen: (target: OpenTarget) => void;
+  onClose: () => void;
+}
+
+/** Asks for an image to open in place of the current one. */
+export function OpenImageDialog({ currentRef, onOpen, onClose }: OpenImageDialogProps) {
+  const dialogRef = useRef<HTMLDialogElement>(null);
+  const [ref, setRef] = useState(currentRef ?? "");
+  const [platform, setPlatform] = useState("");
+  const [source, setSource] = useState("");
+
+  useEffect(() => {
+    dialogRef.current?.showModal();
+  }, []);
+
+  const inputClass =
+    "w-full bg-panel border border-border rounded px-2 py-1 text-xs font-mono text-stone-200 outline-none focus:border-accent/50";
+  const buttonClass =
+    "px-2 py-0.5 rounded text-[11px] font-medium text-stone-500 hover:text-stone-300 hover:bg-stone-800 outline-none";
+
+  return (
+    <dialog
+      ref={dialogRef}
+      className="m-auto w-[28rem] max-w-[90vw] bg-surface text-stone-100 border border-border rounded p-0 backdrop:bg-black/50"
+      onClose={onClose}
+    >
+      <form
+        className="flex flex-col gap-3 p-4"
+        onSubmit={(e) => {
+          e.preventDefault();
+          const target = ref.trim();
+          if (!target) return;
+          onOpen({ ref: target, platform: platform.trim() || undefined, source: source || undefined });
+          dialogRef.current?.close();
+        }}
+      >
+        <div className="text-sm font-semibold">Open image</div>
+        <label className="flex flex-col gap-1 text-[11px] text-stone-500">
+          image
+          <input
+            className={inputClass}
+            value={ref}
+            onChange={(e) => setRef(e.target.value)}
+            placeholder="registry/repository:tag or name@sha256:…"
+            autoFocus
+          />
+        </label>
+        <div className="flex gap-3">
+          <label className="flex-1 flex flex-col gap-1 text-[11px] text-stone-500">
+            platform
+            <input className={inputClass} value={platform} onChange={(e) => setPlatform(e.target.value)} placeholder="default" />
+          </label>
+          <label className="flex-1 flex flex-col gap-1 text-[11px] text-stone-500">
+            source
+            <select className={inputClass} value={source} onChange={(e) => setSource(e.target.value)}>
+              <option value="">default</option>
+              {sources.map((s) => (
+                <option key={s} value={s}>
+                  {s}
+                </option>
+              ))}
+            </select>
+          </label>
+        </div>
+        <div className="flex justify-end gap-2">
+          <button type="button" className={buttonClass} onClick={() => dialogRef.current?.close()}>
+            Cancel
+          </button>
+          <button type="submit" className="px-2 py-0.5 rounded text-[11px] font-medium bg-accent/20 text-accent outline-none">
+            Open
+          </button>
+        </div>
+      </form>
+    </dialog>
+  );
+}
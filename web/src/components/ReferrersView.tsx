@@ -0,0 +1,225 @@
+import { useState } from "react";
+import { useReferrer, useReferrers } from "../hooks/useReferrers";
+import type { Artifact, ArtifactDetail, ArtifactKind, Attestation, Provenance, Signature } from "../types";
+import { formatBytes } from "../utils";
+import { SyntaxView } from "./FileViewer";
+
+interface ReferrersViewProps {
+  onClose: () => void;
+}
+
+const kindLabels: Record<ArtifactKind, string> = {
+  signature: "Signatures",
+  attestation: "Attestations",
+  sbom: "SBOMs",
+  other: "Other",
+};
+
+const sourceLabels: Record<string, string> = {
+  referrers: "referrers API",
+  tag: "cosign tag",
+  index: "image index",
+};
+
+function shortDigest(digest: string): string {
+  return digest.replace(/^sha256:/, "").slice(0, 12);
+}
+
+/** Signatures, attestations and SBOMs attached to the image in its registry. */
+export function ReferrersView({ onClose }: ReferrersViewProps) {
+  const { artifacts, loading, error } = useReferrers();
+  const [selected, setSelected] = useState<string | null>(null);
+  const active = selected ?? artifacts[0]?.digest ?? null;
+
+  return (
+    <div className="flex flex-col h-full overflow-hidden">
+      <div className="flex items-center gap-3 px-3 h-8 border-b border-border shrink-0">
+        <span className="text-xs text-stone-200">Attached artifacts</span>
+        {!loading && !error && <span className="text-xs text-stone-500">{artifacts.length}</span>}
+        <button
+          type="button"
+          className="ml-auto px-2 py-0.5 rounded text-[11px] font-medium text-stone-500 hover:text-stone-300 hover:bg-stone-800 outline-none"
+          onClick={onClose}
+        >
+          Close
+        </button>
+      </div>
+      {loading ? (
+        <div className="flex items-center justify-center h-32 text-stone-500 text-sm">Querying registry…</div>
+      ) : error ? (
+        <div className="p-3 text-xs text-red-400 font-mono">{error}</div>
+      ) : artifacts.length === 0 ? (
+        <div className="p-3 text-xs text-stone-500">No signatures, attestations or SBOMs are attached to this image.</div>
+      ) : (
+        <div className="flex flex-1 min-h-0">
+          <ArtifactList artifacts={artifacts} selected={active} onSelect={setSelected} />
+          <div className="flex-1 min-w-0 overflow-auto">{active && <ArtifactDetailView key={active} digest={active} />}</div>
+        </div>
+      )}
+    </div>
+  );
+}
+
+function ArtifactList({
+  artifacts,
+  selected,
+  onSelect,
+}: {
+  artifacts: Artifact[];
+  selected: string | null;
+  onSelect: (digest: string) => void;
+}) {
+  const kinds = (Object.keys(kindLabels) as ArtifactKind[]).filter((k) => artifacts.some((a) => a.kind === k));
+  return (
+    <div className="w-56 shrink-0 border-r border-border overflow-auto py-1">
+      {kinds.map((kind) => (
+        <div key={kind}>
+          <div className="px-3 pt-2 pb-1 text-[10px] uppercase tracking-wide text-stone-500">{kindLabels[kind]}</div>
+          {artifacts
+            .filter((a) => a.kind === kind)
+            .map((a) => (
+              <button
+                key={a.digest}
+                type="button"
+                className={`w-full text-left px-3 py-1 text-xs outline-none ${
+                  a.digest === selected ? "bg-accent/15 text-stone-100" : "text-stone-400 hover:bg-stone-800/50"
+                }`}
+                onClick={() => onSelect(a.digest)}
+              >
+                <div className="font-mono">{shortDigest(a.digest)}</div>
+                <div className="text-[10px] text-stone-500 truncate" title={a.artifactType || a.mediaType}>
+                  {a.artifactType || a.mediaType}
+                </div>
+                <div className="text-[10px] text-stone-600">
+                  {sourceLabels[a.source] ?? a.source}
+                  {a.subject && ` · on ${shortDigest(a.subject)}`}
+                </div>
+              </button>
+            ))}
+        </div>
+      ))}
+    </div>
+  );
+}
+
+function ArtifactDetailView({ digest }: { digest: string }) {
+  const { detail, loading, error } = useReferrer(digest);
+  const [sbom, setSbom] = useState(0);
+
+  if (loading) return <div className="flex items-center justify-center h-32 text-stone-500 text-sm">Loading…</div>;
+  if (error || !detail) return <div className="p-3 text-xs text-red-400 font-mono">{error}</div>;
+
+  const sboms = detail.sboms ?? [];
+  return (
+    <div className="flex flex-col">
+      <Summary detail={detail} />
+      {detail.attestations?.map((a, i) => <AttestationView key={i} attestation={a} />)}
+      {detail.signatures?.map((s, i) => <SignatureView key={i} signature={s} />)}
+      {sboms.length > 0 && (
+        <div className="border-t border-border">
+          <div className="flex items-center gap-2 px-3 py-1.5 text-[11px] text-stone-500">
+            <span>SBOM</span>
+            {sboms.map((s, i) => (
+              <button
+                key={i}
+                type="button"
+                className={`px-2 py-0.5 rounded outline-none ${
+                  i === sbom ? "bg-accent/20 text-accent" : "hover:text-stone-300 hover:bg-stone-800"
+                }`}
+                onClick={() => setSbom(i)}
+              >
+                {s.format}
+              </button>
+            ))}
+            {sboms[sbom]?.truncated && <span className="text-amber-400">truncated</span>}
+          </div>
+          {sboms[sbom] && <SyntaxView path="sbom.json" content={sboms[sbom].content} />}
+        </div>
+      )}
+    </div>
+  );
+}
+
+function Summary({ detail }: { detail: ArtifactDetail }) {
+  return (
+    <div className="p-3 text-xs font-mono grid grid-cols-[auto_1fr] gap-x-4 gap-y-1">
+      <Field label="digest" value={detail.digest} />
+      <Field label="type" value={detail.artifactType || detail.mediaType} />
+      {detail.subject && <Field label="subject" value={detail.subject} />}
+      {detail.tag && <Field label="tag" value={detail.tag} />}
+      <Field label="size" value={formatBytes(detail.size)} />
+      {detail.annotations &&
+        Object.entries(detail.annotations).map(([k, v]) => <Field key={k} label={k} value={v} />)}
+      {detail.blobs?.map((b) => (
+        <Field
+          key={b.digest}
+          label="layer"
+          value={`${b.mediaType} (${formatBytes(b.size)}${b.truncated ? ", too large to decode" : ""})`}
+        />
+      ))}
+    </div>
+  );
+}
+
+function AttestationView({ attestation }: { attestation: Attestation }) {
+  return (
+    <div className="border-t border-border p-3 text-xs font-mono grid grid-cols-[auto_1fr] gap-x-4 gap-y-1">
+      <Field label="predicate" value={attestation.predicateType} />
+      <Field label="signed" value={attestation.signed ? "yes (DSSE)" : "no"} />
+      {attestation.subjects.map((s, i) => (
+        <Field key={i} label="subject" value={`${s.name} ${Object.values(s.digest)[0]?.slice(0, 12) ?? ""}`} />
+      ))}
+      {attestation.provenance && <ProvenanceView provenance={attestation.provenance} />}
+    </div>
+  );
+}
+
+function ProvenanceView({ provenance }: { provenance: Provenance }) {
+  return (
+    <>
+      <Field label="builder" value={provenance.builderID || "—"} />
+      <Field label="build type" value={provenance.buildType || "—"} />
+      {provenance.sourceRepo && (
+        <Field
+          label="source"
+          value={provenance.sourceRevision ? `${provenance.sourceRepo} @ ${provenance.sourceRevision}` : provenance.sourceRepo}
+        />
+      )}
+      {provenance.entryPoint && <Field label="entry point" value={provenance.entryPoint} />}
+      {provenance.materials.length > 0 && (
+        <>
+          <span className="text-stone-500">materials</span>
+          <div className="flex flex-col gap-0.5">
+            {provenance.materials.map((m, i) => (
+              <span key={i} className="text-stone-300 break-all">
+                {m.uri}
+                {m.digest && <span className="text-stone-500"> {Object.values(m.digest)[0]?.slice(0, 12)}</span>}
+              </span>
+            ))}
+          </div>
+        </>
+      )}
+    </>
+  );
+}
+
+function SignatureView({ signature }: { signature: Signature }) {
+  return (
+    <div className="border-t border-border p-3 text-xs font-mono grid grid-cols-[auto_1fr] gap-x-4 gap-y-1">
+      <Field label="identity" value={signature.identity || "—"} />
+      <Field label="signs" value={signature.manifestDigest} />
+      <Field label="keyless" value={signature.certificate ? "yes (certificate attached)" : "no"} />
+      <Field label="tlog" value={signature.hasBundle ? "Rekor bundle attached" : "none"} />
+      <Field label="signature" value={signature.signature || "—"} />
+    </div>
+  );
+}
+
+function Field({ label, value }: { label: string; value: string }) {
+  return (
+    <>
+      <span className="text-stone-500">{label}</span>
+      <span className="text-stone-300 break-all">{value}</span>
+    </>
+  );
+}
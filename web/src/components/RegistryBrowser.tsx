@@ -0,0 +1,184 @@
+import { useState } from "react";
+import { useCatalog, useTags } from "../hooks/useRegistry";
+import type { TagInfo } from "../types";
+import { parseImageRef } from "../utils";
+
+interface RegistryBrowserProps {
+  currentRef: string;
+  onOpen: (ref: string) => void;
+  onClose: () => void;
+}
+
+/** Browse repositories and tags in a registry, starting from the loaded image's repository. */
+export function RegistryBrowser({ currentRef, onOpen, onClose }: RegistryBrowserProps) {
+  const current = parseImageRef(currentRef);
+  const [hostInput, setHostInput] = useState(current.host);
+  const [host, setHost] = useState<string | null>(null);
+  const [repoInput, setRepoInput] = useState(`${current.host}/${current.repository}`);
+  const [repository, setRepository] = useState<string | null>(`${current.host}/${current.repository}`);
+
+  const catalog = useCatalog(host);
+  const tags = useTags(repository);
+
+  const inputClass =
+    "flex-1 min-w-0 bg-panel border border-border rounded px-2 py-0.5 text-xs font-mono text-stone-200 outline-none focus:border-accent/50";
+  const buttonClass =
+    "px-2 py-0.5 rounded text-[11px] font-medium text-stone-500 hover:text-stone-300 hover:bg-stone-800 outline-none";
+
+  return (
+    <div className="flex flex-col h-full overflow-hidden">
+      <div className="flex items-center gap-3 px-3 h-8 border-b border-border shrink-0">
+        <span className="text-xs text-stone-200">Registry</span>
+        <button type="button" className={`ml-auto ${buttonClass}`} onClick={onClose}>
+          Close
+        </button>
+      </div>
+      <div className="flex flex-1 min-h-0">
+        <div className="w-64 shrink-0 border-r border-border flex flex-col">
+          <form
+            className="flex gap-1 p-2 border-b border-border"
+            onSubmit={(e) => {
+              e.preventDefault();
+              setHost(hostInput.trim() || null);
+            }}
+          >
+            <input className={inputClass} value={hostInput} onChange={(e) => setHostInput(e.target.value)} placeholder="registry host" />
+            <button type="submit" className={buttonClass}>
+              List
+            </button>
+          </form>
+          <div className="flex-1 overflow-auto py-1">
+            {host === null ? (
+              <div className="px-3 py-2 text-[11px] text-stone-500">List repositories with the registry's catalog API.</div>
+            ) : catalog.loading ? (
+              <div className="px-3 py-2 text-xs text-stone-500">Loading…</div>
+            ) : catalog.error ? (
+              <div className="px-3 py-2 text-xs text-red-400 font-mono break-all">{catalog.error}</div>
+            ) : (
+              <>
+                {catalog.repositories.map((repo) => {
+                  const full = `${host}/${repo}`;
+                  return (
+                    <button
+                      key={repo}
+                      type="button"
+                      className={`w-full text-left px-3 py-0.5 text-xs font-mono truncate outline-none ${
+                        full === repository ? "bg-accent/15 text-stone-100" : "text-stone-400 hover:bg-stone-800/50"
+                      }`}
+                      title={full}
+                      onClick={() => {
+                        setRepository(full);
+                        setRepoInput(full);
+                      }}
+                    >
+                      {repo}
+                    </button>
+                  );
+                })}
+                {catalog.repositories.length === 0 && <div className="px-3 py-2 text-xs text-stone-500">No repositories</div>}
+                {catalog.hasMore && (
+                  <button type="button" className={`mx-2 my-1 ${buttonClass}`} onClick={() => catalog.loadMore()} disabled={catalog.loadingMore}>
+                    {catalog.loadingMore ? "Loading…" : "More"}
+                  </button>
+                )}
+              </>
+            )}
+          </div>
+        </div>
+
+        <div className="flex-1 min-w-0 flex flex-col">
+          <form
+            className="flex gap-1 p-2 border-b border-border"
+            onSubmit={(e) => {
+              e.preventDefault();
+              setRepository(repoInput.trim() || null);
+            }}
+          >
+            <input className={inputClass} value={repoInput} onChange={(e) => setRepoInput(e.target.value)} placeholder="registry/repository" />
+            <button type="submit" className={buttonClass}>
+              Tags
+            </button>
+          </form>
+          <div className="flex-1 overflow-auto">
+            {!repository ? null : tags.loading ? (
+              <div className="flex items-center justify-center h-32 text-stone-500 text-sm">Loading…</div>
+            ) : tags.error ? (
+              <div className="p-3 text-xs text-red-400 font-mono break-all">{tags.error}</div>
+            ) : (
+              <>
+                <table className="w-full text-xs font-mono">
+                  <thead className="text-[10px] uppercase tracking-wide text-stone-500 text-left">
+                    <tr>
+                      <th className="px-3 py-1 font-normal">tag</th>
+                      <th className="px-3 py-1 font-normal">digest</th>
+                      <th className="px-3 py-1 font-normal">created</th>
+                      <th className="px-3 py-1" />
+                    </tr>
+                  </thead>
+                  <tbody>
+                    {tags.tags.map((t) => (
+                      <TagRow key={t.tag} tag={t} current={t.ref === currentRef} onOpen={onOpen} />
+                    ))}
+                  </tbody>
+                </table>
+                {tags.tags.length === 0 && <div className="p-3 text-xs text-stone-500">No tags</div>}
+                {tags.hasMore && (
+                  <button type="button" className={`m-2 ${buttonClass}`} onClick={() => tags.loadMore()} disabled={tags.loadingMore}>
+                    {tags.loadingMore ? "Loading…" : "More"}
+                  </button>
+                )}
+              </>
+            )}
+          </div>
+        </div>
+      </div>
+    </div>
+  );
+}
+
+function TagRow({ tag, current, onOpen }: { tag: TagInfo; current: boolean; onOpen: (ref: string) => void }) {
+  const [copied, setCopied] = useState(false);
+  const copy = () => {
+    navigator.clipboard.writeText(`peel ${tag.ref}`).then(() => {
+      setCopied(true);
+      setTimeout(() => setCopied(false), 1500);
+    });
+  };
+
+  return (
+    <tr className={`border-t border-border/50 ${current ? "bg-accent/10" : "hover:bg-stone-800/30"}`}>
+      <td className="px-3 py-1 text-stone-200 whitespace-nowrap">
+        {tag.tag}
+        {tag.platforms && tag.platforms.length > 0 && (
+          <span className="ml-2 text-[10px] text-stone-500" title={tag.platforms.join("\n")}>
+            {tag.platforms.length} platforms
+          </span>
+        )}
+      </td>
+      <td className="px-3 py-1 text-stone-400" title={tag.digest}>
+        {tag.error ? <span className="text-red-400">{tag.error}</span> : tag.digest?.replace(/^sha256:/, "").slice(0, 12)}
+      </td>
+      <td className="px-3 py-1 text-stone-400 whitespace-nowrap">{tag.created ? new Date(tag.created).toLocaleString() : "—"}</td>
+      <td className="px-3 py-1 text-right whitespace-nowrap">
+        {!current && (
+          <button
+            type="button"
+            className="mr-3 text-[11px] text-accent/70 hover:text-accent outline-none"
+            title={`Open ${tag.ref}`}
+            onClick={() => onOpen(tag.ref)}
+          >
+            open
+          </button>
+        )}
+        <button
+          type="button"
+          className="text-[11px] text-accent/70 hover:text-accent outline-none"
+          title={`Copy: peel ${tag.ref}`}
+          onClick={copy}
+        >
+          {copied ? "copied" : "copy command"}
+        </button>
+      </td>
+    </tr>
+  );
+}
@@ -0,0 +1,26 @@
+import { useVerification } from "../hooks/useReferrers";
+
+/** Header badge showing whether the image carries a signature from the --key public key. */
+export function SignatureBadge({ onClick }: { onClick: () => void }) {
+  const { results } = useVerification();
+  if (!results) return null;
+
+  const verified = results.filter((r) => r.verified);
+  const [label, style, title] =
+    verified.length > 0
+      ? ["signed", "bg-change-added/20 text-change-added", verified.map((r) => `✓ ${r.subject} (${r.identity})`).join("\n")]
+      : results.length > 0
+        ? ["signature invalid", "bg-change-deleted/20 text-change-deleted", results.map((r) => `✗ ${r.subject}: ${r.error}`).join("\n")]
+        : ["unsigned", "bg-amber-500/20 text-amber-400", "No cosign signatures are attached to this image"];
+
+  return (
+    <button
+      type="button"
+      className={`text-[11px] px-2 py-0.5 rounded font-medium outline-none ${style}`}
+      title={title}
+      onClick={onClick}
+    >
+      {label}
+    </button>
+  );
+}
@@ -0,0 +1,31 @@
+import { useCallback } from "react";
+import { useQueries, type UseQueryResult } from "@tanstack/react-query";
+import { api } from "../api";
+import type { DirEntry, TreeView } from "../types";
+
+export function dirQueryKey(layer: number | null, view: TreeView, path: string) {
+  return ["layerDir", layer, view, path] as const;
+}
+
+/** Fetch the listing of each directory in `paths`, keyed by path. */
+export function useDirListings(layer: number | null, view: TreeView, paths: string[], enabled = true) {
+  const combine = useCallback(
+    (results: UseQueryResult<DirEntry[]>[]) => {
+      const listings = new Map<string, DirEntry[]>();
+      results.forEach((r, i) => {
+        if (r.data) listings.set(paths[i], r.data);
+      });
+      return { listings, rootPending: results[0]?.isPending ?? false };
+    },
+    [paths],
+  );
+
+  return useQueries({
+    queries: paths.map((path) => ({
+      queryKey: dirQueryKey(layer, view, path),
+      queryFn: () => api.layerDir(layer!, path, view),
+      enabled: enabled && layer !== null,
+    })),
+    combine,
+  });
+}
@@ -0,0 +1,16 @@
+import { useQuery } from "@tanstack/react-query";
+import { api } from "../api";
+import type { DockerfileInfo } from "../types";
+
+export function useDockerfile() {
+  const query = useQuery<DockerfileInfo>({
+    queryKey: ["dockerfile"],
+    queryFn: api.dockerfile,
+  });
+
+  return {
+    dockerfile: query.data ?? null,
+    loading: query.isPending,
+    error: query.error?.message ?? null,
+  };
+}
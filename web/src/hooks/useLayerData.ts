@@ -1,24 +1,22 @@
 import { useQuery } from "@tanstack/react-query";
 import { api } from "../api";
-import type { FileNode, DiffEntry } from "../types";
-
-export function useLayerData(layerIndex: number | null) {
-  const treeQuery = useQuery<FileNode>({
-    queryKey: ["layerTree", layerIndex],
-    queryFn: () => api.layerTree(layerIndex!),
-    enabled: layerIndex !== null,
-  });
+import type { DiffEntry } from "../types";
 
+/**
+ * Fetch the changes made by layerIndex, or by every layer from rangeStart
+ * through layerIndex when a range is selected.
+ */
+export function useLayerData(layerIndex: number | null, rangeStart: number | null = null) {
   const diffQuery = useQuery<DiffEntry[]>({
-    queryKey: ["layerDiff", layerIndex],
-    queryFn: () => api.layerDiff(layerIndex!),
+    queryKey: rangeStart === null ? ["layerDiff", layerIndex] : ["diffRange", rangeStart - 1, layerIndex],
+    queryFn: () =>
+      rangeStart === null ? api.layerDiff(layerIndex!) : api.diffRange(rangeStart - 1, layerIndex!),
     enabled: layerIndex !== null,
   });
 
   return {
-    tree: treeQuery.data ?? null,
     diff: diffQuery.data ?? [],
-    loading: treeQuery.isPending || diffQuery.isPending,
-    error: treeQuery.error?.message ?? diffQuery.error?.message ?? null,
+    loading: diffQuery.isPending,
+    error: diffQuery.error?.message ?? null,
   };
 }
@@ -0,0 +1,43 @@
+import { useQuery } from "@tanstack/react-query";
+import { api } from "../api";
+import type { Artifact, ArtifactDetail, Verification } from "../types";
+
+export function useReferrers() {
+  const query = useQuery<Artifact[]>({
+    queryKey: ["referrers"],
+    queryFn: api.referrers,
+    retry: false,
+  });
+
+  return {
+    artifacts: query.data ?? [],
+    loading: query.isPending,
+    error: query.error?.message ?? null,
+  };
+}
+
+export function useReferrer(digest: string | null) {
+  const query = useQuery<ArtifactDetail>({
+    queryKey: ["referrer", digest],
+    queryFn: () => api.referrer(digest!),
+    enabled: digest !== null,
+    retry: false,
+  });
+
+  return {
+    detail: query.data ?? null,
+    loading: query.isPending && digest !== null,
+    error: query.error?.message ?? null,
+  };
+}
+
+/** Signature checks against the --key public key. Null when peel runs without a key. */
+export function useVerification() {
+  const query = useQuery<Verification[]>({
+    queryKey: ["verify"],
+    queryFn: api.verify,
+    retry: false,
+  });
+
+  return { results: query.data ?? null };
+}
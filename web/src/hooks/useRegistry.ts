@@ -0,0 +1,42 @@
+import { useInfiniteQuery } from "@tanstack/react-query";
+import { api } from "../api";
+
+export function useCatalog(host: string | null) {
+  const query = useInfiniteQuery({
+    queryKey: ["catalog", host],
+    queryFn: ({ pageParam }) => api.catalog(host!, pageParam),
+    initialPageParam: "",
+    getNextPageParam: (page) => page.next,
+    enabled: !!host,
+    retry: false,
+  });
+
+  return {
+    repositories: query.data?.pages.flatMap((p) => p.repositories) ?? [],
+    loading: query.isPending && !!host,
+    error: query.error?.message ?? null,
+    hasMore: query.hasNextPage,
+    loadMore: query.fetchNextPage,
+    loadingMore: query.isFetchingNextPage,
+  };
+}
+
+export function useTags(repository: string | null) {
+  const query = useInfiniteQuery({
+    queryKey: ["tags", repository],
+    queryFn: ({ pageParam }) => api.tags(repository!, pageParam),
+    initialPageParam: "",
+    getNextPageParam: (page) => page.next,
+    enabled: !!repository,
+    retry: false,
+  });
+
+  return {
+    tags: query.data?.pages.flatMap((p) => p.tags) ?? [],
+    loading: query.isPending && !!repository,
+    error: query.error?.message ?? null,
+    hasMore: query.hasNextPage,
+    loadMore: query.fetchNextPage,
+    loadingMore: query.isFetchingNextPage,
+  };
+}
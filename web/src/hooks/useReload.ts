@@ -0,0 +1,52 @@
+import { useCallback, useEffect, useState } from "react";
+import { useQueryClient } from "@tanstack/react-query";
+import { api, isReport } from "../api";
+import type { LoadEvent, OpenTarget } from "../types";
+
+interface ReloadState {
+  reloading: boolean;
+  error: string | null;
+  last: LoadEvent | null;
+}
+
+/**
+ * Follows load events from the server, refetching everything when a reload
+ * (from the button or --watch) or an opened image brings in a different one.
+ */
+export function useReload() {
+  const queryClient = useQueryClient();
+  const [state, setState] = useState<ReloadState>({ reloading: false, error: null, last: null });
+
+  useEffect(() => {
+    if (isReport) return;
+    const source = new EventSource("/api/events");
+    source.onmessage = (msg) => {
+      const ev = JSON.parse(msg.data) as LoadEvent;
+      if (ev.type === "loading") {
+        setState((s) => ({ ...s, reloading: true, error: null }));
+      } else if (ev.type === "error") {
+        setState({ reloading: false, error: ev.error ?? "reload failed", last: ev });
+      } else {
+        setState({ reloading: false, error: null, last: ev });
+        if (ev.changed) queryClient.invalidateQueries();
+      }
+    };
+    return () => source.close();
+  }, [queryClient]);
+
+  const reload = useCallback(() => {
+    setState((s) => ({ ...s, reloading: true, error: null }));
+    api.reload().catch((err: Error) => setState((s) => ({ ...s, reloading: false, error: err.message })));
+  }, []);
+
+  const open = useCallback((target: OpenTarget) => {
+    setState((s) => ({ ...s, reloading: true, error: null }));
+    api.open(target).then(
+      // going back to the previous image completes without a loading event
+      (res) => res.status === "ready" && setState((s) => ({ ...s, reloading: false })),
+      (err: Error) => setState((s) => ({ ...s, reloading: false, error: err.message })),
+    );
+  }, []);
+
+  return { ...state, reload, open };
+}
@@ -0,0 +1,37 @@
+import { expect, test } from "vitest";
+import { emptySelection, parseURL, selectionURL } from "./useURLState";
+
+const url = (s: string) => new URL(s, "http://localhost:9870");
+
+test("parseURL: root", () => {
+  expect(parseURL(url("/"))).toEqual({ ...emptySelection, image: null });
+});
+
+test("parseURL: layer, file and line", () => {
+  expect(parseURL(url("/layers/5/etc/passwd?image=nginx:1.27&changes=1#L12"))).toEqual({
+    layer: 5,
+    rangeStart: null,
+    file: "/etc/passwd",
+    line: 12,
+    changesOnly: true,
+    image: "nginx:1.27",
+  });
+});
+
+test("parseURL: range", () => {
+  const sel = parseURL(url("/layers/2..5"));
+  expect(sel.rangeStart).toBe(2);
+  expect(sel.layer).toBe(5);
+  expect(sel.file).toBeNull();
+});
+
+test("parseURL: line without a file is ignored", () => {
+  expect(parseURL(url("/layers/1#L3")).line).toBeNull();
+});
+
+test("selectionURL: round trips", () => {
+  const sel = { layer: 3, rangeStart: 1, file: "/usr/share/my file.txt", line: 7, changesOnly: true };
+  const built = selectionURL("ghcr.io/org/app:v1", sel);
+  expect(built).toBe("/layers/1..3/usr/share/my%20file.txt?image=ghcr.io%2Forg%2Fapp%3Av1&changes=1#L7");
+  expect(parseURL(url(built))).toEqual({ ...sel, image: "ghcr.io/org/app:v1" });
+});
@@ -0,0 +1,105 @@
+import { useEffect, useRef } from "react";
+import { isReport } from "../api";
+import type { OpenTarget } from "../types";
+
+/** What the URL records about the view. */
+export interface Selection {
+  layer: number | null;
+  rangeStart: number | null;
+  file: string | null;
+  /** 1-based line in the open file */
+  line: number | null;
+  changesOnly: boolean;
+}
+
+export const emptySelection: Selection = { layer: null, rangeStart: null, file: null, line: null, changesOnly: false };
+
+/**
+ * Reads the selection and image from a URL of the form
+ * /layers/[FROM..]LAYER[/PATH]?changes=1&image=REF#LLINE.
+ */
+export function parseURL(url: URL = new URL(window.location.href)): Selection & { image: string | null } {
+  const sel: Selection = { ...emptySelection, changesOnly: url.searchParams.get("changes") === "1" };
+  const match = url.pathname.match(/^\/layers\/(?:(\d+)\.\.)?(\d+)(\/.*)?$/);
+  if (match) {
+    sel.rangeStart = match[1] !== undefined ? Number(match[1]) : null;
+    sel.layer = Number(match[2]);
+    if (match[3] && match[3] !== "/") {
+      sel.file = decodeURIComponent(match[3]);
+      const line = url.hash.match(/^#L(\d+)$/);
+      if (line) sel.line = Number(line[1]);
+    }
+  }
+  return { ...sel, image: url.searchParams.get("image") };
+}
+
+/** Builds the URL for a selection in image. */
+export function selectionURL(image: string, sel: Selection): string {
+  let path = "/";
+  if (sel.layer !== null) {
+    path = `/layers/${sel.rangeStart !== null ? `${sel.rangeStart}..` : ""}${sel.layer}`;
+    if (sel.file) path += sel.file.split("/").map(encodeURIComponent).join("/");
+  }
+  const params = new URLSearchParams();
+  params.set("image", image);
+  if (sel.changesOnly) params.set("changes", "1");
+  const hash = sel.file && sel.line !== null ? `#L${sel.line}` : "";
+  return `${path}?${params}${hash}`;
+}
+
+/**
+ * Keeps the URL in step with the shown image and selection, so links can be
+ * shared. Opening another image pushes a history entry; going back or
+ * forward, or following a link to another image, opens the image in the URL
+ * and restores its selection. Reports opened from disk leave the URL alone.
+ */
+export function useURLState(
+  currentRef: string | null,
+  selection: Selection,
+  apply: (sel: Selection) => void,
+  open: (target: OpenTarget) => void,
+  loadError: string | null,
+) {
+  // the image the URL was last synced for; null until the first one shows
+  const shown = useRef<string | null>(null);
+  // a link to another image is being opened, so the URL is left alone
+  const opening = useRef(false);
+
+  // Must run before the effect below, which overwrites the URL
+  useEffect(() => {
+    if (isReport || currentRef === null || currentRef === shown.current) return;
+    const { image, ...sel } = parseURL();
+    if (shown.current === null && image && image !== currentRef) {
+      if (!opening.current) {
+        opening.current = true;
+        open({ ref: image });
+        return;
+      }
+      if (!loadError) return;
+    }
+    opening.current = false;
+    shown.current = currentRef;
+    apply(!image || image === currentRef ? sel : emptySelection);
+  }, [currentRef, apply, open, loadError]);
+
+  useEffect(() => {
+    if (isReport || currentRef === null || shown.current !== currentRef) return;
+    const url = selectionURL(currentRef, selection);
+    const here = window.location.pathname + window.location.search + window.location.hash;
+    if (url === here) return;
+    const { image } = parseURL();
+    if (image && image !== currentRef) window.history.pushState(null, "", url);
+    else window.history.replaceState(null, "", url);
+  }, [currentRef, selection]);
+
+  useEffect(() => {
+    if (isReport) return;
+    const onPopState = () => {
+      const { image, ...sel } = parseURL();
+      if (image && image !== currentRef) open({ ref: image });
+      else apply(sel);
+    };
+    window.addEventListener("popstate", onPopState);
+    return () => window.removeEventListener("popstate", onPopState);
+  }, [currentRef, apply, open]);
+}
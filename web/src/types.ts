@@ -1,6 +1,17 @@
-export type FileType = "file" | "dir" | "symlink";
+export type FileType = "file" | "dir" | "symlink" | "whiteout";
+export type TreeView = "cumulative" | "layer";
+export type TreeSort = "name" | "size";
 export type ChangeKind = "added" | "modified" | "deleted";
 
+export interface Healthcheck {
+  test: string[];
+  interval?: string;
+  timeout?: string;
+  startPeriod?: string;
+  startInterval?: string;
+  retries?: number;
+}
+
 export interface ImageConfig {
   env: string[] | null;
   entrypoint: string[] | null;
@@ -8,6 +19,27 @@ export interface ImageConfig {
   workingDir: string;
   user: string;
   labels: Record<string, string> | null;
+  exposedPorts?: string[];
+  volumes?: string[];
+  healthcheck?: Healthcheck;
+  stopSignal?: string;
+  shell?: string[];
+  onBuild?: string[];
+  argsEscaped?: boolean;
+}
+
+export interface Descriptor {
+  mediaType: string;
+  digest: string;
+  size: number;
+}
+
+export interface ManifestInfo {
+  mediaType: string;
+  schemaVersion: number;
+  size: number;
+  config: Descriptor;
+  annotations?: Record<string, string>;
 }
 
 export interface ImageInfo {
@@ -15,15 +47,39 @@ export interface ImageInfo {
   digest: string;
   arch: string;
   os: string;
+  variant?: string;
+  created?: string;
+  author?: string;
   config: ImageConfig;
+  rootfs: { type: string; diffIDs: string[] };
+  manifest: ManifestInfo;
   layerCount: number;
+  /** how peel picked this image, e.g. a local image of another platform */
+  resolution?: Resolution;
+}
+
+export interface Resolution {
+  source: string;
+  platform: string;
+  requested: string;
+  reason: string;
 }
 
 export interface LayerInfo {
   index: number;
   diffID: string;
+  /** compressed blob digest */
+  digest?: string;
+  mediaType?: string;
+  /** compressed blob size */
   size: number;
+  uncompressedSize: number;
   command: string;
+  /** command normalized to a Dockerfile instruction */
+  instruction: string;
+  created?: string;
+  author?: string;
+  comment?: string;
   empty: boolean;
 }
 
@@ -33,9 +89,29 @@ export interface FileNode {
   type: FileType;
   size: number;
   linkTarget?: string;
+  opaque?: boolean;
+  addedIn: number;
+  modifiedIn: number;
+  totalSize?: number;
+  descendants?: number;
   children?: FileNode[];
 }
 
+export interface DirEntry {
+  name: string;
+  path: string;
+  type: FileType;
+  size: number;
+  linkTarget?: string;
+  opaque?: boolean;
+  addedIn: number;
+  modifiedIn: number;
+  childCount: number;
+  descendants: number;
+  totalSize: number;
+  layerSize: number;
+}
+
 export interface DiffEntry {
   path: string;
   type: FileType;
@@ -43,6 +119,35 @@ export interface DiffEntry {
   size: number;
 }
 
+export interface PathChange {
+  layer: number;
+  command: string;
+  instruction: string;
+  changeKind: ChangeKind;
+  type: FileType;
+  size: number;
+}
+
+export interface FileVersion extends PathChange {
+  content?: FileContent;
+  diff?: string;
+  /** either side of the diff was cut short, so only the leading bytes are compared */
+  truncated?: boolean;
+}
+
+export interface FileRef {
+  layer: number;
+  path: string;
+}
+
+export interface FileDiff {
+  from: FileRef;
+  to: FileRef;
+  isBinary: boolean;
+  truncated: boolean;
+  diff: string;
+}
+
 export interface FileContent {
   path: string;
   resolvedPath?: string;
@@ -51,3 +156,164 @@ export interface FileContent {
   truncated: boolean;
   content: string;
 }
+
+export interface DockerfileInstruction {
+  keyword: string;
+  args: string;
+  line: number;
+  endLine: number;
+  stage: number;
+}
+
+/** A layer tied to the Dockerfile instruction that produced it. */
+export interface DockerfileMatch {
+  layer: number;
+  line: number;
+  endLine: number;
+  stage: string;
+  instruction: string;
+  /** false when only the keyword agrees with the layer's history */
+  exact: boolean;
+  fromStage?: string;
+  fromLine?: number;
+}
+
+export interface DockerfileAlignment {
+  matches: DockerfileMatch[];
+  /** instructions no layer accounts for */
+  unmatched: DockerfileInstruction[];
+  /** layers after the base image that no instruction accounts for */
+  extra: number[];
+}
+
+export interface DockerfileInfo {
+  /** reconstructed from the image history */
+  content: string;
+  /** the Dockerfile passed with --dockerfile */
+  source?: {
+    path: string;
+    content: string;
+    alignment: DockerfileAlignment;
+  };
+}
+
+export type ArtifactKind = "signature" | "attestation" | "sbom" | "other";
+
+/** A manifest attached to the image: a signature, attestation or SBOM. */
+export interface Artifact {
+  digest: string;
+  mediaType: string;
+  artifactType?: string;
+  kind: ArtifactKind;
+  /** referrers API, cosign tag or BuildKit attestation manifest */
+  source: "referrers" | "tag" | "index" | "";
+  tag?: string;
+  subject: string;
+  size: number;
+  annotations?: Record<string, string>;
+}
+
+export interface Signature {
+  identity: string;
+  manifestDigest: string;
+  signature: string;
+  certificate?: string;
+  hasBundle: boolean;
+  payload: string;
+}
+
+export interface Material {
+  uri: string;
+  digest?: Record<string, string>;
+}
+
+export interface Provenance {
+  slsaVersion: string;
+  builderID: string;
+  buildType: string;
+  sourceRepo?: string;
+  sourceRevision?: string;
+  entryPoint?: string;
+  materials: Material[];
+}
+
+export interface Attestation {
+  payloadType?: string;
+  signed: boolean;
+  predicateType: string;
+  subjects: { name: string; digest: Record<string, string> }[];
+  provenance?: Provenance;
+  predicate: unknown;
+}
+
+export interface SBOM {
+  format: string;
+  mediaType: string;
+  content: string;
+  truncated: boolean;
+}
+
+export interface ArtifactDetail extends Artifact {
+  signatures?: Signature[];
+  attestations?: Attestation[];
+  sboms?: SBOM[];
+  blobs?: {
+    mediaType: string;
+    digest: string;
+    size: number;
+    annotations?: Record<string, string>;
+    /** too large to decode */
+    truncated?: boolean;
+  }[];
+}
+
+/** A cosign signature checked against the --key public key. */
+export interface Verification {
+  artifact: string;
+  subject: string;
+  identity: string;
+  verified: boolean;
+  error?: string;
+}
+
+export interface CatalogPage {
+  registry: string;
+  repositories: string[];
+  next?: string;
+}
+
+export interface TagInfo {
+  tag: string;
+  ref: string;
+  digest?: string;
+  mediaType?: string;
+  created?: string;
+  platforms?: string[];
+  error?: string;
+}
+
+export interface TagPage {
+  repository: string;
+  tags: TagInfo[];
+  next?: string;
+}
+
+/** Progress of a (re)load, streamed from /api/events. */
+export interface LoadEvent {
+  type: "loading" | "loaded" | "error";
+  ref: string;
+  digest?: string;
+  /** the digest differs from the previously loaded image */
+  changed?: boolean;
+  layers?: number;
+  /** content layers reused from the previous image instead of downloaded */
+  reused?: number;
+  error?: string;
+}
+
+/** An image to open in place of the current one. Empty platform and source use the command line's. */
+export interface OpenTarget {
+  ref: string;
+  platform?: string;
+  source?: string;
+}
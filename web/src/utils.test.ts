@@ -1,5 +1,5 @@
 import { expect, test } from "vitest";
-import { formatBytes, cleanCommand } from "./utils";
+import { formatBytes, cleanCommand, formatHealthcheck, parseImageRef, parentDirs } from "./utils";
 
 test("formatBytes: 0", () => {
   expect(formatBytes(0)).toBe("0 B");
@@ -32,3 +32,43 @@ test("cleanCommand: #(nop) prefix", () => {
 test("cleanCommand: trims whitespace", () => {
   expect(cleanCommand("  hello  ")).toBe("hello");
 });
+
+test("formatHealthcheck: shell form with flags", () => {
+  expect(formatHealthcheck({ test: ["CMD-SHELL", "curl -f localhost"], interval: "30s", retries: 3 })).toBe(
+    "--interval=30s --retries=3 CMD curl -f localhost",
+  );
+});
+
+test("formatHealthcheck: start period and interval", () => {
+  expect(formatHealthcheck({ test: ["CMD-SHELL", "true"], startPeriod: "1m0s", startInterval: "5s" })).toBe(
+    "--start-period=1m0s --start-interval=5s CMD true",
+  );
+});
+
+test("formatHealthcheck: exec form", () => {
+  expect(formatHealthcheck({ test: ["CMD", "/bin/check", "-q"] })).toBe('CMD ["/bin/check","-q"]');
+});
+
+test("formatHealthcheck: NONE", () => {
+  expect(formatHealthcheck({ test: ["NONE"] })).toBe("NONE");
+});
+
+test("parseImageRef: Docker Hub official image", () => {
+  expect(parseImageRef("nginx:1.27")).toEqual({ host: "docker.io", repository: "library/nginx" });
+});
+
+test("parseImageRef: registry with port and digest", () => {
+  expect(parseImageRef("localhost:5000/team/app@sha256:abc")).toEqual({ host: "localhost:5000", repository: "team/app" });
+});
+
+test("parseImageRef: Docker Hub user image", () => {
+  expect(parseImageRef("someuser/tool")).toEqual({ host: "docker.io", repository: "someuser/tool" });
+});
+
+test("parentDirs: nested file", () => {
+  expect([...parentDirs("/etc/nginx/nginx.conf")]).toEqual(["/etc", "/etc/nginx"]);
+});
+
+test("parentDirs: top-level file", () => {
+  expect(parentDirs("/hello").size).toBe(0);
+});
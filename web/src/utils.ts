@@ -1,3 +1,5 @@
+import type { Healthcheck } from "./types";
+
 const units = ["B", "KB", "MB", "GB", "TB"];
 
 export function formatBytes(bytes: number): string {
@@ -7,9 +9,43 @@ export function formatBytes(bytes: number): string {
   return `${val < 10 && i > 0 ? val.toFixed(1) : Math.round(val)} ${units[i]}`;
 }
 
+/** Render a healthcheck the way it would be written in a Dockerfile. */
+export function formatHealthcheck(hc: Healthcheck): string {
+  const [kind, ...args] = hc.test;
+  if (kind === "NONE") return "NONE";
+  const flags = [
+    hc.interval && `--interval=${hc.interval}`,
+    hc.timeout && `--timeout=${hc.timeout}`,
+    hc.startPeriod && `--start-period=${hc.startPeriod}`,
+    hc.startInterval && `--start-interval=${hc.startInterval}`,
+    hc.retries && `--retries=${hc.retries}`,
+  ].filter(Boolean);
+  const cmd = kind === "CMD-SHELL" ? args.join(" ") : JSON.stringify(args);
+  return [...flags, "CMD", cmd].join(" ");
+}
+
 export function cleanCommand(cmd: string): string {
   return cmd
     .replace(/^\/bin\/sh -c /, "")
     .replace(/^#\(nop\)\s*/, "")
     .trim();
 }
+
+/** Split an image reference into registry host and repository path, defaulting to Docker Hub. */
+export function parseImageRef(ref: string): { host: string; repository: string } {
+  const name = ref.replace(/@.*$/, "").replace(/:[^/:]+$/, "");
+  const slash = name.indexOf("/");
+  const first = slash === -1 ? "" : name.slice(0, slash);
+  if (first.includes(".") || first.includes(":") || first === "localhost") {
+    return { host: first, repository: name.slice(slash + 1) };
+  }
+  return { host: "docker.io", repository: slash === -1 ? `library/${name}` : name };
+}
+
+/** The directories to expand to show path, e.g. /etc and /etc/nginx for /etc/nginx/nginx.conf. */
+export function parentDirs(path: string): Set<string> {
+  const dirs = new Set<string>();
+  const parts = path.split("/").filter(Boolean);
+  for (let i = 1; i < parts.length; i++) dirs.add("/" + parts.slice(0, i).join("/"));
+  return dirs;
+}